@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/joho/godotenv"
+	"github.com/onyeepeace/todo-api/internal/db"
+	"github.com/onyeepeace/todo-api/internal/secrets"
+)
+
+const usage = `Usage: migrate <command> [args]
+
+Commands:
+  up          Apply all pending migrations
+  down [N]    Roll back the last N migrations (default 1)
+  status      Show applied and pending migrations
+  redo        Roll back the last migration and apply it again
+`
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found")
+	}
+
+	provider, err := secrets.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure secrets: %v", err)
+	}
+	config := db.ConfigFromEnv()
+	if config.User, err = provider.Get(context.Background(), "DB_USER"); err != nil {
+		log.Fatalf("Failed to load database credentials: %v", err)
+	}
+	if config.Password, err = provider.Get(context.Background(), "DB_PASSWORD"); err != nil {
+		log.Fatalf("Failed to load database credentials: %v", err)
+	}
+
+	database, err := db.Connect(config)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := db.NewMigrator(database)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	ctx := context.Background()
+
+	switch flag.Arg(0) {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+		for _, m := range applied {
+			fmt.Printf("Applied %d_%s\n", m.Version, m.Name)
+		}
+
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			steps, err = strconv.Atoi(flag.Arg(1))
+			if err != nil || steps < 1 {
+				log.Fatalf("Invalid number of steps: %s", flag.Arg(1))
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		for _, m := range rolledBack {
+			fmt.Printf("Rolled back %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Rollback failed: %v", err)
+		}
+		if len(rolledBack) == 0 {
+			fmt.Println("No migrations to roll back")
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("Failed to get migration status: %v", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Modified {
+				state += " (modified)"
+			}
+			fmt.Printf("%04d_%-40s %s\n", s.Version, s.Name, state)
+		}
+
+	case "redo":
+		m, err := migrator.Redo(ctx)
+		if err != nil {
+			log.Fatalf("Redo failed: %v", err)
+		}
+		fmt.Printf("Redid %d_%s\n", m.Version, m.Name)
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
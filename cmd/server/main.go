@@ -1,17 +1,25 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/cors"
 	"github.com/joho/godotenv"
 	"github.com/onyeepeace/todo-api/internal/db"
+	"github.com/onyeepeace/todo-api/internal/events"
 	"github.com/onyeepeace/todo-api/internal/handlers"
+	"github.com/onyeepeace/todo-api/internal/itemtypes"
+	"github.com/onyeepeace/todo-api/internal/jwtkeys"
+	"github.com/onyeepeace/todo-api/internal/mail"
 	"github.com/onyeepeace/todo-api/internal/middleware"
+	"github.com/onyeepeace/todo-api/internal/oauth"
+	"github.com/onyeepeace/todo-api/internal/secrets"
+	"github.com/onyeepeace/todo-api/internal/store"
 )
 
 func main() {
@@ -20,80 +28,228 @@ func main() {
 		log.Println("Warning: .env file not found")
 	}
 
-	// Initialize database
-	config := db.Config{
-		Host:     os.Getenv("DB_HOST"),
-		Port:     5432,
-		User:     os.Getenv("DB_USER"),
-		Password: os.Getenv("DB_PASSWORD"),
-		DBName:   os.Getenv("DB_NAME"),
-		SSLMode:  "disable",
+	// Secrets come from the environment, mounted files or Vault, and are
+	// reread every SECRETS_REFRESH so they can be rotated in place
+	provider, err := secrets.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure secrets: %v", err)
 	}
+	refreshInterval := time.Minute
+	if value := os.Getenv("SECRETS_REFRESH"); value != "" {
+		if refreshInterval, err = time.ParseDuration(value); err != nil {
+			log.Fatalf("Invalid SECRETS_REFRESH: %v", err)
+		}
+	}
+	secretWatcher := secrets.NewWatcher(provider)
+	ctx := context.Background()
 
-	if _, err := db.Initialize(config); err != nil {
+	// Initialize database. Rotated credentials are used for new connections,
+	// including the event listener's.
+	config := db.ConfigFromEnv()
+	var broker *events.PostgresBroker
+	err = secretWatcher.Watch(ctx, func(values map[string]string) error {
+		if broker == nil {
+			config.User, config.Password = values["DB_USER"], values["DB_PASSWORD"]
+			return nil
+		}
+		db.SetCredentials(values["DB_USER"], values["DB_PASSWORD"])
+		return broker.Reconnect(db.ConnString())
+	}, "DB_USER", "DB_PASSWORD")
+	if err != nil {
+		log.Fatalf("Failed to load database credentials: %v", err)
+	}
+	database, err := db.Initialize(config)
+	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
 
-	r := chi.NewRouter()
-	
-	allowedOrigins := strings.Split(os.Getenv("ALLOWED_ORIGINS"), ",")
-	
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   allowedOrigins,
-		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "If-None-Match"},
-		ExposedHeaders:   []string{"Link", "ETag"},
-		AllowCredentials: true,
-		MaxAge:           300,
-		AllowOriginFunc: func(r *http.Request, origin string) bool {
-			allowedOriginsMap := make(map[string]bool)
-			for _, o := range allowedOrigins {
-				allowedOriginsMap[strings.TrimSpace(o)] = true
-			}
-			return allowedOriginsMap[origin]
-		},
-	}))
+	// Change events go through Postgres so every replica sees every change
+	hub := events.NewHub()
+	broker, err = events.NewPostgresBroker(database, config.ConnString(), hub)
+	if err != nil {
+		log.Fatalf("Failed to start event listener: %v", err)
+	}
+	defer broker.Close()
 
-	r.Get("/healthcheck", handlers.HealthCheckHandler)
+	// Sign-in providers, e.g. OAUTH_PROVIDERS=google,github,microsoft. Each
+	// one's client credentials are the <NAME>_CLIENT_ID and
+	// <NAME>_CLIENT_SECRET secrets.
+	providerNames := os.Getenv("OAUTH_PROVIDERS")
+	if providerNames == "" {
+		providerNames = "google"
+	}
+	var providers []oauth.Provider
+	for _, name := range strings.Split(providerNames, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		provider, err := oauth.NewProvider(oauth.ConfigFromEnv(name))
+		if err != nil {
+			log.Fatalf("Failed to configure identity provider: %v", err)
+		}
+		clientID, clientSecret := oauth.EnvPrefix(name)+"CLIENT_ID", oauth.EnvPrefix(name)+"CLIENT_SECRET"
+		err = secretWatcher.Watch(ctx, func(values map[string]string) error {
+			provider.SetCredentials(values[clientID], values[clientSecret])
+			return nil
+		}, clientID, clientSecret)
+		if err != nil {
+			log.Fatalf("Failed to load %s OAuth credentials: %v", name, err)
+		}
+		providers = append(providers, provider)
+	}
 
-	r.Route("/api/auth", func(r chi.Router) {
-		r.Get("/callback", handlers.CallbackHandler)
-		r.Get("/logout", handlers.LogoutHandler)
-	})
+	// Invitations are emailed through the SMTP server at SMTP_ADDR, signing
+	// in with the SMTP_USERNAME and SMTP_PASSWORD secrets. Without one they
+	// are only logged.
+	var mailer mail.Sender = mail.Log{}
+	if mailConfig := mail.ConfigFromEnv(); mailConfig.Addr != "" {
+		smtpSender := mail.NewSMTP(mailConfig)
+		err = secretWatcher.Watch(ctx, func(values map[string]string) error {
+			smtpSender.SetCredentials(values["SMTP_USERNAME"], values["SMTP_PASSWORD"])
+			return nil
+		}, "SMTP_USERNAME", "SMTP_PASSWORD")
+		if err != nil {
+			log.Fatalf("Failed to load SMTP credentials: %v", err)
+		}
+		mailer = smtpSender
+	}
+	invitationURL := os.Getenv("INVITATION_URL")
+	if invitationURL == "" {
+		invitationURL = strings.TrimRight(os.Getenv("PUBLIC_URL"), "/") + "/invitations/"
+	}
 
-	r.Group(func(r chi.Router) {
-		r.Use(middleware.ValidateJWT)
+	// Access tokens are signed with rotating keys from the key directory
+	keyConfig, err := jwtkeys.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load signing key config: %v", err)
+	}
+	if keyConfig.Overlap < middleware.AccessTokenTTL {
+		keyConfig.Overlap = middleware.AccessTokenTTL
+	}
 
-		r.Route("/api/items", func(r chi.Router) {
-			r.Get("/", handlers.GetItemsHandler)
-			r.Post("/", handlers.CreateItemHandler)
-			
-			// Routes that need item_id
-			r.Group(func(r chi.Router) {
-				r.With(middleware.Authorize(db.DB(), "can_view")).Get("/{item_id}", handlers.GetItemByIDHandler)
-				r.With(middleware.Authorize(db.DB(), "can_edit")).Put("/{item_id}", handlers.EditItemHandler)
-				r.With(middleware.Authorize(db.DB(), "can_edit")).Delete("/{item_id}", handlers.DeleteItemHandler)
-				r.With(middleware.Authorize(db.DB(), "can_edit")).Post("/{item_id}/share", handlers.ShareItemHandler)
+	// A JWT_SIGNING_KEY secret replaces generated keys: changing the secret
+	// rotates the key, with the same overlap as a generated one
+	var keys *jwtkeys.KeyRing
+	if _, err := provider.Get(ctx, "JWT_SIGNING_KEY"); err == nil {
+		keyConfig.RotateEvery = 0
+		err = secretWatcher.Watch(ctx, func(values map[string]string) error {
+			if _, err := jwtkeys.Import(keyConfig.Dir, []byte(values["JWT_SIGNING_KEY"])); err != nil {
+				return err
+			}
+			if keys == nil {
+				return nil
+			}
+			return keys.Refresh()
+		}, "JWT_SIGNING_KEY")
+		if err != nil {
+			log.Fatalf("Failed to import signing key: %v", err)
+		}
+	} else if !errors.Is(err, secrets.ErrNotFound) {
+		log.Fatalf("Failed to load signing key: %v", err)
+	}
+	keys, err = jwtkeys.Open(keyConfig)
+	if err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+	go keys.Run(time.Minute, nil)
+	go secretWatcher.Run(refreshInterval, nil)
+
+	accessTokens := &middleware.AccessTokens{
+		Keys:     keys,
+		Issuer:   os.Getenv("JWT_ISSUER"),
+		Audience: os.Getenv("JWT_AUDIENCE"),
+	}
+	if accessTokens.Issuer == "" {
+		accessTokens.Issuer = "todo-api"
+	}
+	if accessTokens.Audience == "" {
+		accessTokens.Audience = "todo-api"
+	}
+
+	registry := oauth.NewRegistry(providers...)
+	pg := store.NewPostgres(database)
+	// Scripts can use personal access tokens in place of an access token
+	accessTokens.PersonalTokens = pg
+	insecureCookies := os.Getenv("INSECURE_COOKIES") == "true"
+	// Sign-in state cookies are signed with the access token keys
+	states := &oauth.States{Keys: keys, Used: pg, InsecureCookies: insecureCookies}
+	// Revoked sessions are noticed within 30 seconds on other replicas, and
+	// immediately on the one that revoked them
+	revocations := middleware.NewRevocationCache(pg, 30*time.Second)
+	authHandler := &handlers.AuthHandler{
+		Providers:       registry,
+		States:          states,
+		Users:           pg,
+		Identities:      pg,
+		Sessions:        pg,
+		Tokens:          pg,
+		Invitations:     pg,
+		AccessTokens:    accessTokens,
+		Revocations:     revocations,
+		InsecureCookies: insecureCookies,
+	}
+	itemHandler := &handlers.ItemHandler{
+		Items:         pg,
+		Shares:        pg,
+		Transfers:     pg,
+		Invitations:   pg,
+		Roles:         pg,
+		Users:         pg,
+		Identities:    pg,
+		Events:        broker,
+		Types:         itemtypes.NewRegistry(pg),
+		Mail:          mailer,
+		InvitationURL: invitationURL,
+	}
+	todoHandler := &handlers.TodoHandler{
+		Todos:          pg,
+		Shares:         pg,
+		Events:         broker,
+		RequireIfMatch: os.Getenv("REQUIRE_IF_MATCH") == "true",
+	}
+	userHandler := &handlers.UserHandler{
+		Users:          pg,
+		Identities:     pg,
+		PersonalTokens: pg,
+		Providers:      registry,
+		States:         states,
+	}
+	shareLinkHandler := &handlers.ShareLinkHandler{Links: pg, Items: pg, Todos: pg, Shares: pg, Roles: pg}
+	roleHandler := &handlers.RoleHandler{Roles: pg}
+	searchHandler := &handlers.SearchHandler{Search: pg}
+	docHandler := &handlers.DocHandler{Docs: pg, Shares: pg, Events: broker}
+	itemTypeHandler := &handlers.ItemTypeHandler{Types: pg}
 
-				// Todos routes
-				r.Route("/{item_id}/todos", func(r chi.Router) {
-					r.With(middleware.Authorize(db.DB(), "can_view")).Get("/", handlers.GetTodosHandler)
-					r.With(middleware.Authorize(db.DB(), "can_edit")).Post("/", handlers.CreateTodoHandler)
-					r.With(middleware.Authorize(db.DB(), "can_view")).Get("/{todo_id}", handlers.GetTodoByIDHandler)
-					r.With(middleware.Authorize(db.DB(), "can_edit")).Put("/{todo_id}", handlers.EditTodoHandler)
-					r.With(middleware.Authorize(db.DB(), "can_edit")).Patch("/{todo_id}/done", handlers.MarkTodoDoneHandler)
-					r.With(middleware.Authorize(db.DB(), "can_edit")).Delete("/{todo_id}", handlers.DeleteTodoHandler)
-				})
-			})
-		})
+	allowedOrigins := strings.Split(os.Getenv("ALLOWED_ORIGINS"), ",")
+	allowedOriginsMap := originSet(allowedOrigins)
+	feedHandler := &handlers.FeedHandler{
+		Hub:    hub,
+		Log:    broker,
+		Shares: pg,
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || allowedOriginsMap[origin]
+		},
+	}
 
-		// Add users endpoints
-		r.Route("/api/users", func(r chi.Router) {
-			r.Get("/lookup", handlers.LookupUserHandler)
-			r.Get("/me", handlers.GetCurrentUserHandler)
-		})
+	r := routes(routeDeps{
+		Auth:           authHandler,
+		Items:          itemHandler,
+		Todos:          todoHandler,
+		Users:          userHandler,
+		ShareLinks:     shareLinkHandler,
+		Roles:          roleHandler,
+		Search:         searchHandler,
+		Docs:           docHandler,
+		ItemTypes:      itemTypeHandler,
+		Feed:           feedHandler,
+		Store:          pg,
+		AccessTokens:   accessTokens,
+		Revocations:    revocations,
+		AllowedOrigins: allowedOrigins,
 	})
 
 	log.Fatal(http.ListenAndServe(":4000", r))
-}
\ No newline at end of file
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/cors"
+	"github.com/onyeepeace/todo-api/internal/handlers"
+	"github.com/onyeepeace/todo-api/internal/middleware"
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+// routeStore answers the checks the middleware makes before a handler runs
+type routeStore interface {
+	middleware.PermissionChecker
+	middleware.ItemGetter
+	middleware.AdminChecker
+	middleware.ShareLinkChecker
+}
+
+// routeDeps is everything the API's routes are served by
+type routeDeps struct {
+	Auth       *handlers.AuthHandler
+	Items      *handlers.ItemHandler
+	Todos      *handlers.TodoHandler
+	Users      *handlers.UserHandler
+	ShareLinks *handlers.ShareLinkHandler
+	Roles      *handlers.RoleHandler
+	Search     *handlers.SearchHandler
+	Docs       *handlers.DocHandler
+	ItemTypes  *handlers.ItemTypeHandler
+	Feed       *handlers.FeedHandler
+
+	Store          routeStore
+	AccessTokens   *middleware.AccessTokens
+	Revocations    *middleware.RevocationCache
+	AllowedOrigins []string
+}
+
+// originSet turns ALLOWED_ORIGINS into a set to look origins up in
+func originSet(origins []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, o := range origins {
+		set[strings.TrimSpace(o)] = true
+	}
+	return set
+}
+
+// routes builds the API's router
+func routes(d routeDeps) chi.Router {
+	// Some item routes only exist for one type of item
+	notesOnly := middleware.RequireItemType(d.Store, models.ItemTypeNote)
+	todoListsOnly := middleware.RequireItemType(d.Store, models.ItemTypeTodoList)
+
+	r := chi.NewRouter()
+
+	allowedOriginsMap := originSet(d.AllowedOrigins)
+	r.Use(cors.Handler(cors.Options{
+		AllowedOrigins:   d.AllowedOrigins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "If-None-Match", middleware.ShareLinkPasswordHeader},
+		ExposedHeaders:   []string{"Link", "ETag"},
+		AllowCredentials: true,
+		MaxAge:           300,
+		AllowOriginFunc: func(r *http.Request, origin string) bool {
+			return allowedOriginsMap[origin]
+		},
+	}))
+
+	r.Get("/healthcheck", handlers.HealthCheckHandler)
+	r.Get("/.well-known/jwks.json", d.Auth.JWKSHandler)
+
+	r.Route("/api/auth", func(r chi.Router) {
+		r.Get("/providers", d.Auth.ProvidersHandler)
+		r.Get("/{provider}/login", d.Auth.LoginHandler)
+		r.Get("/{provider}/callback", d.Auth.CallbackHandler)
+		r.Post("/refresh", d.Auth.RefreshHandler)
+		r.Post("/revoke", d.Auth.RevokeHandler)
+	})
+
+	// Declining an invitation only needs its token, so people without an
+	// account can turn it down
+	r.Post("/api/invitations/decline", d.Items.DeclineInvitationHandler)
+
+	// Share links work without signing in, as far as their role allows
+	r.With(middleware.AuthorizeShareLink(d.Store, "can_view")).Get("/api/shared/{token}", d.ShareLinks.GetSharedItemHandler)
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.ValidateJWT(d.AccessTokens, d.Revocations))
+
+		r.With(middleware.RequireSession).Post("/api/auth/logout", d.Auth.LogoutHandler)
+		r.With(middleware.RequireSession).Post("/api/auth/logout-all", d.Auth.LogoutAllHandler)
+
+		// Personal access tokens can only read or change items if they were
+		// granted items:read or items:write
+		itemScopes := middleware.RequireReadWriteScope(models.ScopeItemsRead, models.ScopeItemsWrite)
+
+		r.Route("/api/items", func(r chi.Router) {
+			r.Use(itemScopes)
+			r.Get("/", d.Items.GetItemsHandler)
+			r.Post("/", d.Items.CreateItemHandler)
+
+			// Routes that need item_id
+			r.Group(func(r chi.Router) {
+				r.With(middleware.Authorize(d.Store, "can_view")).Get("/{item_id}", d.Items.GetItemByIDHandler)
+				r.With(middleware.Authorize(d.Store, "can_edit")).Put("/{item_id}", d.Items.EditItemHandler)
+				r.With(middleware.Authorize(d.Store, "can_edit")).Patch("/{item_id}", d.Items.PatchItemHandler)
+				r.With(middleware.Authorize(d.Store, "can_delete")).Delete("/{item_id}", d.Items.DeleteItemHandler)
+				r.With(middleware.Authorize(d.Store, "can_share")).Post("/{item_id}/share", d.Items.ShareItemHandler)
+				r.With(middleware.Authorize(d.Store, "can_view")).Get("/{item_id}/collaborators", d.Items.ListCollaboratorsHandler)
+				r.With(middleware.Authorize(d.Store, "can_share")).Patch("/{item_id}/collaborators/{user_id}", d.Items.UpdateCollaboratorHandler)
+				r.With(middleware.Authorize(d.Store, "can_share")).Delete("/{item_id}/collaborators/{user_id}", d.Items.RemoveCollaboratorHandler)
+				r.With(middleware.Authorize(d.Store, "can_view")).Post("/{item_id}/leave", d.Items.LeaveItemHandler)
+
+				// Sharing by email, with people who may not have signed up
+				r.With(middleware.Authorize(d.Store, "can_share")).Post("/{item_id}/invitations", d.Items.InviteHandler)
+				r.With(middleware.Authorize(d.Store, "can_share")).Get("/{item_id}/invitations", d.Items.ListInvitationsHandler)
+				r.With(middleware.Authorize(d.Store, "can_share")).Delete("/{item_id}/invitations/{invitation_id}", d.Items.RevokeInvitationHandler)
+				r.With(middleware.Authorize(d.Store, "can_share")).Post("/{item_id}/share-links", d.ShareLinks.CreateShareLinkHandler)
+				r.With(middleware.Authorize(d.Store, "can_share")).Get("/{item_id}/share-links", d.ShareLinks.ListShareLinksHandler)
+				r.With(middleware.Authorize(d.Store, "can_share")).Delete("/{item_id}/share-links/{link_id}", d.ShareLinks.RevokeShareLinkHandler)
+
+				// Handing the item to a new owner. The recipient may not have
+				// a role on the item yet, so accepting and declining check
+				// the offer instead.
+				r.With(middleware.Authorize(d.Store, "can_view")).Get("/{item_id}/transfer", d.Items.GetTransferHandler)
+				r.With(middleware.Authorize(d.Store, "can_share")).Post("/{item_id}/transfer", d.Items.OfferTransferHandler)
+				r.With(middleware.Authorize(d.Store, "can_share")).Delete("/{item_id}/transfer", d.Items.CancelTransferHandler)
+				r.Post("/{item_id}/transfer/accept", d.Items.AcceptTransferHandler)
+				r.Post("/{item_id}/transfer/decline", d.Items.DeclineTransferHandler)
+				r.With(middleware.Authorize(d.Store, "can_view")).Get("/{item_id}/ws", d.Feed.ItemWebSocketHandler)
+
+				// Collaborative editing of the item's text
+				r.With(middleware.Authorize(d.Store, "can_view"), notesOnly).Get("/{item_id}/doc", d.Docs.GetDocHandler)
+				r.With(middleware.Authorize(d.Store, "can_view"), notesOnly).Get("/{item_id}/doc/updates", d.Docs.GetDocUpdatesHandler)
+				r.With(middleware.Authorize(d.Store, "can_edit"), notesOnly).Post("/{item_id}/doc/updates", d.Docs.UpdateDocHandler)
+
+				// Todos routes
+				r.Route("/{item_id}/todos", func(r chi.Router) {
+					r.With(middleware.Authorize(d.Store, "can_view"), todoListsOnly).Get("/", d.Todos.GetTodosHandler)
+					r.With(middleware.Authorize(d.Store, "can_edit"), todoListsOnly).Post("/", d.Todos.CreateTodoHandler)
+					r.With(middleware.Authorize(d.Store, "can_view"), todoListsOnly).Get("/{todo_id}", d.Todos.GetTodoByIDHandler)
+					r.With(middleware.Authorize(d.Store, "can_edit"), todoListsOnly).Put("/{todo_id}", d.Todos.EditTodoHandler)
+					r.With(middleware.Authorize(d.Store, "can_complete"), todoListsOnly).Patch("/{todo_id}/done", d.Todos.MarkTodoDoneHandler)
+					r.With(middleware.Authorize(d.Store, "can_edit"), todoListsOnly).Delete("/{todo_id}", d.Todos.DeleteTodoHandler)
+				})
+			})
+		})
+
+		r.Route("/api/item-types", func(r chi.Router) {
+			r.Use(itemScopes)
+			r.Get("/", d.ItemTypes.ListItemTypesHandler)
+			// Types are shared by everyone, so only admins can add them
+			r.With(middleware.RequireSession, middleware.RequireAdmin(d.Store)).Post("/", d.ItemTypes.CreateItemTypeHandler)
+			r.Get("/{name}", d.ItemTypes.GetItemTypeHandler)
+		})
+
+		// Anyone can see which roles there are to share with; only admins
+		// can define new ones
+		r.Route("/api/roles", func(r chi.Router) {
+			r.With(itemScopes).Get("/", d.Roles.ListRolesHandler)
+			r.With(itemScopes).Get("/{name}", d.Roles.GetRoleHandler)
+
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireSession, middleware.RequireAdmin(d.Store))
+				r.Post("/", d.Roles.CreateRoleHandler)
+				r.Delete("/{name}", d.Roles.DeleteRoleHandler)
+				r.Put("/{name}/permissions/{permission}", d.Roles.AddRolePermissionHandler)
+				r.Delete("/{name}/permissions/{permission}", d.Roles.RemoveRolePermissionHandler)
+			})
+		})
+		r.With(itemScopes).Get("/api/permissions", d.Roles.ListPermissionsHandler)
+
+		r.With(itemScopes).Get("/api/search", d.Search.SearchHandler)
+		r.With(itemScopes).Get("/api/events", d.Feed.EventStreamHandler)
+		r.With(itemScopes).Post("/api/invitations/accept", d.Items.AcceptInvitationHandler)
+		r.With(itemScopes, middleware.AuthorizeShareLink(d.Store, "can_view")).Post("/api/shared/{token}/join", d.ShareLinks.JoinSharedItemHandler)
+
+		// Add users endpoints
+		r.Route("/api/users", func(r chi.Router) {
+			r.With(middleware.RequireScope(models.ScopeUsersRead)).Get("/lookup", d.Users.LookupUserHandler)
+			r.With(middleware.RequireScope(models.ScopeUsersRead)).Get("/me", d.Users.GetCurrentUserHandler)
+			r.With(middleware.RequireScope(models.ScopeItemsRead)).Get("/me/transfers", d.Items.ListIncomingTransfersHandler)
+
+			// Managing how the account signs in needs a real sign-in, so a
+			// leaked personal access token can't mint more or take over
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireSession)
+				r.Get("/me/identities", d.Users.ListIdentitiesHandler)
+				r.Post("/me/identities", d.Users.LinkIdentityHandler)
+				r.Delete("/me/identities/{identity_id}", d.Users.UnlinkIdentityHandler)
+				r.Get("/me/tokens", d.Users.ListPersonalTokensHandler)
+				r.Post("/me/tokens", d.Users.CreatePersonalTokenHandler)
+				r.Delete("/me/tokens/{token_id}", d.Users.RevokePersonalTokenHandler)
+			})
+		})
+	})
+
+	return r
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/events"
+	"github.com/onyeepeace/todo-api/internal/handlers"
+	"github.com/onyeepeace/todo-api/internal/itemtypes"
+	"github.com/onyeepeace/todo-api/internal/jwtkeys"
+	"github.com/onyeepeace/todo-api/internal/mail"
+	"github.com/onyeepeace/todo-api/internal/middleware"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+// itemPermissions is the permission each /api/items/{item_id} route needs.
+// An empty permission means the handler decides.
+var itemPermissions = map[string]string{
+	"GET /api/items/{item_id}":                                "can_view",
+	"PUT /api/items/{item_id}":                                "can_edit",
+	"PATCH /api/items/{item_id}":                              "can_edit",
+	"DELETE /api/items/{item_id}":                             "can_delete",
+	"POST /api/items/{item_id}/share":                         "can_share",
+	"GET /api/items/{item_id}/collaborators":                  "can_view",
+	"PATCH /api/items/{item_id}/collaborators/{user_id}":      "can_share",
+	"DELETE /api/items/{item_id}/collaborators/{user_id}":     "can_share",
+	"POST /api/items/{item_id}/leave":                         "can_view",
+	"POST /api/items/{item_id}/invitations":                   "can_share",
+	"GET /api/items/{item_id}/invitations":                    "can_share",
+	"DELETE /api/items/{item_id}/invitations/{invitation_id}": "can_share",
+	"POST /api/items/{item_id}/share-links":                   "can_share",
+	"GET /api/items/{item_id}/share-links":                    "can_share",
+	"DELETE /api/items/{item_id}/share-links/{link_id}":       "can_share",
+	"GET /api/items/{item_id}/transfer":                       "can_view",
+	"POST /api/items/{item_id}/transfer":                      "can_share",
+	"DELETE /api/items/{item_id}/transfer":                    "can_share",
+	"POST /api/items/{item_id}/transfer/accept":               "",
+	"POST /api/items/{item_id}/transfer/decline":              "",
+	"GET /api/items/{item_id}/ws":                             "can_view",
+	"GET /api/items/{item_id}/doc":                            "can_view",
+	"GET /api/items/{item_id}/doc/updates":                    "can_view",
+	"POST /api/items/{item_id}/doc/updates":                   "can_edit",
+	"GET /api/items/{item_id}/todos/":                         "can_view",
+	"POST /api/items/{item_id}/todos/":                        "can_edit",
+	"GET /api/items/{item_id}/todos/{todo_id}":                "can_view",
+	"PUT /api/items/{item_id}/todos/{todo_id}":                "can_edit",
+	"PATCH /api/items/{item_id}/todos/{todo_id}/done":         "can_complete",
+	"DELETE /api/items/{item_id}/todos/{todo_id}":             "can_edit",
+}
+
+// newTestRouter returns the server's router on an in-memory store, with
+// access tokens signed by keys in a temporary directory
+func newTestRouter(t *testing.T, m *store.Memory, keys *jwtkeys.KeyRing) (chi.Router, *middleware.AccessTokens) {
+	t.Helper()
+	broker := events.NewMemoryBroker(events.NewHub())
+	accessTokens := &middleware.AccessTokens{Keys: keys, Issuer: "todo-api", Audience: "todo-api", PersonalTokens: m}
+	return routes(routeDeps{
+		Auth: &handlers.AuthHandler{Users: m, Identities: m, Sessions: m, Tokens: m, Invitations: m, AccessTokens: accessTokens},
+		Items: &handlers.ItemHandler{
+			Items:       m,
+			Shares:      m,
+			Transfers:   m,
+			Invitations: m,
+			Roles:       m,
+			Users:       m,
+			Identities:  m,
+			Events:      broker,
+			Types:       itemtypes.NewRegistry(m),
+			Mail:        mail.Log{},
+		},
+		Todos:        &handlers.TodoHandler{Todos: m, Shares: m, Events: broker},
+		Users:        &handlers.UserHandler{Users: m, Identities: m, PersonalTokens: m},
+		ShareLinks:   &handlers.ShareLinkHandler{Links: m, Items: m, Todos: m, Shares: m, Roles: m},
+		Roles:        &handlers.RoleHandler{Roles: m},
+		Docs:         &handlers.DocHandler{Docs: m, Shares: m, Events: broker},
+		ItemTypes:    &handlers.ItemTypeHandler{Types: m},
+		Feed:         &handlers.FeedHandler{Hub: events.NewHub(), Log: broker, Shares: m},
+		Store:        m,
+		AccessTokens: accessTokens,
+	}), accessTokens
+}
+
+// signIn starts a session for the user and returns an access token for it
+func signIn(t *testing.T, m *store.Memory, accessTokens *middleware.AccessTokens, userID int) string {
+	t.Helper()
+	session := models.Session{SessionID: "session-" + strconv.Itoa(userID), UserID: userID, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := m.CreateSession(context.Background(), &session); err != nil {
+		t.Fatal(err)
+	}
+	token, err := accessTokens.GenerateJWT(userID, session.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// newTestUser adds a user to the store and returns their ID
+func newTestUser(t *testing.T, m *store.Memory, name string) int {
+	t.Helper()
+	user := models.User{Email: name + "@example.com", Username: name}
+	identity := models.Identity{Provider: "test", Subject: name, Email: user.Email, EmailVerified: true}
+	if err := m.CreateUserWithIdentity(context.Background(), &user, &identity); err != nil {
+		t.Fatalf("creating user %s: %v", name, err)
+	}
+	return user.UserID
+}
+
+func TestItemRoutesAreAuthorized(t *testing.T) {
+	keys, err := jwtkeys.Open(jwtkeys.Config{Dir: t.TempDir(), Algorithm: jwtkeys.EdDSA, RotateEvery: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every item route must be in itemPermissions, so a new one can't go
+	// unchecked
+	router, _ := newTestRouter(t, store.NewMemory(), keys)
+	var itemRoutes []string
+	chi.Walk(router, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, "/api/items/{item_id}") {
+			itemRoutes = append(itemRoutes, method+" "+route)
+		}
+		return nil
+	})
+	for _, route := range itemRoutes {
+		if _, ok := itemPermissions[route]; !ok {
+			t.Errorf("%s isn't in itemPermissions", route)
+		}
+	}
+	if len(itemRoutes) != len(itemPermissions) {
+		t.Errorf("router has %d item routes, itemPermissions has %d", len(itemRoutes), len(itemPermissions))
+	}
+
+	roles := []struct {
+		name        string
+		permissions []string
+	}{
+		{"owner", []string{"can_view", "can_edit", "can_complete", "can_share", "can_delete"}},
+		{"editor", []string{"can_view", "can_edit", "can_complete"}},
+		{"viewer", []string{"can_view"}},
+		{"completer", []string{"can_view", "can_complete"}},
+		{"", nil},
+	}
+	for _, role := range roles {
+		name := role.name
+		if name == "" {
+			name = "no role"
+		}
+		granted := map[string]bool{"": true}
+		for _, permission := range role.permissions {
+			granted[permission] = true
+		}
+
+		for _, route := range itemRoutes {
+			t.Run(name+"/"+route, func(t *testing.T) {
+				// Each request gets its own store, since granted ones go on
+				// to change it
+				ctx := context.Background()
+				m := store.NewMemory()
+				router, accessTokens := newTestRouter(t, m, keys)
+				owner := newTestUser(t, m, "owner")
+				user := owner
+				if role.name != "owner" {
+					user = newTestUser(t, m, "user")
+				}
+				if role.name == "completer" {
+					custom := models.Role{Name: role.name, Permissions: role.permissions}
+					if err := m.CreateRole(ctx, &custom); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				method, pattern, _ := strings.Cut(route, " ")
+				itemType, content := models.ItemTypeTodoList, `[]`
+				if strings.Contains(pattern, "/doc") {
+					itemType, content = models.ItemTypeNote, `{"text": ""}`
+				}
+				item := models.Item{Name: itemType, ItemType: itemType, Content: json.RawMessage(content)}
+				if err := m.CreateItem(ctx, &item, owner); err != nil {
+					t.Fatal(err)
+				}
+				if role.name != "owner" && role.name != "" {
+					if err := m.ShareItem(ctx, item.ItemID, user, role.name, owner); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				path := strings.NewReplacer("{item_id}", strconv.Itoa(item.ItemID), "{user_id}", "99", "{invitation_id}", "1", "{link_id}", "1", "{todo_id}", "1").Replace(pattern)
+				r := httptest.NewRequest(method, path, strings.NewReader("{}"))
+				r.Header.Set("Authorization", "Bearer "+signIn(t, m, accessTokens, user))
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, r)
+
+				permission := itemPermissions[route]
+				forbidden := w.Code == http.StatusForbidden && strings.HasPrefix(w.Body.String(), "Forbidden - your role on this item doesn't grant "+permission)
+				if forbidden == granted[permission] {
+					t.Errorf("needs %q: got %d %s", permission, w.Code, strings.TrimSpace(w.Body.String()))
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,276 @@
+// Package crdt implements a Replicated Growable Array (RGA) text document.
+//
+// Every inserted atom (usually one character) gets a globally unique ID made
+// of a Lamport clock and the ID of the site (client) that created it. An
+// insert names the atom it goes after; concurrent inserts after the same atom
+// are ordered by ID, highest first, so every replica that has seen the same
+// set of operations ends up with the same text no matter the order they
+// arrived in. Deletes only mark atoms as tombstones so later inserts can
+// still refer to them.
+//
+// Tombstones are never removed. Doing so safely needs every site to have
+// seen the delete and everything concurrent with it, and the server doesn't
+// know which sites are still out there, so a document grows with every
+// character ever typed into it.
+package crdt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	OpInsert = "ins"
+	OpDelete = "del"
+)
+
+var ErrInvalidOp = errors.New("invalid operation")
+
+// ID identifies an atom. The zero ID stands for the start of the document.
+type ID struct {
+	Clock uint64 `json:"c"`
+	Site  string `json:"s"`
+}
+
+// IsZero reports whether id refers to the start of the document
+func (id ID) IsZero() bool {
+	return id.Clock == 0 && id.Site == ""
+}
+
+// Less orders IDs by clock, breaking ties by site
+func (id ID) Less(other ID) bool {
+	if id.Clock != other.Clock {
+		return id.Clock < other.Clock
+	}
+	return id.Site < other.Site
+}
+
+func (id ID) String() string {
+	return fmt.Sprintf("%d@%s", id.Clock, id.Site)
+}
+
+// Op is a single insert or delete
+type Op struct {
+	Type string `json:"type"`
+	// ID is the new atom for inserts and the atom to remove for deletes
+	ID ID `json:"id"`
+	// After is the atom an insert goes after, zero for the start
+	After ID     `json:"after"`
+	Text  string `json:"text,omitempty"`
+}
+
+// Update is a batch of operations made by one site
+type Update struct {
+	Site string `json:"site"`
+	Ops  []Op   `json:"ops"`
+}
+
+// Validate checks that the update is well formed
+func (u Update) Validate() error {
+	if u.Site == "" {
+		return fmt.Errorf("%w: site is required", ErrInvalidOp)
+	}
+	if len(u.Ops) == 0 {
+		return fmt.Errorf("%w: update has no ops", ErrInvalidOp)
+	}
+	for i, op := range u.Ops {
+		if op.ID.Clock == 0 || op.ID.Site == "" {
+			return fmt.Errorf("%w: op %d has no id", ErrInvalidOp, i)
+		}
+		switch op.Type {
+		case OpInsert:
+			if op.ID.Site != u.Site {
+				return fmt.Errorf("%w: op %d inserts as site %q", ErrInvalidOp, i, op.ID.Site)
+			}
+			if op.Text == "" {
+				return fmt.Errorf("%w: op %d inserts no text", ErrInvalidOp, i)
+			}
+			if !op.After.IsZero() && !op.After.Less(op.ID) {
+				return fmt.Errorf("%w: op %d must have a later clock than the atom it follows", ErrInvalidOp, i)
+			}
+		case OpDelete:
+		default:
+			return fmt.Errorf("%w: op %d has unknown type %q", ErrInvalidOp, i, op.Type)
+		}
+	}
+	return nil
+}
+
+type atom struct {
+	ID      ID     `json:"id"`
+	Text    string `json:"text"`
+	Deleted bool   `json:"deleted,omitempty"`
+	next    *atom
+}
+
+// Document is an RGA text document. It is not safe for concurrent use.
+type Document struct {
+	// head comes before the first atom. Atoms are a linked list so inserts
+	// don't move the atoms after them.
+	head atom
+	ids  map[ID]*atom
+	// pending holds ops whose dependencies have not arrived yet
+	pending []Op
+	// vector is the highest clock seen from each site
+	vector map[string]uint64
+}
+
+// New returns an empty document
+func New() *Document {
+	return &Document{
+		ids:    make(map[ID]*atom),
+		vector: make(map[string]uint64),
+	}
+}
+
+// FromText returns a document holding text as if site had typed it in one
+// go, one atom per character. The same text and site always give the same
+// IDs, so replicas seeding a document from the same text agree on it.
+func FromText(site, text string) *Document {
+	d := New()
+	if text == "" {
+		return d
+	}
+
+	update := Update{Site: site}
+	var after ID
+	for _, r := range text {
+		id := ID{Clock: after.Clock + 1, Site: site}
+		update.Ops = append(update.Ops, Op{Type: OpInsert, ID: id, After: after, Text: string(r)})
+		after = id
+	}
+	d.Apply(update)
+	return d
+}
+
+// Apply integrates an update. Applying the same update twice is harmless.
+func (d *Document) Apply(u Update) error {
+	if err := u.Validate(); err != nil {
+		return err
+	}
+
+	queue := append(d.pending, u.Ops...)
+	d.pending = nil
+
+	// Keep integrating until a pass makes no progress; whatever is left is
+	// waiting on operations we haven't seen
+	for {
+		var waiting []Op
+		for _, op := range queue {
+			if !d.integrate(op) {
+				waiting = append(waiting, op)
+			}
+		}
+		if len(waiting) == len(queue) {
+			d.pending = waiting
+			return nil
+		}
+		queue = waiting
+	}
+}
+
+// integrate applies one op, returning false if it depends on an unseen atom
+func (d *Document) integrate(op Op) bool {
+	switch op.Type {
+	case OpInsert:
+		if d.ids[op.ID] != nil {
+			return true
+		}
+
+		prev := &d.head
+		if !op.After.IsZero() {
+			prev = d.ids[op.After]
+			if prev == nil {
+				return false
+			}
+		}
+
+		// Concurrent inserts after the same atom go in descending ID order.
+		// Anything inserted after a higher ID atom has a higher clock still,
+		// so skipping every greater ID also skips their descendants.
+		for prev.next != nil && op.ID.Less(prev.next.ID) {
+			prev = prev.next
+		}
+
+		a := &atom{ID: op.ID, Text: op.Text, next: prev.next}
+		prev.next = a
+		d.ids[op.ID] = a
+
+	case OpDelete:
+		a := d.ids[op.ID]
+		if a == nil {
+			return false
+		}
+		a.Deleted = true
+	}
+
+	if op.Type == OpInsert && op.ID.Clock > d.vector[op.ID.Site] {
+		d.vector[op.ID.Site] = op.ID.Clock
+	}
+	return true
+}
+
+// Text returns the visible document
+func (d *Document) Text() string {
+	var b strings.Builder
+	for a := d.head.next; a != nil; a = a.next {
+		if !a.Deleted {
+			b.WriteString(a.Text)
+		}
+	}
+	return b.String()
+}
+
+// Vector returns the highest clock seen from each site. A client should use
+// a clock greater than every value here for its next insert.
+func (d *Document) Vector() map[string]uint64 {
+	v := make(map[string]uint64, len(d.vector))
+	for site, clock := range d.vector {
+		v[site] = clock
+	}
+	return v
+}
+
+// Pending returns how many operations are waiting on missing dependencies
+func (d *Document) Pending() int {
+	return len(d.pending)
+}
+
+type snapshot struct {
+	Atoms   []atom            `json:"atoms"`
+	Pending []Op              `json:"pending,omitempty"`
+	Vector  map[string]uint64 `json:"vector"`
+}
+
+// MarshalJSON encodes the full document state as a snapshot
+func (d *Document) MarshalJSON() ([]byte, error) {
+	atoms := make([]atom, 0, len(d.ids))
+	for a := d.head.next; a != nil; a = a.next {
+		atoms = append(atoms, *a)
+	}
+	return json.Marshal(snapshot{Atoms: atoms, Pending: d.pending, Vector: d.vector})
+}
+
+// UnmarshalJSON restores a document from a snapshot
+func (d *Document) UnmarshalJSON(data []byte) error {
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	*d = *New()
+	d.pending = s.Pending
+	prev := &d.head
+	for i := range s.Atoms {
+		a := &s.Atoms[i]
+		prev.next = a
+		d.ids[a.ID] = a
+		prev = a
+	}
+	for site, clock := range s.Vector {
+		d.vector[site] = clock
+	}
+	return nil
+}
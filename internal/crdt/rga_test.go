@@ -0,0 +1,209 @@
+package crdt
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+)
+
+func id(site string, clock uint64) ID {
+	return ID{Clock: clock, Site: site}
+}
+
+// typed is site typing text after the atom after, one atom per character,
+// with clocks counting up from clock
+func typed(site string, clock uint64, after ID, text string) []Update {
+	var updates []Update
+	for _, r := range text {
+		op := Op{Type: OpInsert, ID: id(site, clock), After: after, Text: string(r)}
+		updates = append(updates, Update{Site: site, Ops: []Op{op}})
+		after = op.ID
+		clock++
+	}
+	return updates
+}
+
+func deleted(site string, ids ...ID) []Update {
+	var updates []Update
+	for _, atomID := range ids {
+		updates = append(updates, Update{Site: site, Ops: []Op{{Type: OpDelete, ID: atomID}}})
+	}
+	return updates
+}
+
+func concat(updates ...[]Update) []Update {
+	var all []Update
+	for _, u := range updates {
+		all = append(all, u...)
+	}
+	return all
+}
+
+// base is "ac" as typed by site server
+var base = typed("server", 1, ID{}, "ac")
+
+var convergenceTests = []struct {
+	name    string
+	updates []Update
+	want    string
+}{
+	{
+		name:    "concurrent typing at the start",
+		updates: concat(typed("alice", 1, ID{}, "hello"), typed("bob", 1, ID{}, "world")),
+		// bob's first atom has the higher ID, and the rest of each word
+		// follows its first atom
+		want: "worldhello",
+	},
+	{
+		name: "concurrent inserts after the same atom",
+		updates: concat(base,
+			typed("alice", 3, id("server", 1), "b"),
+			typed("carol", 3, id("server", 1), "X"),
+			typed("bob", 3, id("server", 1), "YZ"),
+		),
+		want: "aXYZbc",
+	},
+	{
+		name: "insert after a deleted atom",
+		updates: concat(base,
+			deleted("alice", id("server", 2)),
+			typed("bob", 3, id("server", 2), "d"),
+		),
+		want: "ad",
+	},
+	{
+		name: "concurrent deletes of the same atom",
+		updates: concat(base,
+			deleted("alice", id("server", 1)),
+			deleted("bob", id("server", 1)),
+			typed("carol", 3, id("server", 1), "b"),
+		),
+		want: "bc",
+	},
+	{
+		name: "deleting concurrent inserts",
+		updates: concat(base,
+			typed("alice", 3, id("server", 1), "xy"),
+			typed("bob", 3, id("server", 2), "z"),
+			deleted("bob", id("alice", 3)),
+			deleted("alice", id("bob", 3), id("server", 2)),
+		),
+		want: "ay",
+	},
+}
+
+func TestConvergence(t *testing.T) {
+	for _, tt := range convergenceTests {
+		t.Run(tt.name, func(t *testing.T) {
+			for seed := int64(0); seed < 50; seed++ {
+				updates := append([]Update(nil), tt.updates...)
+				rand.New(rand.NewSource(seed)).Shuffle(len(updates), func(i, j int) {
+					updates[i], updates[j] = updates[j], updates[i]
+				})
+
+				d := New()
+				for _, u := range updates {
+					if err := d.Apply(u); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if got := d.Text(); got != tt.want || d.Pending() != 0 {
+					t.Fatalf("seed %d: got %q with %d pending, want %q", seed, got, d.Pending(), tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyIsIdempotent(t *testing.T) {
+	for _, tt := range convergenceTests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := New()
+			for _, u := range tt.updates {
+				for i := 0; i < 2; i++ {
+					if err := d.Apply(u); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			// Replaying everything, as a client that reconnects might
+			for _, u := range tt.updates {
+				if err := d.Apply(u); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := d.Text(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPendingOps(t *testing.T) {
+	d := New()
+	for _, u := range base {
+		d.Apply(u)
+	}
+
+	// bob's "xy" arrives backwards, and alice deletes the x before it does
+	xy := typed("bob", 3, id("server", 1), "xy")
+	steps := []struct {
+		update      Update
+		wantText    string
+		wantPending int
+	}{
+		{xy[1], "ac", 1},
+		{deleted("alice", id("bob", 3))[0], "ac", 2},
+		{xy[0], "ayc", 0},
+	}
+	for i, step := range steps {
+		if err := d.Apply(step.update); err != nil {
+			t.Fatal(err)
+		}
+		if d.Text() != step.wantText || d.Pending() != step.wantPending {
+			t.Errorf("step %d: got %q with %d pending, want %q with %d", i, d.Text(), d.Pending(), step.wantText, step.wantPending)
+		}
+	}
+	if clock := d.Vector()["bob"]; clock != 4 {
+		t.Errorf("bob's clock: got %d, want 4", clock)
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	d := New()
+	for _, u := range concat(base, typed("alice", 3, id("server", 2), "d")) {
+		d.Apply(u)
+	}
+	// Waiting on an insert that hasn't arrived
+	d.Apply(typed("bob", 5, id("carol", 4), "e")[0])
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := New()
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Text() != "acd" || restored.Pending() != 1 {
+		t.Fatalf("restored: got %q with %d pending", restored.Text(), restored.Pending())
+	}
+
+	// The restored document keeps integrating where the original left off
+	for _, u := range concat(typed("carol", 4, id("server", 1), "b"), deleted("alice", id("server", 2))) {
+		restored.Apply(u)
+	}
+	if restored.Text() != "abed" || restored.Pending() != 0 {
+		t.Errorf("after more updates: got %q with %d pending", restored.Text(), restored.Pending())
+	}
+}
+
+func TestFromTextIsDeterministic(t *testing.T) {
+	a, b := FromText("server", "héllo"), FromText("server", "héllo")
+	edit := typed("alice", 6, id("server", 5), "!")[0]
+	a.Apply(edit)
+	b.Apply(edit)
+	if a.Text() != "héllo!" || b.Text() != a.Text() {
+		t.Errorf("got %q and %q", a.Text(), b.Text())
+	}
+}
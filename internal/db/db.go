@@ -1,12 +1,17 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"log"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type Config struct {
@@ -20,26 +25,88 @@ type Config struct {
 
 var db *sql.DB
 
-// Initialize sets up the database connection and creates tables
-func Initialize(config Config) (*sql.DB, error) {
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found")
+var connector *credentialConnector
+
+// credentialConnector opens every new connection with the latest
+// credentials, so they can be rotated while the pool is in use
+type credentialConnector struct {
+	mu     sync.Mutex
+	config Config
+}
+
+func (c *credentialConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	pqConnector, err := pq.NewConnector(c.ConnString())
+	if err != nil {
+		return nil, err
+	}
+	return pqConnector.Connect(ctx)
+}
+
+func (c *credentialConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+func (c *credentialConnector) ConnString() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.config.ConnString()
+}
+
+// ConfigFromEnv builds a Config from the DB_* environment variables
+func ConfigFromEnv() Config {
+	return Config{
+		Host:     os.Getenv("DB_HOST"),
+		Port:     5432,
+		User:     os.Getenv("DB_USER"),
+		Password: os.Getenv("DB_PASSWORD"),
+		DBName:   os.Getenv("DB_NAME"),
+		SSLMode:  "disable",
 	}
+}
 
-	// Create connection string
-	connStr := fmt.Sprintf(
+// ConnString returns the lib/pq connection string for the config
+func (c Config) ConnString() string {
+	return fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		config.Host, config.Port, config.User, config.Password, config.DBName, config.SSLMode,
+		c.Host, c.Port, c.User, c.Password, c.DBName, c.SSLMode,
 	)
+}
 
-	var err error
-	// Connect to database
-	db, err = sql.Open("postgres", connStr)
+// Initialize sets up the database connection and applies pending migrations
+func Initialize(config Config) (*sql.DB, error) {
+	if _, err := Connect(config); err != nil {
+		return nil, err
+	}
+
+	migrator, err := NewMigrator(db)
 	if err != nil {
-		return nil, fmt.Errorf("error connecting to the database: %v", err)
+		return nil, fmt.Errorf("error loading migrations: %v", err)
+	}
+
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error running migrations: %v", err)
+	}
+	for _, m := range applied {
+		log.Printf("Applied migration %d_%s", m.Version, m.Name)
 	}
 
+	return db, nil
+}
+
+// Connect sets up the database connection without touching the schema
+func Connect(config Config) (*sql.DB, error) {
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found")
+	}
+
+	// Connect to database
+	connector = &credentialConnector{config: config}
+	db = sql.OpenDB(connector)
+	// Connections made with rotated-out credentials are replaced in time
+	db.SetConnMaxLifetime(30 * time.Minute)
+
 	// Test connection
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("error connecting to the database: %v", err)
@@ -47,27 +114,21 @@ func Initialize(config Config) (*sql.DB, error) {
 
 	log.Printf("Successfully connected to database")
 
-	// Create tables and initial data
-	if err := createSchema(); err != nil {
-		return nil, fmt.Errorf("error creating schema: %v", err)
-	}
-
 	return db, nil
 }
 
-// Query executes a query that returns rows
-func Query(query string, args ...interface{}) (*sql.Rows, error) {
-	return db.Query(query, args...)
-}
-
-// QueryRow executes a query that returns a single row
-func QueryRow(query string, args ...interface{}) *sql.Row {
-	return db.QueryRow(query, args...)
+// SetCredentials changes the user and password new connections are made
+// with. Open connections are left alone until they reach their lifetime.
+func SetCredentials(user, password string) {
+	connector.mu.Lock()
+	defer connector.mu.Unlock()
+	connector.config.User = user
+	connector.config.Password = password
 }
 
-// Exec executes a query that doesn't return rows
-func Exec(query string, args ...interface{}) (sql.Result, error) {
-	return db.Exec(query, args...)
+// ConnString returns the connection string with the current credentials
+func ConnString() string {
+	return connector.ConnString()
 }
 
 // DB returns the database instance
@@ -79,149 +140,3 @@ func DB() *sql.DB {
 func Close() error {
 	return db.Close()
 }
-
-// createSchema creates all tables and initial data
-func createSchema() error {
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	// Create tables
-	tables := `
-		CREATE TABLE IF NOT EXISTS users (
-			user_id SERIAL PRIMARY KEY,
-			username VARCHAR(255) NOT NULL UNIQUE,
-			email VARCHAR(255) NOT NULL UNIQUE,
-			provider_user_id VARCHAR(255) NOT NULL UNIQUE,
-			provider VARCHAR(50) NOT NULL DEFAULT 'google',
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-		);
-
-		CREATE TABLE IF NOT EXISTS roles (
-			role_id SERIAL PRIMARY KEY,
-			name VARCHAR(50) NOT NULL UNIQUE,
-			description TEXT,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-		);
-
-		CREATE TABLE IF NOT EXISTS permissions (
-			permission_id SERIAL PRIMARY KEY,
-			name VARCHAR(50) NOT NULL UNIQUE,
-			description TEXT,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-		);
-
-		CREATE TABLE IF NOT EXISTS role_permissions (
-			role_id INT REFERENCES roles(role_id) ON DELETE CASCADE,
-			permission_id INT REFERENCES permissions(permission_id) ON DELETE CASCADE,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-			PRIMARY KEY (role_id, permission_id)
-		);
-
-		CREATE TABLE IF NOT EXISTS items (
-			item_id SERIAL PRIMARY KEY,
-			name VARCHAR(255) NOT NULL,
-			content JSONB NOT NULL DEFAULT '{}',
-			version INT NOT NULL DEFAULT 1,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-		);
-
-		CREATE TABLE IF NOT EXISTS user_roles (
-			item_id INT REFERENCES items(item_id) ON DELETE CASCADE,
-			role_id INT REFERENCES roles(role_id) ON DELETE CASCADE,
-			user_id INT REFERENCES users(user_id) ON DELETE CASCADE,
-			created_by INT REFERENCES users(user_id) ON DELETE SET NULL,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-			PRIMARY KEY (item_id, user_id)
-		);
-
-		CREATE TABLE IF NOT EXISTS todos (
-			todo_id SERIAL PRIMARY KEY,
-			item_id INT REFERENCES items(item_id) ON DELETE CASCADE,
-			title VARCHAR(255) NOT NULL,
-			done BOOLEAN NOT NULL DEFAULT false,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-		);
-	`
-
-	if _, err := tx.Exec(tables); err != nil {
-		return fmt.Errorf("error creating tables: %v", err)
-	}
-
-	// Create trigger function for ensuring item ownership
-	triggerFunc := `
-		CREATE OR REPLACE FUNCTION ensure_item_owner()
-		RETURNS TRIGGER AS $$
-		BEGIN
-			-- Check if this is an owner role being removed
-			IF EXISTS (
-				SELECT 1 FROM roles 
-				WHERE role_id = OLD.role_id 
-				AND name = 'owner'
-			) THEN
-				-- Only allow owner to remove themselves
-				IF OLD.user_id != CURRENT_USER_ID() THEN
-					RAISE EXCEPTION 'Only the owner can remove themselves from an item';
-				END IF;
-			END IF;
-
-			RETURN OLD;
-		END;
-		$$ LANGUAGE plpgsql;
-	`
-
-	if _, err := tx.Exec(triggerFunc); err != nil {
-		return fmt.Errorf("error creating trigger function: %v", err)
-	}
-
-	// Create trigger without the WHEN clause
-	trigger := `
-		DROP TRIGGER IF EXISTS prevent_remove_last_owner ON user_roles;
-		CREATE TRIGGER prevent_remove_last_owner
-		BEFORE DELETE ON user_roles
-		FOR EACH ROW
-		EXECUTE FUNCTION ensure_item_owner();
-	`
-
-	if _, err := tx.Exec(trigger); err != nil {
-		return fmt.Errorf("error creating trigger: %v", err)
-	}
-
-	// Insert initial data
-	initialData := `
-		INSERT INTO roles (name, description) VALUES
-			('owner', 'Full control over the item and can manage other users'' access'),
-			('editor', 'Can view and edit the item content'),
-			('viewer', 'Can only view the item content')
-		ON CONFLICT (name) DO NOTHING;
-
-		INSERT INTO permissions (name, description) VALUES
-			('can_view', 'Can view the item content'),
-			('can_edit', 'Can modify the item content'),
-			('can_share', 'Can share the item with other users'),
-			('can_delete', 'Can delete the item')
-		ON CONFLICT (name) DO NOTHING;
-
-		INSERT INTO role_permissions (role_id, permission_id)
-		SELECT r.role_id, p.permission_id
-		FROM roles r, permissions p
-		WHERE 
-			(r.name = 'owner') -- owner gets all permissions
-			OR (r.name = 'editor' AND p.name IN ('can_view', 'can_edit'))
-			OR (r.name = 'viewer' AND p.name = 'can_view')
-		ON CONFLICT DO NOTHING;
-	`
-
-	if _, err := tx.Exec(initialData); err != nil {
-		return fmt.Errorf("error inserting initial data: %v", err)
-	}
-
-	return tx.Commit()
-}
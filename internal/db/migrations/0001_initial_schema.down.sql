@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS prevent_remove_last_owner ON user_roles;
+DROP FUNCTION IF EXISTS ensure_item_owner();
+
+DROP TABLE IF EXISTS todos;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS items;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,5 @@
+-- Support filtering and keyset pagination of GET /api/items
+CREATE INDEX IF NOT EXISTS idx_user_roles_user_id ON user_roles (user_id);
+CREATE INDEX IF NOT EXISTS idx_items_created_at ON items (created_at, item_id);
+CREATE INDEX IF NOT EXISTS idx_items_updated_at ON items (updated_at, item_id);
+CREATE INDEX IF NOT EXISTS idx_items_name ON items (name text_pattern_ops, item_id);
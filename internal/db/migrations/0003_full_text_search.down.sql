@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_todos_search_vector;
+DROP INDEX IF EXISTS idx_items_search_vector;
+
+ALTER TABLE todos DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE items DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,12 @@
+-- Item names weigh more than the strings inside their content
+ALTER TABLE items ADD COLUMN IF NOT EXISTS search_vector tsvector
+	GENERATED ALWAYS AS (
+		setweight(to_tsvector('english'::regconfig, coalesce(name, '')), 'A') ||
+		setweight(jsonb_to_tsvector('english'::regconfig, content, '["string"]'), 'B')
+	) STORED;
+
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS search_vector tsvector
+	GENERATED ALWAYS AS (to_tsvector('english'::regconfig, coalesce(title, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_items_search_vector ON items USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_todos_search_vector ON todos USING GIN (search_vector);
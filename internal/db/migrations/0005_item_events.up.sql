@@ -0,0 +1,15 @@
+-- Persisted change feed so SSE clients can resume with Last-Event-ID.
+-- item_id has no foreign key: item.deleted events outlive their item.
+CREATE TABLE IF NOT EXISTS item_events (
+	event_id BIGSERIAL PRIMARY KEY,
+	item_id INT NOT NULL,
+	todo_id INT,
+	type VARCHAR(50) NOT NULL,
+	actor_id INT,
+	audience INT[] NOT NULL DEFAULT '{}',
+	payload JSONB NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_item_events_audience ON item_events USING GIN (audience);
+CREATE INDEX IF NOT EXISTS idx_item_events_created_at ON item_events (created_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS item_doc_updates;
+DROP TABLE IF EXISTS item_docs;
@@ -0,0 +1,21 @@
+-- Collaborative editing state. item_docs.snapshot has every update up to
+-- last_update_id merged in; item_doc_updates keeps the recent updates so
+-- clients can catch up without refetching the snapshot. Updates up to
+-- compacted_through have been dropped from the log.
+CREATE TABLE IF NOT EXISTS item_docs (
+	item_id INT PRIMARY KEY REFERENCES items(item_id) ON DELETE CASCADE,
+	snapshot JSONB NOT NULL,
+	last_update_id BIGINT NOT NULL DEFAULT 0,
+	compacted_through BIGINT NOT NULL DEFAULT 0,
+	updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS item_doc_updates (
+	update_id BIGSERIAL PRIMARY KEY,
+	item_id INT NOT NULL REFERENCES items(item_id) ON DELETE CASCADE,
+	user_id INT REFERENCES users(user_id) ON DELETE SET NULL,
+	payload JSONB NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_item_doc_updates_item ON item_doc_updates (item_id, update_id);
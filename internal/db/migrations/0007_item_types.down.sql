@@ -0,0 +1,6 @@
+-- Items whose content the up migration replaced get their old content back
+UPDATE items SET content = legacy_content WHERE legacy_content IS NOT NULL;
+ALTER TABLE items DROP COLUMN IF EXISTS legacy_content;
+DROP INDEX IF EXISTS idx_items_item_type;
+ALTER TABLE items DROP COLUMN IF EXISTS item_type;
+DROP TABLE IF EXISTS item_types;
@@ -0,0 +1,50 @@
+-- Every item has a type whose JSON Schema its content must satisfy. The
+-- built-in types can't be changed; custom types are added through the API.
+CREATE TABLE IF NOT EXISTS item_types (
+	name VARCHAR(50) PRIMARY KEY,
+	description TEXT,
+	schema JSONB NOT NULL,
+	built_in BOOLEAN NOT NULL DEFAULT FALSE,
+	created_by INT REFERENCES users(user_id) ON DELETE SET NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO item_types (name, description, schema, built_in) VALUES
+	('note', 'Free text that collaborators can edit together',
+	 '{"type": "object", "properties": {"text": {"type": "string"}}, "required": ["text"]}', TRUE),
+	('todo-list', 'A list of todos kept under /todos',
+	 '{"type": "array", "maxItems": 0}', TRUE),
+	('legacy', 'An item from before there were types, whose content can be any JSON',
+	 '{}', TRUE)
+ON CONFLICT (name) DO NOTHING;
+
+ALTER TABLE items ADD COLUMN IF NOT EXISTS item_type VARCHAR(50) NOT NULL DEFAULT 'todo-list' REFERENCES item_types(name);
+
+-- Content this migration has to replace is kept here, so nothing is lost and
+-- the down migration can put it back
+ALTER TABLE items ADD COLUMN IF NOT EXISTS legacy_content JSONB;
+
+-- Items that were being edited as text are notes
+UPDATE items SET item_type = 'note'
+WHERE EXISTS (SELECT 1 FROM item_docs d WHERE d.item_id = items.item_id)
+   OR (jsonb_typeof(content) = 'object' AND content ? 'text'
+       AND NOT EXISTS (SELECT 1 FROM todos t WHERE t.item_id = items.item_id));
+
+-- Their content has to match the note schema from now on
+UPDATE items SET legacy_content = content, content = jsonb_build_object('text', COALESCE(content->>'text', ''))
+WHERE item_type = 'note'
+  AND (jsonb_typeof(content) <> 'object' OR jsonb_typeof(content->'text') IS DISTINCT FROM 'string');
+
+-- Anything else with content of its own and no todos keeps that content as
+-- a legacy item, rather than failing validation on its next edit
+UPDATE items SET item_type = 'legacy'
+WHERE item_type = 'todo-list'
+  AND content NOT IN ('[]'::jsonb, '{}'::jsonb, 'null'::jsonb)
+  AND NOT EXISTS (SELECT 1 FROM todos t WHERE t.item_id = items.item_id);
+
+-- Lists keep their entries in the todos table, so their content is empty.
+-- Whatever else a list with todos had in its content moves aside.
+UPDATE items SET legacy_content = content, content = '[]'
+WHERE item_type = 'todo-list' AND content <> '[]'::jsonb;
+
+CREATE INDEX IF NOT EXISTS idx_items_item_type ON items (item_type);
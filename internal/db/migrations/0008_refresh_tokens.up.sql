@@ -0,0 +1,15 @@
+-- Refresh tokens are stored as SHA-256 hex digests. A family is every token
+-- descended from one sign-in; reusing a rotated token revokes the family.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	token_id BIGSERIAL PRIMARY KEY,
+	token_hash CHAR(64) NOT NULL UNIQUE,
+	user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	family_id VARCHAR(64) NOT NULL,
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	used_at TIMESTAMP WITH TIME ZONE,
+	revoked_at TIMESTAMP WITH TIME ZONE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens (user_id, expires_at);
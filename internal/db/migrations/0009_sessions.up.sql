@@ -0,0 +1,24 @@
+-- A session is one sign-in. Access tokens carry its ID and stop working as
+-- soon as it is revoked; its refresh tokens are the family of the same ID.
+CREATE TABLE IF NOT EXISTS sessions (
+	session_id VARCHAR(64) PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	revoked_at TIMESTAMP WITH TIME ZONE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions (user_id);
+
+-- Every existing refresh token family becomes a session
+INSERT INTO sessions (session_id, user_id, expires_at, revoked_at, created_at)
+SELECT family_id, MIN(user_id), MAX(expires_at),
+       CASE WHEN BOOL_AND(revoked_at IS NOT NULL) THEN MAX(revoked_at) END,
+       MIN(created_at)
+FROM refresh_tokens
+GROUP BY family_id
+ON CONFLICT (session_id) DO NOTHING;
+
+ALTER TABLE refresh_tokens
+	ADD CONSTRAINT refresh_tokens_family_id_fkey
+	FOREIGN KEY (family_id) REFERENCES sessions(session_id) ON DELETE CASCADE;
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_provider_provider_user_id_key;
+ALTER TABLE users ADD CONSTRAINT users_provider_user_id_key UNIQUE (provider_user_id);
+ALTER TABLE users ALTER COLUMN provider SET DEFAULT 'google';
@@ -0,0 +1,5 @@
+-- Users can sign in with providers other than Google, whose subject IDs
+-- are only unique per provider
+ALTER TABLE users ALTER COLUMN provider DROP DEFAULT;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_provider_user_id_key;
+ALTER TABLE users ADD CONSTRAINT users_provider_provider_user_id_key UNIQUE (provider, provider_user_id);
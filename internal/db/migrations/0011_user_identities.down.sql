@@ -0,0 +1,18 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS provider VARCHAR(50);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS provider_user_id VARCHAR(255);
+
+-- Each user keeps their first identity. Every user has at least one.
+UPDATE users u
+SET provider = i.provider, provider_user_id = i.subject
+FROM (
+	SELECT DISTINCT ON (user_id) user_id, provider, subject
+	FROM user_identities
+	ORDER BY user_id, identity_id
+) i
+WHERE u.user_id = i.user_id;
+
+ALTER TABLE users ALTER COLUMN provider SET NOT NULL;
+ALTER TABLE users ALTER COLUMN provider_user_id SET NOT NULL;
+ALTER TABLE users ADD CONSTRAINT users_provider_provider_user_id_key UNIQUE (provider, provider_user_id);
+
+DROP TABLE IF EXISTS user_identities;
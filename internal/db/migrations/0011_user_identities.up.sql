@@ -0,0 +1,26 @@
+-- A user can sign in with accounts at several identity providers. Each of
+-- those accounts is an identity.
+CREATE TABLE IF NOT EXISTS user_identities (
+	identity_id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	provider VARCHAR(50) NOT NULL,
+	subject VARCHAR(255) NOT NULL,
+	email VARCHAR(255),
+	email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	last_sign_in_at TIMESTAMP WITH TIME ZONE,
+	UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+CREATE INDEX IF NOT EXISTS idx_user_identities_verified_email ON user_identities(LOWER(email)) WHERE email_verified;
+
+-- Until now everyone signed in with Google, whose accounts all have a
+-- verified email address
+INSERT INTO user_identities (user_id, provider, subject, email, email_verified, created_at)
+SELECT user_id, provider, provider_user_id, email, provider = 'google', created_at
+FROM users
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users DROP COLUMN IF EXISTS provider_user_id;
+ALTER TABLE users DROP COLUMN IF EXISTS provider;
@@ -0,0 +1,8 @@
+-- OAuth states that have finished a sign-in. The state cookie proves which
+-- browser started a sign-in; this makes each one work once.
+CREATE TABLE IF NOT EXISTS used_oauth_states (
+	state VARCHAR(64) PRIMARY KEY,
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_used_oauth_states_expires_at ON used_oauth_states (expires_at);
@@ -0,0 +1,16 @@
+-- Personal access tokens let scripts call the API without a browser
+-- sign-in. Like refresh tokens they are stored as SHA-256 hex digests, and
+-- their scopes cap what they can do whatever the user's roles.
+CREATE TABLE IF NOT EXISTS personal_tokens (
+	token_id SERIAL PRIMARY KEY,
+	token_hash CHAR(64) NOT NULL UNIQUE,
+	user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	name VARCHAR(100) NOT NULL,
+	scopes TEXT[] NOT NULL DEFAULT '{}',
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	last_used_at TIMESTAMP WITH TIME ZONE,
+	revoked_at TIMESTAMP WITH TIME ZONE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_personal_tokens_user ON personal_tokens (user_id);
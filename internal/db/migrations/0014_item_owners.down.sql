@@ -0,0 +1,29 @@
+DROP TABLE IF EXISTS ownership_transfers;
+
+DROP TRIGGER IF EXISTS item_has_owner ON user_roles;
+DROP FUNCTION IF EXISTS ensure_item_has_owner();
+
+CREATE OR REPLACE FUNCTION ensure_item_owner()
+RETURNS TRIGGER AS $$
+BEGIN
+	-- Check if this is an owner role being removed
+	IF EXISTS (
+		SELECT 1 FROM roles 
+		WHERE role_id = OLD.role_id 
+		AND name = 'owner'
+	) THEN
+		-- Only allow owner to remove themselves
+		IF OLD.user_id != CURRENT_USER_ID() THEN
+			RAISE EXCEPTION 'Only the owner can remove themselves from an item';
+		END IF;
+	END IF;
+
+	RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS prevent_remove_last_owner ON user_roles;
+CREATE TRIGGER prevent_remove_last_owner
+BEFORE DELETE ON user_roles
+FOR EACH ROW
+EXECUTE FUNCTION ensure_item_owner();
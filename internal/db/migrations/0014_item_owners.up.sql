@@ -0,0 +1,42 @@
+-- ensure_item_owner called CURRENT_USER_ID(), which doesn't exist, so
+-- removing any owner failed. Items can now have several owners, and the
+-- rule is that every item keeps at least one. The check is deferred to the
+-- end of the transaction so ownership can change hands in two steps, and
+-- items that were deleted in the meantime are skipped.
+DROP TRIGGER IF EXISTS prevent_remove_last_owner ON user_roles;
+DROP FUNCTION IF EXISTS ensure_item_owner();
+
+CREATE OR REPLACE FUNCTION ensure_item_has_owner()
+RETURNS TRIGGER AS $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM items WHERE item_id = OLD.item_id)
+	AND NOT EXISTS (
+		SELECT 1 FROM user_roles ur
+		JOIN roles r ON ur.role_id = r.role_id
+		WHERE ur.item_id = OLD.item_id AND r.name = 'owner'
+	) THEN
+		RAISE EXCEPTION 'item % must keep at least one owner', OLD.item_id
+			USING ERRCODE = 'check_violation', CONSTRAINT = 'item_has_owner';
+	END IF;
+
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS item_has_owner ON user_roles;
+CREATE CONSTRAINT TRIGGER item_has_owner
+AFTER UPDATE OR DELETE ON user_roles
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW
+EXECUTE FUNCTION ensure_item_has_owner();
+
+-- An owner's offer to hand an item over. Accepting it makes the recipient
+-- an owner and the previous owner an editor.
+CREATE TABLE IF NOT EXISTS ownership_transfers (
+	item_id INT PRIMARY KEY REFERENCES items(item_id) ON DELETE CASCADE,
+	from_user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	to_user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_ownership_transfers_to_user ON ownership_transfers (to_user_id);
@@ -0,0 +1,16 @@
+-- Items shared with an email address before anyone has signed in with it.
+-- Each invitation's link carries a random token, stored like refresh tokens
+-- as a SHA-256 hex digest. An item has at most one invitation per address.
+CREATE TABLE IF NOT EXISTS item_invitations (
+	invitation_id SERIAL PRIMARY KEY,
+	item_id INT NOT NULL REFERENCES items(item_id) ON DELETE CASCADE,
+	email VARCHAR(255) NOT NULL,
+	role_id INT NOT NULL REFERENCES roles(role_id),
+	invited_by INT REFERENCES users(user_id) ON DELETE SET NULL,
+	token_hash CHAR(64) NOT NULL UNIQUE,
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_item_invitations_item_email ON item_invitations (item_id, LOWER(email));
+CREATE INDEX IF NOT EXISTS idx_item_invitations_email ON item_invitations (LOWER(email));
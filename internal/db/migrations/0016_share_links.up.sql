@@ -0,0 +1,19 @@
+-- Links that give whoever has them a role on an item. Like refresh tokens,
+-- their tokens are stored as SHA-256 hex digests; passwords are bcrypt
+-- hashes. A link works until it is revoked, expires or has been used
+-- max_uses times.
+CREATE TABLE IF NOT EXISTS share_links (
+	link_id SERIAL PRIMARY KEY,
+	item_id INT NOT NULL REFERENCES items(item_id) ON DELETE CASCADE,
+	token_hash CHAR(64) NOT NULL UNIQUE,
+	role_id INT NOT NULL REFERENCES roles(role_id),
+	password_hash TEXT,
+	expires_at TIMESTAMP WITH TIME ZONE,
+	max_uses INT CHECK (max_uses > 0),
+	use_count INT NOT NULL DEFAULT 0,
+	created_by INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	revoked_at TIMESTAMP WITH TIME ZONE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_share_links_item ON share_links (item_id);
@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE name = 'can_complete';
+
+ALTER TABLE roles DROP COLUMN IF EXISTS built_in;
+ALTER TABLE users DROP COLUMN IF EXISTS is_admin;
@@ -0,0 +1,19 @@
+-- Admins can define roles of their own and choose what they grant. The
+-- seeded roles are built in and can't be changed, since sharing and
+-- ownership rely on what they grant.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS built_in BOOLEAN NOT NULL DEFAULT false;
+UPDATE roles SET built_in = true WHERE name IN ('owner', 'editor', 'viewer');
+
+-- Ticking todos off is its own permission, so a role can do that without
+-- being able to edit the list
+INSERT INTO permissions (name, description) VALUES
+	('can_complete', 'Can mark todos as done')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.role_id, p.permission_id
+FROM roles r, permissions p
+WHERE r.name IN ('owner', 'editor') AND p.name = 'can_complete'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,125 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+// subscriptionBuffer is how many events a subscriber may fall behind by
+// before it is dropped
+const subscriptionBuffer = 64
+
+// Publisher sends change events to every interested subscriber
+type Publisher interface {
+	Publish(ctx context.Context, event models.Event) error
+}
+
+// EventLog replays persisted events to clients that reconnect
+type EventLog interface {
+	// Since returns up to limit events after afterID whose audience includes
+	// userID, oldest first
+	Since(ctx context.Context, userID int, afterID int64, limit int) ([]models.Event, error)
+}
+
+// Subscription receives the events accepted by its filter until closed. C is
+// closed when the subscription ends, including when the subscriber was too
+// slow to keep up.
+type Subscription struct {
+	C <-chan models.Event
+
+	c      chan models.Event
+	filter func(models.Event) bool
+	hub    *Hub
+	once   sync.Once
+}
+
+// Close stops delivery and releases the subscription
+func (s *Subscription) Close() {
+	s.hub.remove(s)
+}
+
+// Hub fans events out to subscribers in this process
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe returns a subscription to the events for which filter returns true
+func (h *Hub) Subscribe(filter func(models.Event) bool) *Subscription {
+	c := make(chan models.Event, subscriptionBuffer)
+	sub := &Subscription{C: c, c: c, filter: filter, hub: h}
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+
+	return sub
+}
+
+// Publish delivers the event to local subscribers only. Use a broker to reach
+// subscribers on other replicas.
+func (h *Hub) Publish(ctx context.Context, event models.Event) error {
+	h.Dispatch(event)
+	return nil
+}
+
+// Dispatch hands the event to every matching subscriber without blocking
+func (h *Hub) Dispatch(event models.Event) {
+	var slow []*Subscription
+
+	h.mu.RLock()
+	for sub := range h.subs {
+		if !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.c <- event:
+		default:
+			slow = append(slow, sub)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, sub := range slow {
+		h.remove(sub)
+	}
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	h.mu.Lock()
+	delete(h.subs, sub)
+	h.mu.Unlock()
+
+	sub.once.Do(func() { close(sub.c) })
+}
+
+// ForItemAndUser is a subscription filter matching the events of a single
+// item that the user had a role on when the event happened
+func ForItemAndUser(itemID, userID int) func(models.Event) bool {
+	return func(e models.Event) bool {
+		return e.ItemID == itemID && inAudience(e, userID)
+	}
+}
+
+// ForUser is a subscription filter matching the events of every item the
+// user had a role on when the event happened
+func ForUser(userID int) func(models.Event) bool {
+	return func(e models.Event) bool {
+		return inAudience(e, userID)
+	}
+}
+
+func inAudience(e models.Event, userID int) bool {
+	for _, id := range e.Audience {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
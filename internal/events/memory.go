@@ -0,0 +1,51 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+// MemoryBroker keeps the event log in process and dispatches straight into
+// its hub. It is intended for tests and single-process development.
+type MemoryBroker struct {
+	hub *Hub
+
+	mu     sync.RWMutex
+	nextID int64
+	log    []models.Event
+}
+
+func NewMemoryBroker(hub *Hub) *MemoryBroker {
+	return &MemoryBroker{hub: hub}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, event models.Event) error {
+	b.mu.Lock()
+	b.nextID++
+	event.EventID = b.nextID
+	b.log = append(b.log, event)
+	b.mu.Unlock()
+
+	b.hub.Dispatch(event)
+	return nil
+}
+
+func (b *MemoryBroker) Since(ctx context.Context, userID int, afterID int64, limit int) ([]models.Event, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var events []models.Event
+	for _, event := range b.log {
+		if event.EventID <= afterID || !inAudience(event, userID) {
+			continue
+		}
+		events = append(events, event)
+		if len(events) == limit {
+			break
+		}
+	}
+
+	return events, nil
+}
@@ -0,0 +1,235 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+// notifyChannel is the Postgres channel events are sent on
+const notifyChannel = "item_events"
+
+// maxNotifyPayload stays under Postgres' 8000 byte NOTIFY limit
+const maxNotifyPayload = 7900
+
+// publishLock is the advisory lock Publish holds while it appends to the
+// log. event_id comes from a sequence, so two publishers could otherwise
+// commit out of order, and a client that resumed after the later ID would
+// never see the earlier event.
+const publishLock = 0x6974656d // "item"
+
+// eventRetention is how long events stay in the log for clients to resume from
+const eventRetention = 7 * 24 * time.Hour
+
+// notification is what travels over NOTIFY. The audience is internal and so
+// is not part of the event's own JSON.
+type notification struct {
+	models.Event
+	Audience []int `json:"audience"`
+}
+
+// PostgresBroker records events in item_events, publishes them with NOTIFY
+// and LISTENs for them so that subscribers on every API replica see every
+// change
+type PostgresBroker struct {
+	db   *sql.DB
+	hub  *Hub
+	done chan struct{}
+
+	mu       sync.Mutex
+	listener *pq.Listener
+}
+
+// NewPostgresBroker starts listening on connStr and dispatches the events it
+// receives, including its own, into hub
+func NewPostgresBroker(db *sql.DB, connStr string, hub *Hub) (*PostgresBroker, error) {
+	listener, err := listen(connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &PostgresBroker{db: db, hub: hub, listener: listener, done: make(chan struct{})}
+	go b.run()
+	return b, nil
+}
+
+func listen(connStr string) (*pq.Listener, error) {
+	listener := pq.NewListener(connStr, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Event listener: %v", err)
+		}
+	})
+	if err := listener.Listen(notifyChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Reconnect starts listening on connStr instead, e.g. after the database
+// credentials were rotated. The listener reconnects by itself with the
+// connection string it was started with, which stops working once the old
+// credentials are revoked. Events sent while switching over are only
+// available from the log.
+func (b *PostgresBroker) Reconnect(connStr string) error {
+	listener, err := listen(connStr)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	old := b.listener
+	b.listener = listener
+	b.mu.Unlock()
+	return old.Close()
+}
+
+func (b *PostgresBroker) currentListener() *pq.Listener {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.listener
+}
+
+// Publish appends the event to the log and sends it to every replica. Only
+// one event is published at a time across all replicas. Events too large for
+// NOTIFY are sent without the item or todo body; clients refetch those or
+// replay them from the log.
+func (b *PostgresBroker) Publish(ctx context.Context, event models.Event) error {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	// Held until the transaction ends, so events commit in event_id order
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", publishLock); err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO item_events (item_id, todo_id, type, actor_id, audience, payload, created_at)
+		VALUES ($1, NULLIF($2, 0), $3, NULLIF($4, 0), $5, $6, $7)
+		RETURNING event_id
+	`, event.ItemID, event.TodoID, event.Type, event.ActorID, pq.Array(event.Audience), body, event.CreatedAt).Scan(&event.EventID)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(notification{Event: event, Audience: event.Audience})
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxNotifyPayload {
+		event.Item = nil
+		event.Todo = nil
+		event.DocUpdate = nil
+		if payload, err = json.Marshal(notification{Event: event, Audience: event.Audience}); err != nil {
+			return err
+		}
+	}
+
+	// Delivered to listeners when the transaction commits
+	if _, err := tx.ExecContext(ctx, "SELECT pg_notify($1, $2)", notifyChannel, string(payload)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (b *PostgresBroker) Since(ctx context.Context, userID int, afterID int64, limit int) ([]models.Event, error) {
+	rows, err := b.db.QueryContext(ctx, `
+		SELECT event_id, payload, audience
+		FROM item_events
+		WHERE event_id > $1 AND audience @> ARRAY[$2::int]
+		ORDER BY event_id
+		LIMIT $3
+	`, afterID, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.Event
+	for rows.Next() {
+		var event models.Event
+		var payload []byte
+		var audience pq.Int64Array
+		if err := rows.Scan(&event.EventID, &payload, &audience); err != nil {
+			return nil, err
+		}
+		eventID := event.EventID
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		event.EventID = eventID
+		for _, id := range audience {
+			event.Audience = append(event.Audience, int(id))
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// Close stops listening
+func (b *PostgresBroker) Close() error {
+	close(b.done)
+	return b.currentListener().Close()
+}
+
+func (b *PostgresBroker) run() {
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+
+	for {
+		listener := b.currentListener()
+		select {
+		case <-b.done:
+			return
+		case n, ok := <-listener.Notify:
+			if !ok {
+				// Reconnect closed the old listener
+				if listener != b.currentListener() {
+					continue
+				}
+				return
+			}
+			// A nil notification means the connection was re-established
+			if n == nil {
+				continue
+			}
+			var msg notification
+			if err := json.Unmarshal([]byte(n.Extra), &msg); err != nil {
+				log.Printf("Error decoding event: %v", err)
+				continue
+			}
+			msg.Event.Audience = msg.Audience
+			b.hub.Dispatch(msg.Event)
+		case <-ping.C:
+			go listener.Ping()
+		case <-prune.C:
+			go b.prune()
+		}
+	}
+}
+
+// prune drops events older than the retention window
+func (b *PostgresBroker) prune() {
+	_, err := b.db.Exec("DELETE FROM item_events WHERE created_at < $1", time.Now().Add(-eventRetention))
+	if err != nil {
+		log.Printf("Error pruning item events: %v", err)
+	}
+}
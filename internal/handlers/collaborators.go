@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+// ListCollaboratorsHandler lists everyone with a role on the item
+func (h *ItemHandler) ListCollaboratorsHandler(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "item_id"))
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	collaborators, err := h.Shares.ListCollaborators(r.Context(), itemID)
+	if err != nil {
+		log.Printf("Error listing collaborators: %v", err)
+		http.Error(w, "Failed to retrieve collaborators", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collaborators)
+}
+
+// UpdateCollaboratorHandler changes a collaborator's role. Only owners can
+// make someone an owner or change another owner's role, and the last owner
+// can't be demoted.
+func (h *ItemHandler) UpdateCollaboratorHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	itemID, collaboratorID, ok := collaboratorParams(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !checkRole(w, r, h.Roles, body.Role) {
+		return
+	}
+
+	if !h.checkOwnerChange(w, r, itemID, userID, collaboratorID, body.Role) {
+		return
+	}
+
+	if err := h.Shares.ChangeRole(r.Context(), itemID, collaboratorID, body.Role); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "Collaborator not found", http.StatusNotFound)
+		case errors.Is(err, store.ErrLastOwner):
+			writeLastOwner(w)
+		default:
+			log.Printf("Error changing collaborator role: %v", err)
+			http.Error(w, "Failed to update user role", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.publishCollaboratorEvent(r, models.EventCollaboratorChanged, itemID, collaboratorID)
+	h.writeCollaborator(w, r, itemID, collaboratorID)
+}
+
+// RemoveCollaboratorHandler revokes a collaborator's access to the item.
+// Only owners can remove an owner, and never the last one.
+func (h *ItemHandler) RemoveCollaboratorHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	itemID, collaboratorID, ok := collaboratorParams(w, r)
+	if !ok {
+		return
+	}
+
+	if !h.checkOwnerChange(w, r, itemID, userID, collaboratorID, "") {
+		return
+	}
+
+	h.revokeAccess(w, r, itemID, collaboratorID)
+}
+
+// LeaveItemHandler removes the current user's own access to an item. The
+// last owner has to hand the item over first.
+func (h *ItemHandler) LeaveItemHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	itemID, err := strconv.Atoi(chi.URLParam(r, "item_id"))
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	h.revokeAccess(w, r, itemID, userID)
+}
+
+func (h *ItemHandler) revokeAccess(w http.ResponseWriter, r *http.Request, itemID, userID int) {
+	if err := h.Shares.RevokeAccess(r.Context(), itemID, userID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "Collaborator not found", http.StatusNotFound)
+		case errors.Is(err, store.ErrLastOwner):
+			writeLastOwner(w)
+		default:
+			log.Printf("Error revoking access: %v", err)
+			http.Error(w, "Failed to revoke access", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.publishCollaboratorEvent(r, models.EventCollaboratorRemoved, itemID, userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// publishCollaboratorEvent tells everyone with a role on the item, and the
+// collaborator themselves, that the collaborator's role changed or was
+// removed. Their open item feeds close if they can no longer see the item.
+func (h *ItemHandler) publishCollaboratorEvent(r *http.Request, eventType string, itemID, collaboratorID int) {
+	audience, err := h.Shares.ItemUserIDs(r.Context(), itemID)
+	if err != nil {
+		log.Printf("Error getting audience for item %d: %v", itemID, err)
+		return
+	}
+	if eventType == models.EventCollaboratorRemoved {
+		audience = append(audience, collaboratorID)
+	}
+
+	publishEvent(r, h.Events, h.Shares, models.Event{Type: eventType, ItemID: itemID, UserID: collaboratorID, Audience: audience})
+}
+
+// checkOwnerChange writes an error and returns false if giving targetID
+// newRole, or removing them when newRole is empty, changes who owns the
+// item and the current user isn't an owner. Only owners manage owners.
+func (h *ItemHandler) checkOwnerChange(w http.ResponseWriter, r *http.Request, itemID, userID, targetID int, newRole string) bool {
+	targetRole, err := h.Shares.GetRole(r.Context(), itemID, targetID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Error getting collaborator role: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if newRole != "owner" && targetRole != "owner" {
+		return true
+	}
+
+	role, err := h.Shares.GetRole(r.Context(), itemID, userID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Error getting user role: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if role != "owner" {
+		http.Error(w, "Forbidden - only owners can add, change or remove owners", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// writeLastOwner rejects a change that would leave an item without an owner
+func writeLastOwner(w http.ResponseWriter) {
+	http.Error(w, "Conflict - the item must keep at least one owner; add another owner or transfer ownership first", http.StatusConflict)
+}
+
+// writeCollaborator responds with one collaborator of the item
+func (h *ItemHandler) writeCollaborator(w http.ResponseWriter, r *http.Request, itemID, userID int) {
+	collaborators, err := h.Shares.ListCollaborators(r.Context(), itemID)
+	if err != nil {
+		log.Printf("Error listing collaborators: %v", err)
+		http.Error(w, "Failed to retrieve collaborators", http.StatusInternalServerError)
+		return
+	}
+
+	for _, c := range collaborators {
+		if c.UserID == userID {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(c)
+			return
+		}
+	}
+	http.Error(w, "Collaborator not found", http.StatusNotFound)
+}
+
+func collaboratorParams(w http.ResponseWriter, r *http.Request) (itemID, userID int, ok bool) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "item_id"))
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	userID, err = strconv.Atoi(chi.URLParam(r, "user_id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return itemID, userID, true
+}
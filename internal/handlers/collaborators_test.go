@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/middleware"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+func newCollaboratorRouter(m *store.Memory) http.Handler {
+	h := newTestItemHandler(m)
+	r := chi.NewRouter()
+	r.Use(asUser)
+	r.With(middleware.Authorize(m, "can_view")).Get("/items/{item_id}", h.GetItemByIDHandler)
+	r.With(middleware.Authorize(m, "can_view")).Get("/items/{item_id}/collaborators", h.ListCollaboratorsHandler)
+	r.With(middleware.Authorize(m, "can_share")).Patch("/items/{item_id}/collaborators/{user_id}", h.UpdateCollaboratorHandler)
+	r.With(middleware.Authorize(m, "can_share")).Delete("/items/{item_id}/collaborators/{user_id}", h.RemoveCollaboratorHandler)
+	r.With(middleware.Authorize(m, "can_view")).Post("/items/{item_id}/leave", h.LeaveItemHandler)
+	r.With(middleware.Authorize(m, "can_view")).Get("/items/{item_id}/transfer", h.GetTransferHandler)
+	r.With(middleware.Authorize(m, "can_share")).Post("/items/{item_id}/transfer", h.OfferTransferHandler)
+	r.With(middleware.Authorize(m, "can_share")).Delete("/items/{item_id}/transfer", h.CancelTransferHandler)
+	r.Post("/items/{item_id}/transfer/accept", h.AcceptTransferHandler)
+	r.Post("/items/{item_id}/transfer/decline", h.DeclineTransferHandler)
+	r.Get("/me/transfers", h.ListIncomingTransfersHandler)
+	return r
+}
+
+// share gives userID role on the item, as shared by owner
+func share(t *testing.T, m *store.Memory, itemID, userID int, role string, owner int) {
+	t.Helper()
+	if err := m.ShareItem(context.Background(), itemID, userID, role, owner); err != nil {
+		t.Fatalf("sharing with %d as %s: %v", userID, role, err)
+	}
+}
+
+// roleOf returns userID's role on the item, or "" if they have none
+func roleOf(t *testing.T, m *store.Memory, itemID, userID int) string {
+	t.Helper()
+	role, err := m.GetRole(context.Background(), itemID, userID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		t.Fatal(err)
+	}
+	return role
+}
+
+func collaboratorPath(itemID, userID int) string {
+	return "/items/" + strconv.Itoa(itemID) + "/collaborators/" + strconv.Itoa(userID)
+}
+
+func TestListCollaborators(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	editor, viewer := newTestUser(t, m, "editor"), newTestUser(t, m, "viewer")
+	share(t, m, itemID, editor, "editor", owner)
+	share(t, m, itemID, viewer, "viewer", owner)
+	router := newCollaboratorRouter(m)
+
+	w := do(t, router, "GET", "/items/"+strconv.Itoa(itemID)+"/collaborators", viewer, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	var collaborators []models.Collaborator
+	json.NewDecoder(w.Body).Decode(&collaborators)
+	roles := map[int]string{}
+	for _, c := range collaborators {
+		roles[c.UserID] = c.Role
+	}
+	want := map[int]string{owner: "owner", editor: "editor", viewer: "viewer"}
+	if len(roles) != len(want) {
+		t.Fatalf("got %v, want %v", roles, want)
+	}
+	for userID, role := range want {
+		if roles[userID] != role {
+			t.Errorf("user %d: got role %q, want %q", userID, roles[userID], role)
+		}
+	}
+
+	stranger := newTestUser(t, m, "stranger")
+	if w := do(t, router, "GET", "/items/"+strconv.Itoa(itemID)+"/collaborators", stranger, ""); w.Code != http.StatusForbidden {
+		t.Errorf("stranger: got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestUpdateCollaborator(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	editor, viewer := newTestUser(t, m, "editor"), newTestUser(t, m, "viewer")
+	share(t, m, itemID, editor, "editor", owner)
+	share(t, m, itemID, viewer, "viewer", owner)
+	stranger := newTestUser(t, m, "stranger")
+	router := newCollaboratorRouter(m)
+
+	w := do(t, router, "PATCH", collaboratorPath(itemID, viewer), owner, `{"role": "editor"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("promote viewer: got %d %s", w.Code, w.Body)
+	}
+	var updated models.Collaborator
+	json.NewDecoder(w.Body).Decode(&updated)
+	if updated.UserID != viewer || updated.Role != "editor" || roleOf(t, m, itemID, viewer) != "editor" {
+		t.Errorf("promote viewer: got %+v", updated)
+	}
+
+	for _, tt := range []struct {
+		name           string
+		userID, target int
+		body           string
+		want           int
+	}{
+		{"unknown role", owner, viewer, `{"role": "superuser"}`, http.StatusBadRequest},
+		{"no role", owner, viewer, `{}`, http.StatusBadRequest},
+		{"not a collaborator", owner, stranger, `{"role": "viewer"}`, http.StatusNotFound},
+		{"editor can't share", editor, viewer, `{"role": "viewer"}`, http.StatusForbidden},
+	} {
+		if w := do(t, router, "PATCH", collaboratorPath(itemID, tt.target), tt.userID, tt.body); w.Code != tt.want {
+			t.Errorf("%s: got %d %s, want %d", tt.name, w.Code, w.Body, tt.want)
+		}
+	}
+	if roleOf(t, m, itemID, viewer) != "editor" || roleOf(t, m, itemID, stranger) != "" {
+		t.Error("a rejected change went through")
+	}
+}
+
+func TestRemoveCollaboratorAndLeave(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	editor, viewer := newTestUser(t, m, "editor"), newTestUser(t, m, "viewer")
+	share(t, m, itemID, editor, "editor", owner)
+	share(t, m, itemID, viewer, "viewer", owner)
+	router := newCollaboratorRouter(m)
+	item := "/items/" + strconv.Itoa(itemID)
+
+	if w := do(t, router, "DELETE", collaboratorPath(itemID, viewer), editor, ""); w.Code != http.StatusForbidden {
+		t.Errorf("editor removing viewer: got %d %s", w.Code, w.Body)
+	}
+	if w := do(t, router, "DELETE", collaboratorPath(itemID, viewer), owner, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("owner removing viewer: got %d %s", w.Code, w.Body)
+	}
+	if w := do(t, router, "GET", item, viewer, ""); w.Code != http.StatusForbidden {
+		t.Errorf("removed viewer reading the item: got %d %s", w.Code, w.Body)
+	}
+	if w := do(t, router, "DELETE", collaboratorPath(itemID, viewer), owner, ""); w.Code != http.StatusNotFound {
+		t.Errorf("removing viewer again: got %d %s", w.Code, w.Body)
+	}
+
+	if w := do(t, router, "POST", item+"/leave", editor, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("editor leaving: got %d %s", w.Code, w.Body)
+	}
+	if roleOf(t, m, itemID, editor) != "" {
+		t.Error("editor still has a role after leaving")
+	}
+}
+
+func TestOnlyOwnersManageOwners(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	curatorRole := models.Role{Name: "curator", Permissions: []string{"can_view", "can_share"}}
+	if err := m.CreateRole(context.Background(), &curatorRole); err != nil {
+		t.Fatal(err)
+	}
+	curator, editor, viewer := newTestUser(t, m, "curator"), newTestUser(t, m, "editor"), newTestUser(t, m, "viewer")
+	share(t, m, itemID, curator, "curator", owner)
+	share(t, m, itemID, editor, "editor", owner)
+	share(t, m, itemID, viewer, "viewer", owner)
+	router := newCollaboratorRouter(m)
+
+	// Someone who can share but doesn't own the item can't touch owners
+	for _, tt := range []struct {
+		name, method string
+		target       int
+		body         string
+	}{
+		{"make someone an owner", "PATCH", viewer, `{"role": "owner"}`},
+		{"demote an owner", "PATCH", owner, `{"role": "viewer"}`},
+		{"remove an owner", "DELETE", owner, ""},
+	} {
+		w := do(t, router, tt.method, collaboratorPath(itemID, tt.target), curator, tt.body)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("curator trying to %s: got %d %s", tt.name, w.Code, w.Body)
+		}
+	}
+	if roleOf(t, m, itemID, owner) != "owner" || roleOf(t, m, itemID, viewer) != "viewer" {
+		t.Fatal("a curator changed who owns the item")
+	}
+	// but can manage everyone else
+	if w := do(t, router, "PATCH", collaboratorPath(itemID, viewer), curator, `{"role": "editor"}`); w.Code != http.StatusOK {
+		t.Errorf("curator promoting viewer: got %d %s", w.Code, w.Body)
+	}
+
+	// An owner can add a co-owner, who can then demote them
+	if w := do(t, router, "PATCH", collaboratorPath(itemID, editor), owner, `{"role": "owner"}`); w.Code != http.StatusOK {
+		t.Fatalf("owner adding a co-owner: got %d %s", w.Code, w.Body)
+	}
+	if w := do(t, router, "PATCH", collaboratorPath(itemID, owner), editor, `{"role": "editor"}`); w.Code != http.StatusOK {
+		t.Errorf("co-owner demoting the original owner: got %d %s", w.Code, w.Body)
+	}
+	if roleOf(t, m, itemID, owner) != "editor" || roleOf(t, m, itemID, editor) != "owner" {
+		t.Errorf("got roles %q and %q", roleOf(t, m, itemID, owner), roleOf(t, m, itemID, editor))
+	}
+}
+
+func TestItemKeepsAnOwner(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	coOwner := newTestUser(t, m, "co-owner")
+	router := newCollaboratorRouter(m)
+	item := "/items/" + strconv.Itoa(itemID)
+
+	for _, tt := range []struct{ name, method, path, body string }{
+		{"demote themselves", "PATCH", collaboratorPath(itemID, owner), `{"role": "editor"}`},
+		{"remove themselves", "DELETE", collaboratorPath(itemID, owner), ""},
+		{"leave", "POST", item + "/leave", ""},
+	} {
+		if w := do(t, router, tt.method, tt.path, owner, tt.body); w.Code != http.StatusConflict {
+			t.Errorf("last owner trying to %s: got %d %s", tt.name, w.Code, w.Body)
+		}
+		if roleOf(t, m, itemID, owner) != "owner" {
+			t.Fatalf("last owner trying to %s lost ownership", tt.name)
+		}
+	}
+
+	// With a co-owner, either can go
+	share(t, m, itemID, coOwner, "owner", owner)
+	if w := do(t, router, "POST", item+"/leave", owner, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("leaving with a co-owner: got %d %s", w.Code, w.Body)
+	}
+	if w := do(t, router, "POST", item+"/leave", coOwner, ""); w.Code != http.StatusConflict {
+		t.Errorf("the co-owner, now the last owner, leaving: got %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/crdt"
+	"github.com/onyeepeace/todo-api/internal/events"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+const (
+	// docLogRetention is how many updates per item stay in the log for
+	// clients catching up; older ones only live on in the snapshot
+	docLogRetention = 500
+	// maxPendingOps bounds the ops a document holds while waiting on
+	// operations the server has not seen
+	maxPendingOps = 1000
+)
+
+// docSeedSite is the site a document is seeded as from the text a note had
+// before it was first edited collaboratively. Clients can't use it.
+const docSeedSite = "server"
+
+var errTooManyPending = errors.New("too many operations waiting on missing dependencies")
+
+// DocHandler serves collaborative editing of an item's text. The text is an
+// RGA document (see package crdt) that clients change by sending incremental
+// updates. The server merges them, keeps the merged snapshot and a log of
+// recent updates, and mirrors the text into the item's content as
+// {"text": "..."}.
+type DocHandler struct {
+	Docs   store.DocStore
+	Shares store.ShareStore
+	Events events.Publisher
+}
+
+type docResponse struct {
+	ItemID       int               `json:"item_id"`
+	Text         string            `json:"text"`
+	Vector       map[string]uint64 `json:"vector"`
+	Pending      int               `json:"pending"`
+	LastUpdateID int64             `json:"last_update_id"`
+	Snapshot     json.RawMessage   `json:"snapshot,omitempty"`
+}
+
+// loadDocument restores the document from its snapshot or, before it has
+// one, seeds it with the item's text so the first update doesn't lose it
+func loadDocument(snapshot, content json.RawMessage) (*crdt.Document, error) {
+	if snapshot == nil {
+		var current struct {
+			Text string `json:"text"`
+		}
+		if len(content) > 0 {
+			if err := json.Unmarshal(content, &current); err != nil {
+				return nil, err
+			}
+		}
+		return crdt.FromText(docSeedSite, current.Text), nil
+	}
+
+	doc := crdt.New()
+	if err := json.Unmarshal(snapshot, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (h *DocHandler) GetDocHandler(w http.ResponseWriter, r *http.Request) {
+	itemIDStr := chi.URLParam(r, "item_id")
+	itemID, err := strconv.Atoi(itemIDStr)
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	stored, err := h.Docs.GetDoc(r.Context(), itemID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Item not found", http.StatusNotFound)
+		} else {
+			log.Printf("Error getting document: %v", err)
+			http.Error(w, "Failed to get document", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	doc, err := loadDocument(stored.Snapshot, stored.Content)
+	if err != nil {
+		log.Printf("Error loading document for item %d: %v", itemID, err)
+		http.Error(w, "Failed to get document", http.StatusInternalServerError)
+		return
+	}
+
+	snapshot, err := json.Marshal(doc)
+	if err != nil {
+		log.Printf("Error encoding document for item %d: %v", itemID, err)
+		http.Error(w, "Failed to get document", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(docResponse{
+		ItemID:       itemID,
+		Text:         doc.Text(),
+		Vector:       doc.Vector(),
+		Pending:      doc.Pending(),
+		LastUpdateID: stored.LastUpdateID,
+		Snapshot:     snapshot,
+	})
+}
+
+func (h *DocHandler) GetDocUpdatesHandler(w http.ResponseWriter, r *http.Request) {
+	itemIDStr := chi.URLParam(r, "item_id")
+	itemID, err := strconv.Atoi(itemIDStr)
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	var after int64
+	if v := r.URL.Query().Get("after"); v != "" {
+		after, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || after < 0 {
+			http.Error(w, "Invalid after", http.StatusBadRequest)
+			return
+		}
+	}
+
+	updates, err := h.Docs.DocUpdatesSince(r.Context(), itemID, after)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrCompacted):
+			http.Error(w, "Updates have been compacted - fetch the document snapshot", http.StatusGone)
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "Item not found", http.StatusNotFound)
+		default:
+			log.Printf("Error getting document updates: %v", err)
+			http.Error(w, "Failed to get document updates", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updates)
+}
+
+func (h *DocHandler) UpdateDocHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	itemIDStr := chi.URLParam(r, "item_id")
+	itemID, err := strconv.Atoi(itemIDStr)
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	var update crdt.Update
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := update.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if update.Site == docSeedSite {
+		http.Error(w, "site "+docSeedSite+" is reserved", http.StatusBadRequest)
+		return
+	}
+
+	raw, err := json.Marshal(update)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var merged *crdt.Document
+	merge := func(snapshot, content, _ json.RawMessage) (json.RawMessage, json.RawMessage, error) {
+		doc, err := loadDocument(snapshot, content)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := doc.Apply(update); err != nil {
+			return nil, nil, err
+		}
+		if doc.Pending() > maxPendingOps {
+			return nil, nil, errTooManyPending
+		}
+
+		state, err := json.Marshal(doc)
+		if err != nil {
+			return nil, nil, err
+		}
+		mergedContent, err := json.Marshal(map[string]string{"text": doc.Text()})
+		if err != nil {
+			return nil, nil, err
+		}
+		merged = doc
+		return state, mergedContent, nil
+	}
+
+	docUpdate := models.DocUpdate{ItemID: itemID, UserID: userID, Update: raw}
+	stored, item, err := h.Docs.ApplyDocUpdate(r.Context(), &docUpdate, merge, docLogRetention)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "Item not found", http.StatusNotFound)
+		case errors.Is(err, errTooManyPending):
+			http.Error(w, "Conflict - "+err.Error(), http.StatusConflict)
+		default:
+			log.Printf("Error applying document update: %v", err)
+			http.Error(w, "Failed to apply document update", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	publishEvent(r, h.Events, h.Shares, models.Event{Type: models.EventDocUpdated, ItemID: itemID, Item: &item, DocUpdate: &docUpdate})
+
+	w.Header().Set("ETag", item.GenerateETag())
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(docResponse{
+		ItemID:       itemID,
+		Text:         merged.Text(),
+		Vector:       merged.Vector(),
+		Pending:      merged.Pending(),
+		LastUpdateID: stored.LastUpdateID,
+	})
+}
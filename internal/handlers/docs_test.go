@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/middleware"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+func newDocRouter(m *store.Memory) http.Handler {
+	h := &DocHandler{Docs: m, Shares: m}
+	r := chi.NewRouter()
+	r.Use(asUser)
+	r.With(middleware.Authorize(m, "can_view")).Get("/items/{item_id}/doc", h.GetDocHandler)
+	r.With(middleware.Authorize(m, "can_edit")).Post("/items/{item_id}/doc/updates", h.UpdateDocHandler)
+	return r
+}
+
+func TestFirstDocUpdateKeepsExistingText(t *testing.T) {
+	m := store.NewMemory()
+	owner := newTestUser(t, m, "owner")
+	router := newDocRouter(m)
+
+	note := models.Item{Name: "Plans", ItemType: models.ItemTypeNote, Content: json.RawMessage(`{"text": "hi"}`)}
+	if err := m.CreateItem(context.Background(), &note, owner); err != nil {
+		t.Fatal(err)
+	}
+	path := "/items/" + strconv.Itoa(note.ItemID) + "/doc"
+
+	// Before any update the document already holds the note's text
+	w := do(t, router, "GET", path, owner, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("get: got %d %s", w.Code, w.Body)
+	}
+	var doc docResponse
+	json.NewDecoder(w.Body).Decode(&doc)
+	if doc.Text != "hi" || doc.Vector[docSeedSite] != 2 {
+		t.Fatalf("get: got text %q vector %v", doc.Text, doc.Vector)
+	}
+
+	// Append after the seeded text, and insert at the start as a client
+	// that never fetched the document would
+	update := `{"site": "a", "ops": [
+		{"type": "ins", "id": {"c": 3, "s": "a"}, "after": {"c": 2, "s": "server"}, "text": "!"},
+		{"type": "ins", "id": {"c": 4, "s": "a"}, "after": {"c": 0, "s": ""}, "text": ">"}
+	]}`
+	w = do(t, router, "POST", path+"/updates", owner, update)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: got %d %s", w.Code, w.Body)
+	}
+	json.NewDecoder(w.Body).Decode(&doc)
+	if doc.Text != ">hi!" {
+		t.Errorf("update: got text %q, want %q", doc.Text, ">hi!")
+	}
+
+	item, err := m.GetItem(context.Background(), note.ItemID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var content struct {
+		Text string `json:"text"`
+	}
+	json.Unmarshal(item.Content, &content)
+	if content.Text != ">hi!" {
+		t.Errorf("content: got text %q, want %q", content.Text, ">hi!")
+	}
+}
+
+func TestDocUpdateRejectsSeedSite(t *testing.T) {
+	m := store.NewMemory()
+	owner := newTestUser(t, m, "owner")
+	router := newDocRouter(m)
+
+	note := models.Item{Name: "Plans", ItemType: models.ItemTypeNote, Content: json.RawMessage(`{"text": "hi"}`)}
+	if err := m.CreateItem(context.Background(), &note, owner); err != nil {
+		t.Fatal(err)
+	}
+
+	update := `{"site": "server", "ops": [{"type": "del", "id": {"c": 1, "s": "server"}}]}`
+	w := do(t, router, "POST", "/items/"+strconv.Itoa(note.ItemID)+"/doc/updates", owner, update)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"github.com/onyeepeace/todo-api/internal/events"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = (wsPongWait * 9) / 10
+
+	sseHeartbeat   = 30 * time.Second
+	sseReplayBatch = 500
+)
+
+// publishEvent stamps the event with the acting user and sends it. Unless the
+// caller already knows the audience, it is everyone with a role on the item.
+// Failing to publish never fails the request that made the change.
+func publishEvent(r *http.Request, publisher events.Publisher, shares store.ShareStore, event models.Event) {
+	if publisher == nil {
+		return
+	}
+	event.ActorID, _ = r.Context().Value(models.UserIDKey).(int)
+	event.CreatedAt = time.Now().UTC()
+
+	if event.Audience == nil {
+		audience, err := shares.ItemUserIDs(r.Context(), event.ItemID)
+		if err != nil {
+			log.Printf("Error getting audience for item %d: %v", event.ItemID, err)
+			return
+		}
+		event.Audience = audience
+	}
+
+	if err := publisher.Publish(r.Context(), event); err != nil {
+		log.Printf("Error publishing %s event for item %d: %v", event.Type, event.ItemID, err)
+	}
+}
+
+// FeedHandler streams change events to connected collaborators
+type FeedHandler struct {
+	Hub *events.Hub
+	Log events.EventLog
+	// Shares rechecks access when a subscriber's role on the item changes
+	Shares store.ShareStore
+	// CheckOrigin decides which browser origins may open a WebSocket
+	CheckOrigin func(r *http.Request) bool
+}
+
+// ItemWebSocketHandler streams the item's changes over a WebSocket for as
+// long as the user can view it. The socket is closed when their access is
+// revoked or the item is deleted.
+func (h *FeedHandler) ItemWebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	itemIDStr := chi.URLParam(r, "item_id")
+	itemID, err := strconv.Atoi(itemIDStr)
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	// Subscribing first means nothing published once the client sees the
+	// upgrade is missed. Events published after the user lost their role
+	// don't include them.
+	sub := h.Hub.Subscribe(events.ForItemAndUser(itemID, userID))
+	defer sub.Close()
+
+	upgrader := websocket.Upgrader{CheckOrigin: h.CheckOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written the error response
+		log.Printf("Error upgrading to WebSocket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// The read loop only exists to process control frames and notice when
+	// the client goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+
+		case event, ok := <-sub.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				// Dropped for falling behind; the client should reconnect and refetch
+				conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "subscriber too slow"))
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+			if event.Type == models.EventItemDeleted {
+				conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, "item deleted"))
+				return
+			}
+			if event.UserID == userID && !h.canStillView(r, event) {
+				conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "access revoked"))
+				return
+			}
+
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// canStillView reports whether the subscriber can still view the item after
+// a collaborator event about them
+func (h *FeedHandler) canStillView(r *http.Request, event models.Event) bool {
+	switch event.Type {
+	case models.EventCollaboratorRemoved:
+		return false
+	case models.EventCollaboratorChanged:
+		allowed, err := h.Shares.HasPermission(r.Context(), event.UserID, event.ItemID, "can_view")
+		if err != nil {
+			log.Printf("Error rechecking access to item %d: %v", event.ItemID, err)
+			return false
+		}
+		return allowed
+	}
+	return true
+}
+
+// EventStreamHandler streams the changes to every item the user has a role on
+// as Server-Sent Events. Clients resume with Last-Event-ID, or the
+// last_event_id query parameter, and get the events they missed replayed.
+func (h *FeedHandler) EventStreamHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	var lastEventID int64
+	resume := r.Header.Get("Last-Event-ID")
+	if resume == "" {
+		resume = r.URL.Query().Get("last_event_id")
+	}
+	if resume != "" {
+		id, err := strconv.ParseInt(resume, 10, 64)
+		if err != nil || id < 0 {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastEventID = id
+	}
+
+	// Subscribe before replaying so nothing published in between is missed;
+	// duplicates are skipped by event ID below
+	sub := h.Hub.Subscribe(events.ForUser(userID))
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	if resume != "" {
+		for {
+			missed, err := h.Log.Since(r.Context(), userID, lastEventID, sseReplayBatch)
+			if err != nil {
+				log.Printf("Error replaying events: %v", err)
+				return
+			}
+			for _, event := range missed {
+				if err := writeSSE(w, event); err != nil {
+					return
+				}
+				lastEventID = event.EventID
+			}
+			if len(missed) < sseReplayBatch {
+				break
+			}
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case event, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client reconnects with
+				// Last-Event-ID and catches up from the log
+				return
+			}
+			if event.EventID != 0 && event.EventID <= lastEventID {
+				continue
+			}
+			if err := writeSSE(w, event); err != nil {
+				return
+			}
+			if event.EventID != 0 {
+				lastEventID = event.EventID
+			}
+			flusher.Flush()
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, event models.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if event.EventID != 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", event.EventID); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"github.com/onyeepeace/todo-api/internal/events"
+	"github.com/onyeepeace/todo-api/internal/middleware"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+func TestItemWebSocketClosesWhenAccessRevoked(t *testing.T) {
+	m := store.NewMemory()
+	owner := newTestUser(t, m, "owner")
+	friend := newTestUser(t, m, "friend")
+
+	hub := events.NewHub()
+	items := newTestItemHandler(m)
+	items.Events = events.NewMemoryBroker(hub)
+	feed := &FeedHandler{Hub: hub, Shares: m}
+
+	r := chi.NewRouter()
+	r.Use(asUser)
+	r.With(middleware.Authorize(m, "can_view")).Get("/items/{item_id}/ws", feed.ItemWebSocketHandler)
+	r.With(middleware.Authorize(m, "can_edit")).Put("/items/{item_id}", items.EditItemHandler)
+	r.With(middleware.Authorize(m, "can_share")).Delete("/items/{item_id}/collaborators/{user_id}", items.RemoveCollaboratorHandler)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	item := models.Item{Name: "Groceries", ItemType: models.ItemTypeTodoList, Content: json.RawMessage(`[]`)}
+	if err := m.CreateItem(context.Background(), &item, owner); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ShareItem(context.Background(), item.ItemID, friend, "viewer", owner); err != nil {
+		t.Fatal(err)
+	}
+	path := "/items/" + strconv.Itoa(item.ItemID)
+
+	header := http.Header{"X-Test-User": {strconv.Itoa(friend)}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+path+"/ws", header)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if w := do(t, r, "PUT", path, owner, `{"name": "Shopping", "content": []}`); w.Code != http.StatusOK {
+		t.Fatalf("edit: got %d %s", w.Code, w.Body)
+	}
+	var event models.Event
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatalf("reading update: %v", err)
+	}
+	if event.Type != models.EventItemUpdated {
+		t.Fatalf("got %s event, want %s", event.Type, models.EventItemUpdated)
+	}
+
+	if w := do(t, r, "DELETE", path+"/collaborators/"+strconv.Itoa(friend), owner, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("revoke: got %d %s", w.Code, w.Body)
+	}
+	do(t, r, "PUT", path, owner, `{"name": "Secret plans", "content": []}`)
+
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatalf("reading removal event: %v", err)
+	}
+	if event.Type != models.EventCollaboratorRemoved || event.UserID != friend {
+		t.Fatalf("got %s event about user %d, want %s about %d", event.Type, event.UserID, models.EventCollaboratorRemoved, friend)
+	}
+
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Fatalf("got %v, want the socket closed for a policy violation", err)
+	}
+}
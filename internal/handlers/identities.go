@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/oauth"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+// ListIdentitiesHandler lists the provider accounts the current user can
+// sign in with
+func (h *UserHandler) ListIdentitiesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	identities, err := h.Identities.ListIdentities(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error listing identities: %v", err)
+		http.Error(w, "Failed to retrieve identities", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(identities)
+}
+
+// LinkIdentityHandler adds another provider account to the current user.
+// The client starts a sign-in at /api/auth/{provider}/login as usual and
+// sends the code and state the provider returns here instead of to the
+// callback.
+func (h *UserHandler) LinkIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var body struct {
+		Provider string `json:"provider"`
+		Code     string `json:"code"`
+		State    string `json:"state"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if body.Code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+
+	provider, err := h.Providers.Get(body.Provider)
+	if err != nil {
+		http.Error(w, "Unknown identity provider", http.StatusBadRequest)
+		return
+	}
+
+	flow, err := h.States.Finish(w, r, provider.Name(), body.State)
+	if err != nil {
+		if errors.Is(err, oauth.ErrInvalidState) {
+			log.Printf("Rejected identity link: %v", err)
+			http.Error(w, "Invalid or expired sign-in - please try again", http.StatusBadRequest)
+		} else {
+			log.Printf("Error checking sign-in state: %v", err)
+			http.Error(w, "Failed to link identity", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	identity, err := provider.Exchange(r.Context(), body.Code, flow)
+	if err != nil {
+		log.Printf("Error linking identity: %v", err)
+		http.Error(w, "Failed to sign in with "+body.Provider, http.StatusUnauthorized)
+		return
+	}
+
+	status := http.StatusCreated
+	linked := models.Identity{
+		UserID:        userID,
+		Provider:      identity.Provider,
+		Subject:       identity.Subject,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+	}
+	err = h.Identities.LinkIdentity(r.Context(), &linked)
+	if errors.Is(err, store.ErrConflict) {
+		// Linking the same account twice is fine; taking someone else's isn't
+		existing, getErr := h.Identities.GetIdentity(r.Context(), identity.Provider, identity.Subject)
+		if getErr == nil && existing.UserID == userID {
+			linked, err, status = existing, nil, http.StatusOK
+		}
+	}
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			http.Error(w, "This "+identity.Provider+" account is linked to another user", http.StatusConflict)
+		} else {
+			log.Printf("Error linking identity: %v", err)
+			http.Error(w, "Failed to link identity", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(linked)
+}
+
+// UnlinkIdentityHandler removes one of the current user's provider accounts.
+// The last one can't be removed.
+func (h *UserHandler) UnlinkIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	identityID, err := strconv.Atoi(chi.URLParam(r, "identity_id"))
+	if err != nil {
+		http.Error(w, "Invalid identity ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Identities.UnlinkIdentity(r.Context(), userID, identityID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "Identity not found", http.StatusNotFound)
+		case errors.Is(err, store.ErrLastIdentity):
+			http.Error(w, "Conflict - this is the only way left to sign in", http.StatusConflict)
+		default:
+			log.Printf("Error unlinking identity: %v", err)
+			http.Error(w, "Failed to unlink identity", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	netmail "net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/mail"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+// invitationTTL is how long an invitation's link works
+const invitationTTL = 7 * 24 * time.Hour
+
+// InviteHandler is sharing an item with an email address rather than a user
+// ID. Someone who already has an account with that address verified gets
+// the role straight away; anyone else is emailed a link, and gets the role
+// when they accept it or first sign in with the address verified.
+func (h *ItemHandler) InviteHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	itemID, err := strconv.Atoi(chi.URLParam(r, "item_id"))
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	body.Email = strings.TrimSpace(body.Email)
+	if address, err := netmail.ParseAddress(body.Email); err != nil || address.Address != body.Email || len(body.Email) > 255 {
+		http.Error(w, "A valid email address is required", http.StatusBadRequest)
+		return
+	}
+	if !checkRole(w, r, h.Roles, body.Role) {
+		return
+	}
+
+	existing, err := h.Identities.GetUserByVerifiedEmail(r.Context(), body.Email)
+	if err == nil {
+		h.shareWithUser(w, r, itemID, userID, existing.UserID, body.Role)
+		return
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Error looking up user by email: %v", err)
+		http.Error(w, "Failed to send invitation", http.StatusInternalServerError)
+		return
+	}
+
+	// Only owners can invite owners
+	if !h.checkOwnerChange(w, r, itemID, userID, 0, body.Role) {
+		return
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	invitation := models.Invitation{
+		ItemID:    itemID,
+		Email:     body.Email,
+		Role:      body.Role,
+		InvitedBy: userID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(invitationTTL),
+	}
+	if err := h.Invitations.CreateInvitation(r.Context(), &invitation); err != nil {
+		log.Printf("Error creating invitation: %v", err)
+		http.Error(w, "Failed to send invitation", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.sendInvitation(r, invitation, token); err != nil {
+		log.Printf("Error emailing invitation %d: %v", invitation.InvitationID, err)
+		http.Error(w, "Failed to email the invitation - please try again", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invitation)
+}
+
+// shareWithUser gives an existing user the role, as ShareItemHandler does,
+// and responds with them as a collaborator
+func (h *ItemHandler) shareWithUser(w http.ResponseWriter, r *http.Request, itemID, userID, targetID int, role string) {
+	if !h.checkOwnerChange(w, r, itemID, userID, targetID, role) {
+		return
+	}
+
+	if err := h.Shares.ShareItem(r.Context(), itemID, targetID, role, userID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "User not found", http.StatusNotFound)
+		case errors.Is(err, store.ErrLastOwner):
+			writeLastOwner(w)
+		default:
+			log.Printf("Error updating user role: %v", err)
+			http.Error(w, "Failed to update user role", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.publishCollaboratorEvent(r, models.EventCollaboratorChanged, itemID, targetID)
+	h.writeCollaborator(w, r, itemID, targetID)
+}
+
+// sendInvitation emails the invitation's link to the address it was made for
+func (h *ItemHandler) sendInvitation(r *http.Request, invitation models.Invitation, token string) error {
+	item, err := h.Items.GetItem(r.Context(), invitation.ItemID)
+	if err != nil {
+		return err
+	}
+	inviter, err := h.Users.GetUser(r.Context(), invitation.InvitedBy)
+	if err != nil {
+		return err
+	}
+
+	return h.Mail.Send(r.Context(), mail.Message{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("%s shared %q with you", inviter.Username, item.Name),
+		Body: fmt.Sprintf(
+			"%s (%s) invited you to %q as %s.\n\nAccept the invitation here:\n%s%s\n\nThe link expires on %s.\n",
+			inviter.Username, inviter.Email, item.Name, invitation.Role,
+			h.InvitationURL, token, invitation.ExpiresAt.Format("2 January 2006"),
+		),
+	})
+}
+
+// ListInvitationsHandler lists the item's pending invitations
+func (h *ItemHandler) ListInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "item_id"))
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	invitations, err := h.Invitations.ListInvitations(r.Context(), itemID)
+	if err != nil {
+		log.Printf("Error listing invitations: %v", err)
+		http.Error(w, "Failed to retrieve invitations", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invitations)
+}
+
+// RevokeInvitationHandler withdraws one of the item's invitations, so its
+// link stops working
+func (h *ItemHandler) RevokeInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "item_id"))
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+	invitationID, err := strconv.Atoi(chi.URLParam(r, "invitation_id"))
+	if err != nil {
+		http.Error(w, "Invalid invitation ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Invitations.RevokeInvitation(r.Context(), itemID, invitationID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Invitation not found", http.StatusNotFound)
+		} else {
+			log.Printf("Error revoking invitation: %v", err)
+			http.Error(w, "Failed to revoke invitation", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptInvitationHandler gives the current user the role an invitation
+// offers, whatever email address they signed in with. Whoever has the link
+// can accept it, once.
+func (h *ItemHandler) AcceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	token, ok := invitationToken(w, r)
+	if !ok {
+		return
+	}
+
+	invitation, err := h.Invitations.AcceptInvitation(r.Context(), hashToken(token), userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Invalid or expired invitation", http.StatusNotFound)
+		} else {
+			log.Printf("Error accepting invitation: %v", err)
+			http.Error(w, "Failed to accept invitation", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeCollaborator(w, r, invitation.ItemID, userID)
+}
+
+// DeclineInvitationHandler turns an invitation down. It needs no sign-in,
+// so people without an account can decline too.
+func (h *ItemHandler) DeclineInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	token, ok := invitationToken(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Invitations.DeclineInvitation(r.Context(), hashToken(token)); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Invalid or expired invitation", http.StatusNotFound)
+		} else {
+			log.Printf("Error declining invitation: %v", err)
+			http.Error(w, "Failed to decline invitation", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// invitationToken reads the invitation token from the request body, where
+// it stays out of access logs
+func invitationToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return "", false
+	}
+	if body.Token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return "", false
+	}
+	return body.Token, true
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/middleware"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/oauth"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+func newInvitationRouter(m *store.Memory) http.Handler {
+	h := newTestItemHandler(m)
+	r := chi.NewRouter()
+	r.Use(asUser)
+	r.With(middleware.Authorize(m, "can_share")).Post("/items/{item_id}/invitations", h.InviteHandler)
+	r.With(middleware.Authorize(m, "can_share")).Get("/items/{item_id}/invitations", h.ListInvitationsHandler)
+	r.With(middleware.Authorize(m, "can_share")).Delete("/items/{item_id}/invitations/{invitation_id}", h.RevokeInvitationHandler)
+	r.Post("/invitations/accept", h.AcceptInvitationHandler)
+	r.Post("/invitations/decline", h.DeclineInvitationHandler)
+	return r
+}
+
+// fakeProvider signs everyone in as the same identity
+type fakeProvider struct {
+	identity oauth.Identity
+}
+
+func (p *fakeProvider) Name() string { return p.identity.Provider }
+
+func (p *fakeProvider) AuthCodeURL(flow oauth.Flow) (string, error) {
+	return "https://provider.example.com/authorize?state=" + flow.State, nil
+}
+
+func (p *fakeProvider) Exchange(ctx context.Context, code string, flow oauth.Flow) (oauth.Identity, error) {
+	return p.identity, nil
+}
+
+func (p *fakeProvider) SetCredentials(clientID, clientSecret string) {}
+
+// signInAs runs a whole sign-in through the provider callback as identity,
+// and returns the user it signed in
+func signInAs(t *testing.T, m *store.Memory, identity oauth.Identity) int {
+	t.Helper()
+	_, h := newAuthRouter(t, m)
+	h.Providers = oauth.NewRegistry(&fakeProvider{identity: identity})
+	h.States = &oauth.States{Keys: h.AccessTokens.Keys, Used: m}
+	r := chi.NewRouter()
+	r.Get("/auth/{provider}/callback", h.CallbackHandler)
+
+	begin := httptest.NewRecorder()
+	flow, err := h.States.Begin(begin, identity.Provider, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/auth/"+identity.Provider+"/callback?code=code&state="+flow.State, nil)
+	for _, cookie := range begin.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("sign in: got %d %s", w.Code, w.Body)
+	}
+
+	stored, err := m.GetIdentity(context.Background(), identity.Provider, identity.Subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored.UserID
+}
+
+// createInvitation invites email to the item with a known token, as
+// InviteHandler would have emailed it
+func createInvitation(t *testing.T, m *store.Memory, itemID, owner int, email, token string, expiresAt time.Time) models.Invitation {
+	t.Helper()
+	invitation := models.Invitation{
+		ItemID:    itemID,
+		Email:     email,
+		Role:      "editor",
+		InvitedBy: owner,
+		TokenHash: hashToken(token),
+		ExpiresAt: expiresAt,
+	}
+	if err := m.CreateInvitation(context.Background(), &invitation); err != nil {
+		t.Fatal(err)
+	}
+	return invitation
+}
+
+func listInvitations(t *testing.T, router http.Handler, itemID, owner int) []models.Invitation {
+	t.Helper()
+	w := do(t, router, "GET", "/items/"+strconv.Itoa(itemID)+"/invitations", owner, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("list invitations: got %d %s", w.Code, w.Body)
+	}
+	var invitations []models.Invitation
+	json.NewDecoder(w.Body).Decode(&invitations)
+	return invitations
+}
+
+func TestInviteExistingUser(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	friend := newTestUser(t, m, "friend")
+	router := newInvitationRouter(m)
+
+	w := do(t, router, "POST", "/items/"+strconv.Itoa(itemID)+"/invitations", owner, `{"email":"friend@example.com","role":"viewer"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	if role := roleOf(t, m, itemID, friend); role != "viewer" {
+		t.Errorf("friend has role %q, want viewer", role)
+	}
+	if invitations := listInvitations(t, router, itemID, owner); len(invitations) != 0 {
+		t.Errorf("got invitations %+v, want none", invitations)
+	}
+}
+
+func TestInviteByEmail(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	router := newInvitationRouter(m)
+
+	for _, body := range []string{`{"email":"not an address","role":"viewer"}`, `{"email":"new@example.com","role":"nobody"}`} {
+		if w := do(t, router, "POST", "/items/"+strconv.Itoa(itemID)+"/invitations", owner, body); w.Code != http.StatusBadRequest {
+			t.Errorf("invite %s: got %d, want 400", body, w.Code)
+		}
+	}
+
+	w := do(t, router, "POST", "/items/"+strconv.Itoa(itemID)+"/invitations", owner, `{"email":"new@example.com","role":"viewer"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	var invitation models.Invitation
+	json.NewDecoder(w.Body).Decode(&invitation)
+
+	invitations := listInvitations(t, router, itemID, owner)
+	if len(invitations) != 1 || invitations[0].InvitationID != invitation.InvitationID || invitations[0].Role != "viewer" {
+		t.Fatalf("got invitations %+v, want %+v", invitations, invitation)
+	}
+
+	path := "/items/" + strconv.Itoa(itemID) + "/invitations/" + strconv.Itoa(invitation.InvitationID)
+	if w := do(t, router, "DELETE", path, owner, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("revoke: got %d %s", w.Code, w.Body)
+	}
+	if w := do(t, router, "DELETE", path, owner, ""); w.Code != http.StatusNotFound {
+		t.Errorf("revoke again: got %d, want 404", w.Code)
+	}
+	if invitations := listInvitations(t, router, itemID, owner); len(invitations) != 0 {
+		t.Errorf("got invitations %+v after revoking, want none", invitations)
+	}
+}
+
+func TestSignInClaimsInvitations(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	createInvitation(t, m, itemID, owner, "New@Example.com", "token", time.Now().Add(time.Hour))
+	router := newInvitationRouter(m)
+
+	// The address matches whatever its case
+	userID := signInAs(t, m, oauth.Identity{Provider: "test", Subject: "new", Email: "new@example.com", EmailVerified: true})
+	if role := roleOf(t, m, itemID, userID); role != "editor" {
+		t.Errorf("new user has role %q, want editor", role)
+	}
+	if invitations := listInvitations(t, router, itemID, owner); len(invitations) != 0 {
+		t.Errorf("got invitations %+v after claiming, want none", invitations)
+	}
+	if w := do(t, router, "POST", "/invitations/accept", userID, `{"token":"token"}`); w.Code != http.StatusNotFound {
+		t.Errorf("accepting a claimed invitation: got %d, want 404", w.Code)
+	}
+}
+
+func TestUnverifiedSignInDoesNotClaimInvitations(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	createInvitation(t, m, itemID, owner, "new@example.com", "token", time.Now().Add(time.Hour))
+	router := newInvitationRouter(m)
+
+	userID := signInAs(t, m, oauth.Identity{Provider: "test", Subject: "new", Email: "new@example.com"})
+	if role := roleOf(t, m, itemID, userID); role != "" {
+		t.Errorf("unverified user has role %q, want none", role)
+	}
+	if invitations := listInvitations(t, router, itemID, owner); len(invitations) != 1 {
+		t.Errorf("got invitations %+v, want the one still pending", invitations)
+	}
+}
+
+func TestSignInDoesNotClaimExpiredInvitations(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	createInvitation(t, m, itemID, owner, "new@example.com", "token", time.Now().Add(-time.Minute))
+
+	userID := signInAs(t, m, oauth.Identity{Provider: "test", Subject: "new", Email: "new@example.com", EmailVerified: true})
+	if role := roleOf(t, m, itemID, userID); role != "" {
+		t.Errorf("user has role %q from an expired invitation, want none", role)
+	}
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	createInvitation(t, m, itemID, owner, "new@example.com", "token", time.Now().Add(time.Hour))
+	other := newTestUser(t, m, "other")
+	router := newInvitationRouter(m)
+
+	if w := do(t, router, "POST", "/invitations/accept", other, `{"token":"wrong"}`); w.Code != http.StatusNotFound {
+		t.Errorf("wrong token: got %d, want 404", w.Code)
+	}
+
+	// Whoever has the link can accept it, whatever their address
+	w := do(t, router, "POST", "/invitations/accept", other, `{"token":"token"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	var collaborator models.Collaborator
+	json.NewDecoder(w.Body).Decode(&collaborator)
+	if collaborator.UserID != other || collaborator.Role != "editor" {
+		t.Errorf("got %+v, want user %d as editor", collaborator, other)
+	}
+
+	if w := do(t, router, "POST", "/invitations/accept", other, `{"token":"token"}`); w.Code != http.StatusNotFound {
+		t.Errorf("accepting twice: got %d, want 404", w.Code)
+	}
+}
+
+func TestDeclineInvitation(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	createInvitation(t, m, itemID, owner, "new@example.com", "token", time.Now().Add(time.Hour))
+	other := newTestUser(t, m, "other")
+	router := newInvitationRouter(m)
+
+	// Declining needs no sign-in
+	if w := do(t, router, "POST", "/invitations/decline", 0, `{"token":"token"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	if w := do(t, router, "POST", "/invitations/accept", other, `{"token":"token"}`); w.Code != http.StatusNotFound {
+		t.Errorf("accepting a declined invitation: got %d, want 404", w.Code)
+	}
+	if role := roleOf(t, m, itemID, other); role != "" {
+		t.Errorf("user has role %q, want none", role)
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/itemtypes"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+var itemTypeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]{0,49}$`)
+
+// ItemTypeHandler serves the /api/item-types endpoints
+type ItemTypeHandler struct {
+	Types store.ItemTypeStore
+}
+
+func (h *ItemTypeHandler) ListItemTypesHandler(w http.ResponseWriter, r *http.Request) {
+	itemTypes, err := h.Types.ListItemTypes(r.Context())
+	if err != nil {
+		log.Printf("Error listing item types: %v", err)
+		http.Error(w, "Failed to retrieve item types", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(itemTypes)
+}
+
+func (h *ItemTypeHandler) GetItemTypeHandler(w http.ResponseWriter, r *http.Request) {
+	itemType, err := h.Types.GetItemType(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Item type not found", http.StatusNotFound)
+		} else {
+			log.Printf("Error getting item type: %v", err)
+			http.Error(w, "Failed to get item type", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(itemType)
+}
+
+// CreateItemTypeHandler registers a custom item type. Types can't be edited
+// afterwards, since existing items were validated against the schema.
+func (h *ItemTypeHandler) CreateItemTypeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	var itemType models.ItemType
+	if err := json.NewDecoder(r.Body).Decode(&itemType); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !itemTypeNamePattern.MatchString(itemType.Name) {
+		http.Error(w, "name must be lowercase letters, digits and dashes, starting with a letter", http.StatusBadRequest)
+		return
+	}
+	if itemType.Schema == nil {
+		http.Error(w, "schema is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := itemtypes.Compile(itemType.Name, itemType.Schema); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	itemType.CreatedBy = userID
+
+	if err := h.Types.CreateItemType(r.Context(), &itemType); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			http.Error(w, "Item type already exists", http.StatusConflict)
+		} else {
+			log.Printf("Error creating item type: %v", err)
+			http.Error(w, "Failed to create item type", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(itemType)
+}
@@ -1,87 +1,96 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"reflect"
 	"strconv"
 
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/go-chi/chi/v5"
-	"github.com/onyeepeace/todo-api/internal/db"
+	"github.com/onyeepeace/todo-api/internal/events"
+	"github.com/onyeepeace/todo-api/internal/itemtypes"
+	"github.com/onyeepeace/todo-api/internal/mail"
 	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
 )
 
-func GetItemsHandler(w http.ResponseWriter, r *http.Request) {
+// ItemHandler serves the /api/items endpoints
+type ItemHandler struct {
+	Items       store.ItemStore
+	Shares      store.ShareStore
+	Transfers   store.TransferStore
+	Invitations store.InvitationStore
+	Roles       store.RoleStore
+	Users       store.UserStore
+	Identities  store.IdentityStore
+	Events      events.Publisher
+	// Types validates content against the schema of the item's type
+	Types *itemtypes.Registry
+	// Mail delivers invitations to people who haven't signed up yet
+	Mail mail.Sender
+	// InvitationURL is the page invitation emails link to; the invitation's
+	// token is appended to it
+	InvitationURL string
+}
+
+// validateContent writes the error response itself and returns false when
+// content isn't acceptable for the item type
+func (h *ItemHandler) validateContent(w http.ResponseWriter, r *http.Request, itemType string, content json.RawMessage) bool {
+	err := h.Types.Validate(r.Context(), itemType, content)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, itemtypes.ErrUnknownType):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, itemtypes.ErrInvalidContent):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		log.Printf("Error validating item content: %v", err)
+		http.Error(w, "Failed to validate item content", http.StatusInternalServerError)
+	}
+	return false
+}
+
+func (h *ItemHandler) GetItemsHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(models.UserIDKey).(int)
 	if !ok {
 		http.Error(w, "User ID not found in context", http.StatusInternalServerError)
 		return
 	}
 
-	// Get all items where user has any role (owner, editor, or viewer)
-	query := `
-		SELECT DISTINCT 
-			i.item_id,
-			i.name,
-			i.content,
-			i.created_at,
-			i.updated_at,
-			r.name as role_name,
-			u.email as shared_by_email
-		FROM items i
-		JOIN user_roles ur ON i.item_id = ur.item_id
-		JOIN roles r ON ur.role_id = r.role_id
-		LEFT JOIN users u ON ur.created_by = u.user_id
-		WHERE ur.user_id = $1
-		ORDER BY i.created_at DESC
-	`
-	rows, err := db.Query(query, userID)
+	query, err := parseItemQuery(r, userID)
 	if err != nil {
-		log.Printf("Error retrieving items: %v", err)
-		http.Error(w, "Failed to retrieve items", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer rows.Close()
-
-	type ItemWithAccess struct {
-		models.Item
-		Role      string `json:"role"`       // owner, editor, or viewer
-		SharedBy  string `json:"shared_by"`  // email of user who shared it (null if owner)
-	}
 
-	var items []ItemWithAccess
-	for rows.Next() {
-		var item ItemWithAccess
-		var sharedByEmail sql.NullString // Use sql.NullString for potentially null shared_by_email
+	// Fetch one extra row to find out whether there is a next page
+	limit := query.Limit
+	query.Limit++
 
-		if err := rows.Scan(
-			&item.ItemID,
-			&item.Name,
-			&item.Content,
-			&item.CreatedAt,
-			&item.UpdatedAt,
-			&item.Role,
-			&sharedByEmail,
-		); err != nil {
-			log.Printf("Error scanning item: %v", err)
-			http.Error(w, "Failed to scan item", http.StatusInternalServerError)
-			return
-		}
-
-		// Only set SharedBy if the item was shared (role is not owner)
-		if item.Role != "owner" && sharedByEmail.Valid {
-			item.SharedBy = sharedByEmail.String
-		}
+	items, err := h.Items.ListItems(r.Context(), query)
+	if err != nil {
+		log.Printf("Error retrieving items: %v", err)
+		http.Error(w, "Failed to retrieve items", http.StatusInternalServerError)
+		return
+	}
 
-		items = append(items, item)
+	if len(items) > limit {
+		items = items[:limit]
+		setNextLink(w, r, encodeItemCursor(query, query.CursorFor(items[limit-1])))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(items)
 }
 
-func CreateItemHandler(w http.ResponseWriter, r *http.Request) {
+func (h *ItemHandler) CreateItemHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(models.UserIDKey).(int)
 	if !ok {
 		log.Printf("Error: User ID not found in context")
@@ -96,62 +105,31 @@ func CreateItemHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Ensure content is valid JSON
+	// Items created before there were types were all todo lists
+	if item.ItemType == "" {
+		item.ItemType = models.ItemTypeTodoList
+	}
 	if item.Content == nil {
-		item.Content = json.RawMessage("[]")
+		item.Content = models.DefaultContent(item.ItemType)
 	}
-
-	// Start transaction
-	tx, err := db.DB().Begin()
-	if err != nil {
-		log.Printf("Error starting transaction: %v", err)
-		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+	if !h.validateContent(w, r, item.ItemType, item.Content) {
 		return
 	}
-	defer tx.Rollback() // Rollback if we don't commit
 
-	// Create item
-	err = tx.QueryRow(
-		"INSERT INTO items (name, content) VALUES ($1, $2::jsonb) RETURNING item_id, name, content, created_at, updated_at",
-		item.Name, item.Content,
-	).Scan(&item.ItemID, &item.Name, &item.Content, &item.CreatedAt, &item.UpdatedAt)
-	if err != nil {
+	if err := h.Items.CreateItem(r.Context(), &item, userID); err != nil {
 		log.Printf("Error creating item: %v", err)
 		http.Error(w, "Failed to create item", http.StatusInternalServerError)
 		return
 	}
 
-	// Get owner role ID
-	var ownerRoleID int
-	err = tx.QueryRow("SELECT role_id FROM roles WHERE name = 'owner'").Scan(&ownerRoleID)
-	if err != nil {
-		log.Printf("Error getting owner role: %v", err)
-		http.Error(w, "Failed to get owner role", http.StatusInternalServerError)
-		return
-	}
-
-	// Assign owner role to creator
-	_, err = tx.Exec(
-		"INSERT INTO user_roles (item_id, user_id, role_id, created_by) VALUES ($1, $2, $3, $2)",
-		item.ItemID, userID, ownerRoleID,
-	)
-	if err != nil {
-		log.Printf("Error assigning owner role: %v", err)
-		http.Error(w, "Failed to assign owner role", http.StatusInternalServerError)
-		return
-	}
-
-	if err := tx.Commit(); err != nil {
-		log.Printf("Error committing transaction: %v", err)
-		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
-		return
-	}
+	publishEvent(r, h.Events, h.Shares, models.Event{Type: models.EventItemCreated, ItemID: item.ItemID, Item: &item})
 
+	w.Header().Set("ETag", item.GenerateETag())
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(item)
 }
 
-func GetItemByIDHandler(w http.ResponseWriter, r *http.Request) {
+func (h *ItemHandler) GetItemByIDHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(models.UserIDKey).(int)
 	if !ok {
 		http.Error(w, "User ID not found in context", http.StatusInternalServerError)
@@ -165,44 +143,9 @@ func GetItemByIDHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Query item with user's role and who shared it
-	query := `
-		SELECT 
-			i.item_id,
-			i.name,
-			i.content,
-			i.created_at,
-			i.updated_at,
-			r.name as role_name,
-			u.email as shared_by_email
-		FROM items i
-		JOIN user_roles ur ON i.item_id = ur.item_id
-		JOIN roles r ON ur.role_id = r.role_id
-		LEFT JOIN users u ON ur.created_by = u.user_id
-		WHERE i.item_id = $1 AND ur.user_id = $2
-	`
-	row := db.QueryRow(query, itemID, userID)
-
-	type ItemWithAccess struct {
-		models.Item
-		Role     string `json:"role"`      // owner, editor, or viewer
-		SharedBy string `json:"shared_by"` // email of user who shared it (null if owner)
-	}
-
-	var item ItemWithAccess
-	var sharedByEmail sql.NullString
-
-	err = row.Scan(
-		&item.ItemID,
-		&item.Name,
-		&item.Content,
-		&item.CreatedAt,
-		&item.UpdatedAt,
-		&item.Role,
-		&sharedByEmail,
-	)
+	item, err := h.Items.GetItemForUser(r.Context(), itemID, userID)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, store.ErrNotFound) {
 			http.Error(w, "Item not found", http.StatusNotFound)
 		} else {
 			log.Printf("Error scanning item: %v", err)
@@ -211,11 +154,6 @@ func GetItemByIDHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Only set SharedBy if the item was shared (role is not owner)
-	if item.Role != "owner" && sharedByEmail.Valid {
-		item.SharedBy = sharedByEmail.String
-	}
-
 	// Generate ETag
 	etag := item.Item.GenerateETag()
 	w.Header().Set("ETag", etag)
@@ -235,9 +173,46 @@ func GetItemByIDHandler(w http.ResponseWriter, r *http.Request) {
 type UpdateItemRequest struct {
 	Name    string          `json:"name"`
 	Content json.RawMessage `json:"content"`
+	// ItemType may be sent back unchanged; an item's type is fixed
+	ItemType string `json:"item_type"`
+	// Version is the version the client edited, used when If-Match is absent
+	Version int `json:"version"`
 }
 
-func EditItemHandler(w http.ResponseWriter, r *http.Request) {
+// expectedItemVersion works out which version a write to the item is
+// conditioned on, preferring If-Match over the version sent in the body. 0
+// means the write is unconditional.
+func expectedItemVersion(r *http.Request, itemID, bodyVersion int) (int, error) {
+	match := r.Header.Get("If-Match")
+	if match == "" {
+		return bodyVersion, nil
+	}
+	if match == "*" {
+		return 0, nil
+	}
+	version, ok := models.ParseItemETag(itemID, match)
+	if !ok {
+		return 0, errors.New("If-Match does not identify a version of this item")
+	}
+	return version, nil
+}
+
+// writeItemConflict returns 409 with the server's current copy of the item
+// so the client can merge its change and retry
+func writeItemConflict(w http.ResponseWriter, conflict *store.ItemConflictError) {
+	current := conflict.Current
+	current.ETag = current.GenerateETag()
+
+	w.Header().Set("ETag", current.ETag)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":   "Conflict - Item has been modified",
+		"current": current,
+	})
+}
+
+func (h *ItemHandler) EditItemHandler(w http.ResponseWriter, r *http.Request) {
 	itemIDStr := chi.URLParam(r, "item_id")
 	itemID, err := strconv.Atoi(itemIDStr)
 	if err != nil {
@@ -251,66 +226,54 @@ func EditItemHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Start transaction
-	tx, err := db.DB().Begin()
-	if err != nil {
-		log.Printf("Error starting transaction: %v", err)
-		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
-		return
-	}
-	defer tx.Rollback()
-
-	// Get current item state
-	var currentItem models.Item
-	err = tx.QueryRow(`
-		SELECT item_id, name, content, created_at, updated_at
-		FROM items WHERE item_id = $1
-	`, itemID).Scan(
-		&currentItem.ItemID,
-		&currentItem.Name,
-		&currentItem.Content,
-		&currentItem.CreatedAt,
-		&currentItem.UpdatedAt,
-	)
+	version, err := expectedItemVersion(r, itemID, updateReq.Version)
 	if err != nil {
-		log.Printf("Error getting current item state: %v", err)
-		http.Error(w, "Failed to get current item state", http.StatusInternalServerError)
+		http.Error(w, "Precondition Failed - "+err.Error(), http.StatusPreconditionFailed)
 		return
 	}
 
-	// Check If-Match header
-	if match := r.Header.Get("If-Match"); match != "" {
-		if !currentItem.ValidateETag(match) {
-			http.Error(w, "Precondition Failed - Item has been modified", http.StatusPreconditionFailed)
+	current, err := h.Items.GetItem(r.Context(), itemID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Item not found", http.StatusNotFound)
+		} else {
+			log.Printf("Error getting item: %v", err)
+			http.Error(w, "Failed to update item", http.StatusInternalServerError)
+		}
+		return
+	}
+	if updateReq.ItemType != "" && updateReq.ItemType != current.ItemType {
+		http.Error(w, "item_type can't be changed", http.StatusBadRequest)
+		return
+	}
+	if current.ItemType == models.ItemTypeNote {
+		// A note can be renamed here, sending its content back unchanged or
+		// not at all, but its text is edited through its document
+		if updateReq.Content != nil && !sameJSON(updateReq.Content, current.Content) {
+			http.Error(w, "Conflict - "+errNoteContent.Error(), http.StatusConflict)
 			return
 		}
+		updateReq.Content = nil
+	} else if !h.validateContent(w, r, current.ItemType, updateReq.Content) {
+		return
 	}
 
-	// Update the item
-	var updatedItem models.Item
-	err = tx.QueryRow(`
-		UPDATE items 
-		SET name = $1, content = $2::jsonb, updated_at = NOW()
-		WHERE item_id = $3 
-		RETURNING item_id, name, content, created_at, updated_at
-	`, updateReq.Name, updateReq.Content, itemID).Scan(
-		&updatedItem.ItemID,
-		&updatedItem.Name,
-		&updatedItem.Content,
-		&updatedItem.CreatedAt,
-		&updatedItem.UpdatedAt,
-	)
+	updatedItem, err := h.Items.UpdateItem(r.Context(), itemID, updateReq.Name, updateReq.Content, version)
 	if err != nil {
-		log.Printf("Error updating item: %v", err)
-		http.Error(w, "Failed to update item", http.StatusInternalServerError)
+		var conflict *store.ItemConflictError
+		switch {
+		case errors.As(err, &conflict):
+			writeItemConflict(w, conflict)
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "Item not found", http.StatusNotFound)
+		default:
+			log.Printf("Error updating item: %v", err)
+			http.Error(w, "Failed to update item", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		log.Printf("Error committing transaction: %v", err)
-		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
-		return
-	}
+	publishEvent(r, h.Events, h.Shares, models.Event{Type: models.EventItemUpdated, ItemID: itemID, Item: &updatedItem})
 
 	// Generate new ETag
 	etag := updatedItem.GenerateETag()
@@ -319,7 +282,29 @@ func EditItemHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(updatedItem)
 }
 
-func DeleteItemHandler(w http.ResponseWriter, r *http.Request) {
+// Media types accepted by PatchItemHandler
+const (
+	jsonPatchMediaType  = "application/json-patch+json"
+	mergePatchMediaType = "application/merge-patch+json"
+)
+
+// errPatchFailed marks errors from applying a patch to the stored content
+var errPatchFailed = errors.New("patch could not be applied")
+
+// errNoteContent rejects changes to a note's text outside its document,
+// which the next document update would overwrite
+var errNoteContent = errors.New("a note's text can only be changed through /api/items/{item_id}/doc/updates")
+
+// sameJSON reports whether a and b encode the same value
+func sameJSON(a, b json.RawMessage) bool {
+	var x, y interface{}
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+func (h *ItemHandler) PatchItemHandler(w http.ResponseWriter, r *http.Request) {
 	itemIDStr := chi.URLParam(r, "item_id")
 	itemID, err := strconv.Atoi(itemIDStr)
 	if err != nil {
@@ -327,55 +312,128 @@ func DeleteItemHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Start transaction
-	tx, err := db.DB().Begin()
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != jsonPatchMediaType && mediaType != mergePatchMediaType {
+		w.Header().Set("Accept-Patch", jsonPatchMediaType+", "+mergePatchMediaType)
+		http.Error(w, "Unsupported Media Type - use "+jsonPatchMediaType+" or "+mergePatchMediaType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil || !json.Valid(body) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var apply store.ContentPatch
+	if mediaType == jsonPatchMediaType {
+		// RFC 6902: a list of operations, applied all or nothing
+		operations, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			http.Error(w, "Invalid JSON Patch document", http.StatusBadRequest)
+			return
+		}
+		apply = func(current models.Item) (json.RawMessage, error) {
+			patched, err := operations.Apply(current.Content)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", errPatchFailed, err)
+			}
+			return patched, h.Types.Validate(r.Context(), current.ItemType, patched)
+		}
+	} else {
+		// RFC 7396: a partial document where null removes a member
+		apply = func(current models.Item) (json.RawMessage, error) {
+			patched, err := jsonpatch.MergePatch(current.Content, body)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", errPatchFailed, err)
+			}
+			return patched, h.Types.Validate(r.Context(), current.ItemType, patched)
+		}
+	}
+
+	patch := func(current models.Item) (json.RawMessage, error) {
+		patched, err := apply(current)
+		if err == nil && current.ItemType == models.ItemTypeNote && !sameJSON(patched, current.Content) {
+			return nil, errNoteContent
+		}
+		return patched, err
+	}
+
+	version, err := expectedItemVersion(r, itemID, 0)
 	if err != nil {
-		log.Printf("Error starting transaction: %v", err)
-		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		http.Error(w, "Precondition Failed - "+err.Error(), http.StatusPreconditionFailed)
 		return
 	}
-	defer tx.Rollback()
 
-	// Delete associated todos first
-	_, err = tx.Exec("DELETE FROM todos WHERE item_id = $1", itemID)
+	updatedItem, err := h.Items.PatchItem(r.Context(), itemID, patch, version)
 	if err != nil {
-		log.Printf("Error deleting todos: %v", err)
-		http.Error(w, "Failed to delete todos", http.StatusInternalServerError)
+		var conflict *store.ItemConflictError
+		switch {
+		case errors.As(err, &conflict):
+			writeItemConflict(w, conflict)
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "Item not found", http.StatusNotFound)
+		case errors.Is(err, errPatchFailed), errors.Is(err, itemtypes.ErrInvalidContent):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case errors.Is(err, errNoteContent):
+			http.Error(w, "Conflict - "+err.Error(), http.StatusConflict)
+		default:
+			log.Printf("Error patching item: %v", err)
+			http.Error(w, "Failed to update item", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	// Delete user roles
-	_, err = tx.Exec("DELETE FROM user_roles WHERE item_id = $1", itemID)
+	publishEvent(r, h.Events, h.Shares, models.Event{Type: models.EventItemUpdated, ItemID: itemID, Item: &updatedItem})
+
+	etag := updatedItem.GenerateETag()
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updatedItem)
+}
+
+func (h *ItemHandler) DeleteItemHandler(w http.ResponseWriter, r *http.Request) {
+	itemIDStr := chi.URLParam(r, "item_id")
+	itemID, err := strconv.Atoi(itemIDStr)
 	if err != nil {
-		log.Printf("Error deleting user roles: %v", err)
-		http.Error(w, "Failed to delete user roles", http.StatusInternalServerError)
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
 		return
 	}
 
-	// Delete the item
-	result, err := tx.Exec("DELETE FROM items WHERE item_id = $1", itemID)
+	version, err := expectedItemVersion(r, itemID, 0)
 	if err != nil {
-		log.Printf("Error deleting item: %v", err)
-		http.Error(w, "Failed to delete item", http.StatusInternalServerError)
+		http.Error(w, "Precondition Failed - "+err.Error(), http.StatusPreconditionFailed)
 		return
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil || rowsAffected == 0 {
-		http.Error(w, "Item not found", http.StatusNotFound)
+	// Collaborators lose their roles with the item, so find them first
+	audience, err := h.Shares.ItemUserIDs(r.Context(), itemID)
+	if err != nil {
+		log.Printf("Error getting item collaborators: %v", err)
+		http.Error(w, "Failed to delete item", http.StatusInternalServerError)
 		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		log.Printf("Error committing transaction: %v", err)
-		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+	if err := h.Items.DeleteItem(r.Context(), itemID, version); err != nil {
+		var conflict *store.ItemConflictError
+		switch {
+		case errors.As(err, &conflict):
+			writeItemConflict(w, conflict)
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "Item not found", http.StatusNotFound)
+		default:
+			log.Printf("Error deleting item: %v", err)
+			http.Error(w, "Failed to delete item", http.StatusInternalServerError)
+		}
 		return
 	}
 
+	publishEvent(r, h.Events, h.Shares, models.Event{Type: models.EventItemDeleted, ItemID: itemID, Audience: audience})
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func ShareItemHandler(w http.ResponseWriter, r *http.Request) {
+func (h *ItemHandler) ShareItemHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(models.UserIDKey).(int)
 	if !ok {
 		http.Error(w, "User ID not found in context", http.StatusInternalServerError)
@@ -392,7 +450,7 @@ func ShareItemHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
 	var shareRequest struct {
 		UserID int    `json:"user_id"` // ID of user to share with
-		Role   string `json:"role"`    // editor or viewer
+		Role   string `json:"role"`    // owner, editor, viewer or a custom role
 	}
 	if err := json.NewDecoder(r.Body).Decode(&shareRequest); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -404,82 +462,31 @@ func ShareItemHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "user_id is required", http.StatusBadRequest)
 		return
 	}
-	if shareRequest.Role == "" {
-		http.Error(w, "role is required", http.StatusBadRequest)
-		return
-	}
-
-	// Validate role
-	if shareRequest.Role != "editor" && shareRequest.Role != "viewer" {
-		http.Error(w, "Invalid role. Must be 'editor' or 'viewer'", http.StatusBadRequest)
-		return
-	}
-
-	log.Printf("Attempting to share item %d with user ID: %d, role: %s", itemID, shareRequest.UserID, shareRequest.Role)
-
-	// Start transaction
-	tx, err := db.DB().Begin()
-	if err != nil {
-		log.Printf("Error starting transaction: %v", err)
-		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
-		return
-	}
-	defer tx.Rollback()
 
-	// Verify user exists
-	var exists bool
-	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1)", shareRequest.UserID).Scan(&exists)
-	if err != nil {
-		log.Printf("Error verifying user existence: %v", err)
-		http.Error(w, "Failed to verify user", http.StatusInternalServerError)
-		return
-	}
-	if !exists {
-		http.Error(w, "User not found", http.StatusNotFound)
+	// Any defined role can be given, built-in or custom
+	if !checkRole(w, r, h.Roles, shareRequest.Role) {
 		return
 	}
 
-	// Get role ID
-	var roleID int
-	err = tx.QueryRow("SELECT role_id FROM roles WHERE name = $1", shareRequest.Role).Scan(&roleID)
-	if err != nil {
-		log.Printf("Error getting role: %v", err)
-		http.Error(w, "Failed to get role", http.StatusInternalServerError)
+	// Sharing replaces any role the user already has
+	if !h.checkOwnerChange(w, r, itemID, userID, shareRequest.UserID, shareRequest.Role) {
 		return
 	}
 
-	log.Printf("Found role ID: %d for role: %s", roleID, shareRequest.Role)
-
-	// Check if user already has a role for this item
-	var existingRoleID int
-	err = tx.QueryRow("SELECT role_id FROM user_roles WHERE user_id = $1 AND item_id = $2", shareRequest.UserID, itemID).Scan(&existingRoleID)
-	if err == nil {
-		// Update existing role
-		_, err = tx.Exec(
-			"UPDATE user_roles SET role_id = $1, created_by = $2 WHERE user_id = $3 AND item_id = $4",
-			roleID, userID, shareRequest.UserID, itemID,
-		)
-		log.Printf("Updated existing role for user")
-	} else if err == sql.ErrNoRows {
-		// Insert new role
-		_, err = tx.Exec(
-			"INSERT INTO user_roles (item_id, user_id, role_id, created_by) VALUES ($1, $2, $3, $4)",
-			itemID, shareRequest.UserID, roleID, userID,
-		)
-		log.Printf("Inserted new role for user")
-	}
-
-	if err != nil {
-		log.Printf("Error updating user role: %v", err)
-		http.Error(w, "Failed to update user role", http.StatusInternalServerError)
+	if err := h.Shares.ShareItem(r.Context(), itemID, shareRequest.UserID, shareRequest.Role, userID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "User not found", http.StatusNotFound)
+		case errors.Is(err, store.ErrLastOwner):
+			writeLastOwner(w)
+		default:
+			log.Printf("Error updating user role: %v", err)
+			http.Error(w, "Failed to update user role", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		log.Printf("Error committing transaction: %v", err)
-		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
-		return
-	}
+	h.publishCollaboratorEvent(r, models.EventCollaboratorChanged, itemID, shareRequest.UserID)
 
 	w.WriteHeader(http.StatusOK)
-}
\ No newline at end of file
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/events"
+	"github.com/onyeepeace/todo-api/internal/itemtypes"
+	"github.com/onyeepeace/todo-api/internal/mail"
+	"github.com/onyeepeace/todo-api/internal/middleware"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+// newTestUser adds a user with a verified email to the store and returns
+// their ID
+func newTestUser(t *testing.T, m *store.Memory, name string) int {
+	t.Helper()
+	user := models.User{Email: name + "@example.com", Username: name}
+	identity := models.Identity{Provider: "test", Subject: name, Email: user.Email, EmailVerified: true}
+	if err := m.CreateUserWithIdentity(context.Background(), &user, &identity); err != nil {
+		t.Fatalf("creating user %s: %v", name, err)
+	}
+	return user.UserID
+}
+
+// newTestItemHandler returns an ItemHandler backed entirely by m
+func newTestItemHandler(m *store.Memory) *ItemHandler {
+	return &ItemHandler{
+		Items:       m,
+		Shares:      m,
+		Transfers:   m,
+		Invitations: m,
+		Roles:       m,
+		Users:       m,
+		Identities:  m,
+		Events:      events.NewMemoryBroker(events.NewHub()),
+		Types:       itemtypes.NewRegistry(m),
+		Mail:        mail.Log{},
+	}
+}
+
+// asUser stands in for ValidateJWT, taking the user ID from X-Test-User
+func asUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if userID, err := strconv.Atoi(r.Header.Get("X-Test-User")); err == nil {
+			r = r.WithContext(context.WithValue(r.Context(), models.UserIDKey, userID))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// do sends a request as userID and returns the response
+func do(t *testing.T, h http.Handler, method, path string, userID int, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("X-Test-User", strconv.Itoa(userID))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func newItemRouter(m *store.Memory) http.Handler {
+	h := newTestItemHandler(m)
+	r := chi.NewRouter()
+	r.Use(asUser)
+	r.Get("/items", h.GetItemsHandler)
+	r.Post("/items", h.CreateItemHandler)
+	r.With(middleware.Authorize(m, "can_view")).Get("/items/{item_id}", h.GetItemByIDHandler)
+	r.With(middleware.Authorize(m, "can_edit")).Put("/items/{item_id}", h.EditItemHandler)
+	r.With(middleware.Authorize(m, "can_edit")).Patch("/items/{item_id}", h.PatchItemHandler)
+	r.With(middleware.Authorize(m, "can_share")).Post("/items/{item_id}/share", h.ShareItemHandler)
+	return r
+}
+
+func TestCreateAndGetItem(t *testing.T) {
+	m := store.NewMemory()
+	owner := newTestUser(t, m, "owner")
+	router := newItemRouter(m)
+
+	w := do(t, router, "POST", "/items", owner, `{"name": "Groceries"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("create: got %d %s", w.Code, w.Body)
+	}
+	var created models.Item
+	json.NewDecoder(w.Body).Decode(&created)
+	if created.ItemType != models.ItemTypeTodoList || created.Version != 1 {
+		t.Fatalf("create: got type %q version %d", created.ItemType, created.Version)
+	}
+
+	w = do(t, router, "GET", "/items/"+strconv.Itoa(created.ItemID), owner, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("get: got %d %s", w.Code, w.Body)
+	}
+	var got models.ItemWithAccess
+	json.NewDecoder(w.Body).Decode(&got)
+	if got.Name != "Groceries" || got.Role != "owner" {
+		t.Errorf("get: got name %q role %q", got.Name, got.Role)
+	}
+	if etag := w.Header().Get("ETag"); etag != created.GenerateETag() {
+		t.Errorf("get: got ETag %s, want %s", etag, created.GenerateETag())
+	}
+}
+
+func TestEditItemWithStaleVersion(t *testing.T) {
+	m := store.NewMemory()
+	owner := newTestUser(t, m, "owner")
+	router := newItemRouter(m)
+
+	item := models.Item{Name: "Groceries", ItemType: models.ItemTypeTodoList, Content: json.RawMessage(`[]`)}
+	if err := m.CreateItem(context.Background(), &item, owner); err != nil {
+		t.Fatal(err)
+	}
+	path := "/items/" + strconv.Itoa(item.ItemID)
+
+	if w := do(t, router, "PUT", path, owner, `{"name": "Shopping", "content": [], "version": 1}`); w.Code != http.StatusOK {
+		t.Fatalf("first edit: got %d %s", w.Code, w.Body)
+	}
+
+	w := do(t, router, "PUT", path, owner, `{"name": "Errands", "content": [], "version": 1}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("stale edit: got %d %s", w.Code, w.Body)
+	}
+	var conflict struct {
+		Current models.Item `json:"current"`
+	}
+	json.NewDecoder(w.Body).Decode(&conflict)
+	if conflict.Current.Name != "Shopping" || conflict.Current.Version != 2 {
+		t.Errorf("stale edit: got current %q version %d", conflict.Current.Name, conflict.Current.Version)
+	}
+}
+
+func TestShareItem(t *testing.T) {
+	m := store.NewMemory()
+	owner := newTestUser(t, m, "owner")
+	friend := newTestUser(t, m, "friend")
+	router := newItemRouter(m)
+
+	item := models.Item{Name: "Groceries", ItemType: models.ItemTypeTodoList, Content: json.RawMessage(`[]`)}
+	if err := m.CreateItem(context.Background(), &item, owner); err != nil {
+		t.Fatal(err)
+	}
+	path := "/items/" + strconv.Itoa(item.ItemID)
+
+	if w := do(t, router, "GET", path, friend, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("before sharing: got %d", w.Code)
+	}
+
+	body := `{"user_id": ` + strconv.Itoa(friend) + `, "role": "viewer"}`
+	if w := do(t, router, "POST", path+"/share", owner, body); w.Code != http.StatusOK {
+		t.Fatalf("share: got %d %s", w.Code, w.Body)
+	}
+
+	if w := do(t, router, "GET", path, friend, ""); w.Code != http.StatusOK {
+		t.Fatalf("after sharing: got %d %s", w.Code, w.Body)
+	}
+	if w := do(t, router, "PUT", path, friend, `{"name": "Mine now", "content": []}`); w.Code != http.StatusForbidden {
+		t.Errorf("viewer edit: got %d", w.Code)
+	}
+}
+
+func TestNoteTextOnlyChangesThroughDoc(t *testing.T) {
+	m := store.NewMemory()
+	owner := newTestUser(t, m, "owner")
+	router := newItemRouter(m)
+
+	note := models.Item{Name: "Plans", ItemType: models.ItemTypeNote, Content: json.RawMessage(`{"text": "hi"}`)}
+	if err := m.CreateItem(context.Background(), &note, owner); err != nil {
+		t.Fatal(err)
+	}
+	path := "/items/" + strconv.Itoa(note.ItemID)
+
+	if w := do(t, router, "PUT", path, owner, `{"name": "Plans", "content": {"text": "bye"}}`); w.Code != http.StatusConflict {
+		t.Errorf("put new text: got %d %s", w.Code, w.Body)
+	}
+
+	r := httptest.NewRequest("PATCH", path, strings.NewReader(`{"text": "bye"}`))
+	r.Header.Set("X-Test-User", strconv.Itoa(owner))
+	r.Header.Set("Content-Type", mergePatchMediaType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusConflict {
+		t.Errorf("patch text: got %d %s", w.Code, w.Body)
+	}
+
+	// Renaming is fine, with or without the unchanged content
+	if w := do(t, router, "PUT", path, owner, `{"name": "Ideas", "content": {"text":"hi"}}`); w.Code != http.StatusOK {
+		t.Errorf("rename with content: got %d %s", w.Code, w.Body)
+	}
+	w = do(t, router, "PUT", path, owner, `{"name": "Notes"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("rename without content: got %d %s", w.Code, w.Body)
+	}
+	var renamed models.Item
+	json.NewDecoder(w.Body).Decode(&renamed)
+	if renamed.Name != "Notes" || !sameJSON(renamed.Content, note.Content) {
+		t.Errorf("rename without content: got name %q content %s", renamed.Name, renamed.Content)
+	}
+}
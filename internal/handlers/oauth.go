@@ -3,91 +3,207 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
-	"os"
 
-	"github.com/joho/godotenv"
-	"github.com/onyeepeace/todo-api/internal/db"
+	"github.com/go-chi/chi/v5"
 	"github.com/onyeepeace/todo-api/internal/middleware"
 	"github.com/onyeepeace/todo-api/internal/models"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
+	"github.com/onyeepeace/todo-api/internal/oauth"
+	"github.com/onyeepeace/todo-api/internal/store"
 )
 
-var googleOauthConfig = &oauth2.Config{}
+// AuthHandler serves the /api/auth endpoints
+type AuthHandler struct {
+	Providers *oauth.Registry
+	// States ties each provider callback to the login that started it
+	States     *oauth.States
+	Users      store.UserStore
+	Identities store.IdentityStore
+	Sessions   store.SessionStore
+	Tokens     store.RefreshTokenStore
+	// Invitations to a verified email address turn into roles when someone
+	// signs in with it
+	Invitations store.InvitationStore
+	// AccessTokens signs the access tokens handed out at sign-in and refresh
+	AccessTokens *middleware.AccessTokens
+	// Revocations learns about sessions revoked here straight away
+	Revocations *middleware.RevocationCache
+	// InsecureCookies lets the token cookies travel over plain HTTP, for
+	// local development
+	InsecureCookies bool
+}
+
+// provider finds the identity provider named in the route
+func (h *AuthHandler) provider(w http.ResponseWriter, r *http.Request) (oauth.Provider, bool) {
+	provider, err := h.Providers.Get(chi.URLParam(r, "provider"))
+	if err != nil {
+		http.Error(w, "Unknown identity provider", http.StatusNotFound)
+		return nil, false
+	}
+	return provider, true
+}
 
-func init() {
-	_ = godotenv.Load()
+// ProvidersHandler lists the identity providers users can sign in with
+func (h *AuthHandler) ProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Providers.Names())
+}
 
-	clientID := os.Getenv("GOOGLE_CLIENT_ID")
-	clientSecret := os.Getenv("GOOGLE_CLIENT_SECRET")
+// LoginHandler sends the user to the provider to sign in. The state, nonce
+// and PKCE verifier the callback checks are kept in a signed cookie, along
+// with whether the browser asked for cookie mode.
+func (h *AuthHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.provider(w, r)
+	if !ok {
+		return
+	}
 
-	googleOauthConfig = &oauth2.Config{
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		RedirectURL:  "postmessage",
-		Scopes:       []string{"email", "profile"},
-		Endpoint:     google.Endpoint,
+	flow, err := h.States.Begin(w, provider.Name(), r.URL.Query().Get("mode") == "cookie")
+	if err != nil {
+		log.Printf("Error starting sign-in: %v", err)
+		http.Error(w, "Failed to start sign-in", http.StatusInternalServerError)
+		return
 	}
-}
 
-func LoginHandler(w http.ResponseWriter, r *http.Request) {
-	url := googleOauthConfig.AuthCodeURL("state", oauth2.AccessTypeOffline)
+	url, err := provider.AuthCodeURL(flow)
+	if err != nil {
+		log.Printf("Error starting sign-in: %v", err)
+		http.Error(w, "Identity provider unavailable", http.StatusBadGateway)
+		return
+	}
 	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 }
 
-func CallbackHandler(w http.ResponseWriter, r *http.Request) {
-	code := r.URL.Query().Get("code")
+// CallbackHandler finishes a sign-in the provider sent the user back from.
+// Only the browser that started it at LoginHandler can finish it, and only
+// once.
+func (h *AuthHandler) CallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.provider(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	flow, err := h.States.Finish(w, r, provider.Name(), query.Get("state"))
+	if err != nil {
+		if errors.Is(err, oauth.ErrInvalidState) {
+			log.Printf("Rejected sign-in: %v", err)
+			http.Error(w, "Invalid or expired sign-in - please try again", http.StatusBadRequest)
+		} else {
+			log.Printf("Error checking sign-in state: %v", err)
+			http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		}
+		return
+	}
+	if query.Get("error") != "" {
+		http.Error(w, "Sign-in was cancelled", http.StatusUnauthorized)
+		return
+	}
+
+	code := query.Get("code")
 	if code == "" {
 		http.Error(w, "Code not found", http.StatusBadRequest)
 		return
 	}
-	
-	token, err := googleOauthConfig.Exchange(context.Background(), code)
 
+	identity, err := provider.Exchange(r.Context(), code, flow)
 	if err != nil {
-		http.Error(w, "Failed to exchange token", http.StatusInternalServerError)
+		log.Printf("Error signing in: %v", err)
+		http.Error(w, "Failed to sign in", http.StatusUnauthorized)
 		return
 	}
 
-	client := googleOauthConfig.Client(context.Background(), token)
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
+	user, err := h.userFor(r.Context(), identity)
 	if err != nil {
-		http.Error(w, "Failed to get user info", http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, errNoEmail):
+			http.Error(w, "Your "+identity.Provider+" account has no email address", http.StatusForbidden)
+		case errors.Is(err, store.ErrConflict):
+			http.Error(w, "An account with this email address already exists - sign in to it and link "+identity.Provider+" from your profile", http.StatusConflict)
+		default:
+			log.Printf("Error signing in user: %v", err)
+			http.Error(w, "Failed to sign in", http.StatusInternalServerError)
+		}
 		return
 	}
-	defer resp.Body.Close()
 
-	var userInfo struct {
-		Email          string `json:"email"`
-		ProviderUserID string `json:"id"`
-		Name           string `json:"name"`
+	if identity.EmailVerified {
+		h.claimInvitations(r.Context(), identity.Email, user.UserID)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
-		http.Error(w, "Failed to decode user info", http.StatusInternalServerError)
+
+	// Browser clients can ask for the tokens as HttpOnly cookies when the
+	// sign-in starts
+	h.startSession(w, r, user.UserID, flow.UseCookies)
+}
+
+// claimInvitations gives the user the roles they were invited to by email.
+// Only verified addresses claim invitations, since anyone can claim an
+// unverified one. Failing to doesn't stop the sign-in; the invitation links
+// still work.
+func (h *AuthHandler) claimInvitations(ctx context.Context, email string, userID int) {
+	invitations, err := h.Invitations.ClaimInvitations(ctx, email, userID)
+	if err != nil {
+		log.Printf("Error claiming invitations for user %d: %v", userID, err)
 		return
 	}
+	for _, invitation := range invitations {
+		log.Printf("User %d joined item %d by invitation %d", userID, invitation.ItemID, invitation.InvitationID)
+	}
+}
 
-	var user models.User
-	err = db.QueryRow("SELECT user_id, email FROM users WHERE provider_user_id = $1", userInfo.ProviderUserID).Scan(&user.UserID, &user.Email)
-	if err != nil {
-		err = db.QueryRow("INSERT INTO users (email, username, provider_user_id, provider) VALUES ($1, $2, $3, $4) RETURNING user_id, email", userInfo.Email, userInfo.Name, userInfo.ProviderUserID, "google").Scan(&user.UserID, &user.Email)
-		if err != nil {
-			http.Error(w, "Failed to create user", http.StatusInternalServerError)
-			return
+var errNoEmail = errors.New("identity has no email address")
+
+// userFor finds or creates the user an identity signs in as. An identity
+// seen for the first time joins the user who already has the same email
+// address verified by a provider, so teammates can sign in with any of
+// their accounts. Unverified addresses never link, since anyone can claim
+// them.
+func (h *AuthHandler) userFor(ctx context.Context, identity oauth.Identity) (models.User, error) {
+	stored, err := h.Identities.GetIdentity(ctx, identity.Provider, identity.Subject)
+	if err == nil {
+		stored.Email = identity.Email
+		stored.EmailVerified = identity.EmailVerified
+		if err := h.Identities.RecordSignIn(ctx, &stored); err != nil {
+			return models.User{}, err
 		}
+		return h.Users.GetUser(ctx, stored.UserID)
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return models.User{}, err
 	}
 
-	tokenString, err := middleware.GenerateJWT(user.UserID)
-	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
-		return
+	if identity.Email == "" {
+		return models.User{}, errNoEmail
+	}
+	stored = models.Identity{
+		Provider:      identity.Provider,
+		Subject:       identity.Subject,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"token": tokenString})
-}
+	if identity.EmailVerified {
+		user, err := h.Identities.GetUserByVerifiedEmail(ctx, identity.Email)
+		if err == nil {
+			stored.UserID = user.UserID
+			if err := h.Identities.LinkIdentity(ctx, &stored); err != nil {
+				return models.User{}, err
+			}
+			return user, nil
+		}
+		if !errors.Is(err, store.ErrNotFound) {
+			return models.User{}, err
+		}
+	}
 
-func LogoutHandler(w http.ResponseWriter, r *http.Request) {
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	user := models.User{Email: identity.Email, Username: identity.Name}
+	if user.Username == "" {
+		user.Username = identity.Email
+	}
+	if err := h.Identities.CreateUserWithIdentity(ctx, &user, &stored); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
 }
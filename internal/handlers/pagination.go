@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// itemPageCursor is what an opaque item cursor decodes to. The sort it was
+// issued for is included so a cursor can't be replayed against another order.
+type itemPageCursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d,omitempty"`
+	store.ItemCursor
+}
+
+func encodeItemCursor(q store.ItemQuery, cursor *store.ItemCursor) string {
+	payload, _ := json.Marshal(itemPageCursor{Sort: q.Sort, Desc: q.Desc, ItemCursor: *cursor})
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func decodeItemCursor(q store.ItemQuery, s string) (*store.ItemCursor, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var cursor itemPageCursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if cursor.Sort != q.Sort || cursor.Desc != q.Desc {
+		return nil, fmt.Errorf("cursor does not match the requested sort order")
+	}
+
+	return &cursor.ItemCursor, nil
+}
+
+// parseItemQuery reads the pagination, filter and sort parameters of
+// GET /api/items
+func parseItemQuery(r *http.Request, userID int) (store.ItemQuery, error) {
+	params := r.URL.Query()
+	q := store.ItemQuery{
+		UserID:     userID,
+		SharedBy:   params.Get("shared_by"),
+		NamePrefix: params.Get("name_prefix"),
+		ItemType:   params.Get("item_type"),
+		Limit:      defaultPageSize,
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxPageSize {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		q.Limit = n
+	}
+
+	for _, role := range params["role"] {
+		for _, r := range strings.Split(role, ",") {
+			// Custom roles can be filtered on too, so any role name will do
+			r = strings.TrimSpace(r)
+			if !roleNamePattern.MatchString(r) {
+				return q, fmt.Errorf("invalid role %q", r)
+			}
+			q.Roles = append(q.Roles, r)
+		}
+	}
+
+	times := []struct {
+		param string
+		dest  *time.Time
+	}{
+		{"created_after", &q.CreatedAfter},
+		{"created_before", &q.CreatedBefore},
+		{"updated_after", &q.UpdatedAfter},
+		{"updated_before", &q.UpdatedBefore},
+	}
+	for _, t := range times {
+		value := params.Get(t.param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return q, fmt.Errorf("%s must be an RFC 3339 timestamp", t.param)
+		}
+		*t.dest = parsed
+	}
+
+	// Newest first unless asked otherwise, matching the original listing
+	q.Sort = store.SortByCreatedAt
+	q.Desc = true
+	if sort := params.Get("sort"); sort != "" {
+		switch sort {
+		case store.SortByName, store.SortByCreatedAt, store.SortByUpdatedAt:
+			q.Sort = sort
+		default:
+			return q, fmt.Errorf("sort must be one of name, created_at or updated_at")
+		}
+		// Names read naturally A-Z, timestamps newest first
+		q.Desc = sort != store.SortByName
+	}
+	switch params.Get("order") {
+	case "":
+	case "asc":
+		q.Desc = false
+	case "desc":
+		q.Desc = true
+	default:
+		return q, fmt.Errorf("order must be 'asc' or 'desc'")
+	}
+
+	if cursor := params.Get("cursor"); cursor != "" {
+		after, err := decodeItemCursor(q, cursor)
+		if err != nil {
+			return q, err
+		}
+		q.After = after
+	}
+
+	return q, nil
+}
+
+// setNextLink points the Link header at the page following cursor
+func setNextLink(w http.ResponseWriter, r *http.Request, cursor string) {
+	params := r.URL.Query()
+	params.Set("cursor", cursor)
+	next := *r.URL
+	next.RawQuery = params.Encode()
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/middleware"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+const (
+	defaultPersonalTokenDays = 30
+	maxPersonalTokenDays     = 365
+)
+
+// personalTokenResponse is a new token; the token itself is only ever shown
+// this once
+type personalTokenResponse struct {
+	models.PersonalToken
+	Token string `json:"token"`
+}
+
+// CreatePersonalTokenHandler mints a personal access token for scripts and
+// the CLI. It can only do what its scopes allow, and expires after
+// expires_in_days (30 by default, at most 365).
+func (h *UserHandler) CreatePersonalTokenHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var body struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if body.Name == "" || len(body.Name) > 100 {
+		http.Error(w, "name is required and must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+	if len(body.Scopes) == 0 {
+		http.Error(w, "At least one scope is required", http.StatusBadRequest)
+		return
+	}
+	for _, scope := range body.Scopes {
+		if !validScope(scope) {
+			http.Error(w, "Unknown scope "+strconv.Quote(scope), http.StatusBadRequest)
+			return
+		}
+	}
+	if body.ExpiresInDays == 0 {
+		body.ExpiresInDays = defaultPersonalTokenDays
+	}
+	if body.ExpiresInDays < 0 || body.ExpiresInDays > maxPersonalTokenDays {
+		http.Error(w, "expires_in_days must be between 1 and "+strconv.Itoa(maxPersonalTokenDays), http.StatusBadRequest)
+		return
+	}
+
+	token, hash, err := middleware.NewPersonalToken()
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	stored := models.PersonalToken{
+		TokenHash: hash,
+		UserID:    userID,
+		Name:      body.Name,
+		Scopes:    body.Scopes,
+		ExpiresAt: time.Now().AddDate(0, 0, body.ExpiresInDays),
+	}
+	if err := h.PersonalTokens.CreatePersonalToken(r.Context(), &stored); err != nil {
+		log.Printf("Error creating personal access token: %v", err)
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(personalTokenResponse{PersonalToken: stored, Token: token})
+}
+
+// ListPersonalTokensHandler lists the current user's personal access tokens
+// that haven't been revoked
+func (h *UserHandler) ListPersonalTokensHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := h.PersonalTokens.ListPersonalTokens(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error listing personal access tokens: %v", err)
+		http.Error(w, "Failed to retrieve tokens", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// RevokePersonalTokenHandler revokes one of the current user's personal
+// access tokens. It stops working straight away.
+func (h *UserHandler) RevokePersonalTokenHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	tokenID, err := strconv.Atoi(chi.URLParam(r, "token_id"))
+	if err != nil {
+		http.Error(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.PersonalTokens.RevokePersonalToken(r.Context(), userID, tokenID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Token not found", http.StatusNotFound)
+		} else {
+			log.Printf("Error revoking personal access token: %v", err)
+			http.Error(w, "Failed to revoke token", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func validScope(scope string) bool {
+	for _, s := range models.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/middleware"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+// newPersonalTokenRouter serves the token management, item and user routes
+// with the scope and session guards the server puts on them. Requests carry
+// a real bearer token: an access token from signIn or a personal one.
+func newPersonalTokenRouter(t *testing.T, m *store.Memory) (http.Handler, *AuthHandler) {
+	t.Helper()
+	_, auth := newAuthRouter(t, m)
+	auth.AccessTokens.PersonalTokens = m
+	users := &UserHandler{Users: m, Identities: m, PersonalTokens: m}
+	items := newTestItemHandler(m)
+
+	r := chi.NewRouter()
+	r.Use(middleware.ValidateJWT(auth.AccessTokens, auth.Revocations))
+	r.With(middleware.RequireSession).Post("/auth/logout", auth.LogoutHandler)
+	r.Route("/items", func(r chi.Router) {
+		r.Use(middleware.RequireReadWriteScope(models.ScopeItemsRead, models.ScopeItemsWrite))
+		r.Get("/", items.GetItemsHandler)
+		r.Post("/", items.CreateItemHandler)
+	})
+	r.With(middleware.RequireScope(models.ScopeUsersRead)).Get("/users/me", users.GetCurrentUserHandler)
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RequireSession)
+		r.Get("/users/me/tokens", users.ListPersonalTokensHandler)
+		r.Post("/users/me/tokens", users.CreatePersonalTokenHandler)
+		r.Delete("/users/me/tokens/{token_id}", users.RevokePersonalTokenHandler)
+	})
+	return r, auth
+}
+
+// bearer sends a request with the token and returns the response
+func bearer(t *testing.T, h http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// createPersonalToken mints a token through the API with the session's
+// access token
+func createPersonalToken(t *testing.T, router http.Handler, accessToken string, scopes ...string) personalTokenResponse {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{"name": "cli", "scopes": scopes})
+	w := bearer(t, router, "POST", "/users/me/tokens", accessToken, string(body))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create token with %v: got %d %s", scopes, w.Code, w.Body)
+	}
+	var created personalTokenResponse
+	json.NewDecoder(w.Body).Decode(&created)
+	if !strings.HasPrefix(created.Token, middleware.PersonalTokenPrefix) {
+		t.Fatalf("got token %q", created.Token)
+	}
+	return created
+}
+
+func TestPersonalTokenScopes(t *testing.T) {
+	m := store.NewMemory()
+	router, auth := newPersonalTokenRouter(t, m)
+	session := signIn(t, auth, newTestUser(t, m, "ada")).Token
+
+	tokens := map[string]string{
+		"session":   session,
+		"read":      createPersonalToken(t, router, session, models.ScopeItemsRead).Token,
+		"readwrite": createPersonalToken(t, router, session, models.ScopeItemsRead, models.ScopeItemsWrite).Token,
+		"users":     createPersonalToken(t, router, session, models.ScopeUsersRead).Token,
+	}
+	newItem := `{"name": "Groceries", "item_type": "todo-list", "content": []}`
+	tests := []struct {
+		method, path, body string
+		allowed            []string
+		status             int
+	}{
+		{"GET", "/items/", "", []string{"session", "read", "readwrite"}, http.StatusOK},
+		{"POST", "/items/", newItem, []string{"session", "readwrite"}, http.StatusOK},
+		{"GET", "/users/me", "", []string{"session", "users"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		for name, token := range tokens {
+			want := http.StatusForbidden
+			for _, allowed := range tt.allowed {
+				if name == allowed {
+					want = tt.status
+				}
+			}
+			if w := bearer(t, router, tt.method, tt.path, token, tt.body); w.Code != want {
+				t.Errorf("%s %s with %s token: got %d %s, want %d", tt.method, tt.path, name, w.Code, w.Body, want)
+			}
+		}
+	}
+}
+
+func TestPersonalTokensCantManageTheAccount(t *testing.T) {
+	m := store.NewMemory()
+	router, auth := newPersonalTokenRouter(t, m)
+	session := signIn(t, auth, newTestUser(t, m, "ada")).Token
+	created := createPersonalToken(t, router, session, models.Scopes...)
+	tokenPath := "/users/me/tokens/" + strconv.Itoa(created.TokenID)
+
+	for _, req := range []struct{ method, path, body string }{
+		{"GET", "/users/me/tokens", ""},
+		{"POST", "/users/me/tokens", `{"name": "more", "scopes": ["items:read"]}`},
+		{"DELETE", tokenPath, ""},
+		{"POST", "/auth/logout", ""},
+	} {
+		w := bearer(t, router, req.method, req.path, created.Token, req.body)
+		if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "sign in") {
+			t.Errorf("%s %s with a personal token: got %d %s", req.method, req.path, w.Code, w.Body)
+		}
+	}
+
+	// A real sign-in can list and revoke it, and it stops working at once
+	if w := bearer(t, router, "GET", "/users/me/tokens", session, ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"name":"cli"`) {
+		t.Errorf("list tokens: got %d %s", w.Code, w.Body)
+	}
+	if w := bearer(t, router, "DELETE", tokenPath, session, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("revoke token: got %d %s", w.Code, w.Body)
+	}
+	if w := bearer(t, router, "GET", "/items/", created.Token, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked token: got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestPersonalTokenValidation(t *testing.T) {
+	m := store.NewMemory()
+	router, auth := newPersonalTokenRouter(t, m)
+	user := newTestUser(t, m, "ada")
+	session := signIn(t, auth, user).Token
+
+	for _, body := range []string{
+		`{"name": "", "scopes": ["items:read"]}`,
+		`{"name": "cli", "scopes": []}`,
+		`{"name": "cli", "scopes": ["items:delete"]}`,
+		`{"name": "cli", "scopes": ["items:read"], "expires_in_days": 366}`,
+	} {
+		if w := bearer(t, router, "POST", "/users/me/tokens", session, body); w.Code != http.StatusBadRequest {
+			t.Errorf("create %s: got %d %s", body, w.Code, w.Body)
+		}
+	}
+
+	token, hash, err := middleware.NewPersonalToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := models.PersonalToken{TokenHash: hash, UserID: user, Name: "old", Scopes: models.Scopes, ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := m.CreatePersonalToken(context.Background(), &expired); err != nil {
+		t.Fatal(err)
+	}
+	if w := bearer(t, router, "GET", "/items/", token, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expired token: got %d %s", w.Code, w.Body)
+	}
+}
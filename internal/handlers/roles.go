@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,49}$`)
+
+// RoleHandler serves the /api/roles and /api/permissions endpoints
+type RoleHandler struct {
+	Roles store.RoleStore
+}
+
+func (h *RoleHandler) ListRolesHandler(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.Roles.ListRoles(r.Context())
+	if err != nil {
+		log.Printf("Error listing roles: %v", err)
+		http.Error(w, "Failed to retrieve roles", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(roles)
+}
+
+func (h *RoleHandler) GetRoleHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeRole(w, r, chi.URLParam(r, "name"))
+}
+
+func (h *RoleHandler) ListPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	permissions, err := h.Roles.ListPermissions(r.Context())
+	if err != nil {
+		log.Printf("Error listing permissions: %v", err)
+		http.Error(w, "Failed to retrieve permissions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(permissions)
+}
+
+// CreateRoleHandler defines a custom role granting the given permissions.
+// It can be used for sharing straight away.
+func (h *RoleHandler) CreateRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !roleNamePattern.MatchString(body.Name) {
+		http.Error(w, "name must be lowercase letters, digits, dashes and underscores, starting with a letter", http.StatusBadRequest)
+		return
+	}
+
+	role := models.Role{Name: body.Name, Description: body.Description, Permissions: uniqueStrings(body.Permissions)}
+	if err := h.Roles.CreateRole(r.Context(), &role); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			http.Error(w, "Conflict - a role with this name already exists", http.StatusConflict)
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "Unknown permission - see GET /api/permissions", http.StatusBadRequest)
+		default:
+			log.Printf("Error creating role: %v", err)
+			http.Error(w, "Failed to create role", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(role)
+}
+
+// DeleteRoleHandler removes a custom role nobody has and no pending
+// invitation or working share link offers
+func (h *RoleHandler) DeleteRoleHandler(w http.ResponseWriter, r *http.Request) {
+	if err := h.Roles.DeleteRole(r.Context(), chi.URLParam(r, "name")); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "Role not found", http.StatusNotFound)
+		case errors.Is(err, store.ErrConflict):
+			http.Error(w, "Conflict - built-in roles and roles in use can't be deleted", http.StatusConflict)
+		default:
+			log.Printf("Error deleting role: %v", err)
+			http.Error(w, "Failed to delete role", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddRolePermissionHandler makes a custom role grant a permission. Everyone
+// with the role gets it straight away.
+func (h *RoleHandler) AddRolePermissionHandler(w http.ResponseWriter, r *http.Request) {
+	name, ok := h.customRole(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Roles.AddRolePermission(r.Context(), name, chi.URLParam(r, "permission")); err != nil {
+		h.writeRolePermissionError(w, err, "Permission not found")
+		return
+	}
+
+	h.writeRole(w, r, name)
+}
+
+// RemoveRolePermissionHandler stops a custom role granting a permission
+func (h *RoleHandler) RemoveRolePermissionHandler(w http.ResponseWriter, r *http.Request) {
+	name, ok := h.customRole(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Roles.RemoveRolePermission(r.Context(), name, chi.URLParam(r, "permission")); err != nil {
+		h.writeRolePermissionError(w, err, "The role doesn't grant this permission")
+		return
+	}
+
+	h.writeRole(w, r, name)
+}
+
+// customRole returns the role named in the route, and writes an error
+// unless it exists and isn't built in
+func (h *RoleHandler) customRole(w http.ResponseWriter, r *http.Request) (string, bool) {
+	role, err := h.Roles.GetRoleByName(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Role not found", http.StatusNotFound)
+		} else {
+			log.Printf("Error getting role: %v", err)
+			http.Error(w, "Failed to retrieve role", http.StatusInternalServerError)
+		}
+		return "", false
+	}
+	if role.BuiltIn {
+		http.Error(w, "Conflict - built-in roles can't be changed", http.StatusConflict)
+		return "", false
+	}
+	return role.Name, true
+}
+
+func (h *RoleHandler) writeRolePermissionError(w http.ResponseWriter, err error, notFound string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, notFound, http.StatusNotFound)
+	case errors.Is(err, store.ErrConflict):
+		http.Error(w, "Conflict - built-in roles can't be changed", http.StatusConflict)
+	default:
+		log.Printf("Error changing role permissions: %v", err)
+		http.Error(w, "Failed to update role", http.StatusInternalServerError)
+	}
+}
+
+func (h *RoleHandler) writeRole(w http.ResponseWriter, r *http.Request, name string) {
+	role, err := h.Roles.GetRoleByName(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Role not found", http.StatusNotFound)
+		} else {
+			log.Printf("Error getting role: %v", err)
+			http.Error(w, "Failed to retrieve role", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(role)
+}
+
+// checkRole writes an error and returns false unless role is defined. Any
+// role can be given out, built-in or custom.
+func checkRole(w http.ResponseWriter, r *http.Request, roles store.RoleStore, role string) bool {
+	if role == "" {
+		http.Error(w, "role is required", http.StatusBadRequest)
+		return false
+	}
+	if _, err := roles.GetRoleByName(r.Context(), role); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Unknown role - see GET /api/roles", http.StatusBadRequest)
+		} else {
+			log.Printf("Error getting role: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return false
+	}
+	return true
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := []string{}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/middleware"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+func newRoleRouter(m *store.Memory) http.Handler {
+	h := &RoleHandler{Roles: m}
+	r := chi.NewRouter()
+	r.Use(asUser)
+	r.Get("/roles", h.ListRolesHandler)
+	r.Get("/roles/{name}", h.GetRoleHandler)
+	r.Get("/permissions", h.ListPermissionsHandler)
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RequireAdmin(m))
+		r.Post("/roles", h.CreateRoleHandler)
+		r.Delete("/roles/{name}", h.DeleteRoleHandler)
+		r.Put("/roles/{name}/permissions/{permission}", h.AddRolePermissionHandler)
+		r.Delete("/roles/{name}/permissions/{permission}", h.RemoveRolePermissionHandler)
+	})
+	return r
+}
+
+// newAdmin adds a user who can manage roles
+func newAdmin(t *testing.T, m *store.Memory) int {
+	t.Helper()
+	admin := newTestUser(t, m, "admin")
+	m.SetAdmin(admin, true)
+	return admin
+}
+
+// decodeRole reads the role a response carries
+func decodeRole(t *testing.T, resp *http.Response) models.Role {
+	t.Helper()
+	var role models.Role
+	if err := json.NewDecoder(resp.Body).Decode(&role); err != nil {
+		t.Fatal(err)
+	}
+	return role
+}
+
+func TestOnlyAdminsManageRoles(t *testing.T) {
+	m := store.NewMemory()
+	newAdmin(t, m)
+	user := newTestUser(t, m, "user")
+	router := newRoleRouter(m)
+
+	requests := []struct{ method, path, body string }{
+		{"POST", "/roles", `{"name":"reviewer","permissions":["can_view"]}`},
+		{"DELETE", "/roles/viewer", ""},
+		{"PUT", "/roles/viewer/permissions/can_edit", ""},
+		{"DELETE", "/roles/viewer/permissions/can_view", ""},
+	}
+	for _, req := range requests {
+		if w := do(t, router, req.method, req.path, user, req.body); w.Code != http.StatusForbidden {
+			t.Errorf("%s %s: got %d, want 403", req.method, req.path, w.Code)
+		}
+	}
+
+	// Anyone can see the roles there are to share with
+	for _, path := range []string{"/roles", "/roles/viewer", "/permissions"} {
+		if w := do(t, router, "GET", path, user, ""); w.Code != http.StatusOK {
+			t.Errorf("GET %s: got %d, want 200", path, w.Code)
+		}
+	}
+}
+
+func TestCreateRole(t *testing.T) {
+	m := store.NewMemory()
+	admin := newAdmin(t, m)
+	router := newRoleRouter(m)
+
+	invalid := []string{
+		`{"name":"Reviewer","permissions":["can_view"]}`,
+		`{"name":"","permissions":["can_view"]}`,
+		`{"name":"reviewer","permissions":["can_fly"]}`,
+		`not json`,
+	}
+	for _, body := range invalid {
+		if w := do(t, router, "POST", "/roles", admin, body); w.Code != http.StatusBadRequest {
+			t.Errorf("create %s: got %d, want 400", body, w.Code)
+		}
+	}
+
+	w := do(t, router, "POST", "/roles", admin, `{"name":"reviewer","description":"Reads and ticks off","permissions":["can_view","can_complete","can_view"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	created := decodeRole(t, w.Result())
+	if created.Name != "reviewer" || created.BuiltIn || len(created.Permissions) != 2 {
+		t.Errorf("created %+v, want reviewer granting can_complete and can_view", created)
+	}
+
+	if w := do(t, router, "POST", "/roles", admin, `{"name":"reviewer","permissions":["can_view"]}`); w.Code != http.StatusConflict {
+		t.Errorf("create twice: got %d, want 409", w.Code)
+	}
+	if w := do(t, router, "POST", "/roles", admin, `{"name":"editor","permissions":["can_view"]}`); w.Code != http.StatusConflict {
+		t.Errorf("create a built-in name: got %d, want 409", w.Code)
+	}
+
+	w = do(t, router, "GET", "/roles/reviewer", admin, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("get: got %d %s", w.Code, w.Body)
+	}
+	if got := decodeRole(t, w.Result()); got.RoleID != created.RoleID || got.Description != "Reads and ticks off" {
+		t.Errorf("got %+v, want %+v", got, created)
+	}
+	if w := do(t, router, "GET", "/roles/nobody", admin, ""); w.Code != http.StatusNotFound {
+		t.Errorf("get a missing role: got %d, want 404", w.Code)
+	}
+
+	// The role can be shared with straight away
+	itemID, owner := newSharedList(t, m)
+	reviewer := newTestUser(t, m, "reviewer")
+	share(t, m, itemID, reviewer, "reviewer", owner)
+	for permission, want := range map[string]bool{"can_view": true, "can_complete": true, "can_edit": false} {
+		if got, err := m.HasPermission(context.Background(), reviewer, itemID, permission); err != nil || got != want {
+			t.Errorf("%s: got %v, %v, want %v", permission, got, err, want)
+		}
+	}
+}
+
+func TestChangeRolePermissions(t *testing.T) {
+	m := store.NewMemory()
+	admin := newAdmin(t, m)
+	router := newRoleRouter(m)
+	if w := do(t, router, "POST", "/roles", admin, `{"name":"reviewer","permissions":["can_view"]}`); w.Code != http.StatusCreated {
+		t.Fatalf("create: got %d %s", w.Code, w.Body)
+	}
+	itemID, owner := newSharedList(t, m)
+	reviewer := newTestUser(t, m, "reviewer")
+	share(t, m, itemID, reviewer, "reviewer", owner)
+
+	w := do(t, router, "PUT", "/roles/reviewer/permissions/can_edit", admin, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("add: got %d %s", w.Code, w.Body)
+	}
+	if role := decodeRole(t, w.Result()); len(role.Permissions) != 2 {
+		t.Errorf("got permissions %v, want can_edit and can_view", role.Permissions)
+	}
+	// Everyone with the role gets it straight away
+	if ok, err := m.HasPermission(context.Background(), reviewer, itemID, "can_edit"); err != nil || !ok {
+		t.Errorf("reviewer can_edit after adding it: got %v, %v", ok, err)
+	}
+	if w := do(t, router, "PUT", "/roles/reviewer/permissions/can_edit", admin, ""); w.Code != http.StatusOK {
+		t.Errorf("add twice: got %d, want 200", w.Code)
+	}
+
+	w = do(t, router, "DELETE", "/roles/reviewer/permissions/can_edit", admin, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("remove: got %d %s", w.Code, w.Body)
+	}
+	if role := decodeRole(t, w.Result()); len(role.Permissions) != 1 || role.Permissions[0] != "can_view" {
+		t.Errorf("got permissions %v, want can_view", role.Permissions)
+	}
+	if ok, err := m.HasPermission(context.Background(), reviewer, itemID, "can_edit"); err != nil || ok {
+		t.Errorf("reviewer can_edit after removing it: got %v, %v", ok, err)
+	}
+
+	notFound := []struct{ method, path string }{
+		{"PUT", "/roles/reviewer/permissions/can_fly"},
+		{"DELETE", "/roles/reviewer/permissions/can_edit"},
+		{"PUT", "/roles/nobody/permissions/can_view"},
+		{"DELETE", "/roles/nobody/permissions/can_view"},
+	}
+	for _, req := range notFound {
+		if w := do(t, router, req.method, req.path, admin, ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s %s: got %d, want 404", req.method, req.path, w.Code)
+		}
+	}
+
+	// Built-in roles never change
+	for _, method := range []string{"PUT", "DELETE"} {
+		if w := do(t, router, method, "/roles/viewer/permissions/can_delete", admin, ""); w.Code != http.StatusConflict {
+			t.Errorf("%s a built-in role's permission: got %d, want 409", method, w.Code)
+		}
+	}
+}
+
+func TestDeleteRole(t *testing.T) {
+	m := store.NewMemory()
+	admin := newAdmin(t, m)
+	router := newRoleRouter(m)
+	if w := do(t, router, "POST", "/roles", admin, `{"name":"reviewer","permissions":["can_view"]}`); w.Code != http.StatusCreated {
+		t.Fatalf("create: got %d %s", w.Code, w.Body)
+	}
+	itemID, owner := newSharedList(t, m)
+	reviewer := newTestUser(t, m, "reviewer")
+	share(t, m, itemID, reviewer, "reviewer", owner)
+
+	if w := do(t, router, "DELETE", "/roles/owner", admin, ""); w.Code != http.StatusConflict {
+		t.Errorf("delete a built-in role: got %d, want 409", w.Code)
+	}
+	if w := do(t, router, "DELETE", "/roles/reviewer", admin, ""); w.Code != http.StatusConflict {
+		t.Errorf("delete a role in use: got %d, want 409", w.Code)
+	}
+
+	if err := m.RevokeAccess(context.Background(), itemID, reviewer); err != nil {
+		t.Fatal(err)
+	}
+	if w := do(t, router, "DELETE", "/roles/reviewer", admin, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: got %d %s", w.Code, w.Body)
+	}
+	if w := do(t, router, "GET", "/roles/reviewer", admin, ""); w.Code != http.StatusNotFound {
+		t.Errorf("get after deleting: got %d, want 404", w.Code)
+	}
+	if w := do(t, router, "DELETE", "/roles/reviewer", admin, ""); w.Code != http.StatusNotFound {
+		t.Errorf("delete twice: got %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/middleware"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ShareLinkHandler serves share links: creating them for an item, and the
+// /api/shared endpoints people who have one use
+type ShareLinkHandler struct {
+	Links  store.ShareLinkStore
+	Items  store.ItemStore
+	Todos  store.TodoStore
+	Shares store.ShareStore
+	Roles  store.RoleStore
+}
+
+// shareLinkResponse is a new link; the token itself is only ever shown this
+// once
+type shareLinkResponse struct {
+	models.ShareLink
+	Token string `json:"token"`
+}
+
+// sharedItemResponse is an item as seen through a share link
+type sharedItemResponse struct {
+	models.Item
+	Role  string        `json:"role"`
+	Todos []models.Todo `json:"todos,omitempty"`
+}
+
+// CreateShareLinkHandler makes a link that gives whoever has it a role on
+// the item, as long as the role can't share or delete it. It can optionally
+// expire at expires_at, need a password or stop working after max_uses
+// people joined through it.
+func (h *ShareLinkHandler) CreateShareLinkHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	itemID, err := strconv.Atoi(chi.URLParam(r, "item_id"))
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Role      string     `json:"role"`
+		ExpiresAt *time.Time `json:"expires_at"`
+		Password  string     `json:"password"`
+		MaxUses   *int       `json:"max_uses"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !checkRole(w, r, h.Roles, body.Role) {
+		return
+	}
+	tooStrong, err := linkRoleTooStrong(r, h.Shares, body.Role)
+	if err != nil {
+		log.Printf("Error checking share link role: %v", err)
+		http.Error(w, "Failed to create share link", http.StatusInternalServerError)
+		return
+	}
+	if tooStrong {
+		http.Error(w, "Share links can't give a role that can share or delete the item", http.StatusBadRequest)
+		return
+	}
+	if body.ExpiresAt != nil && !body.ExpiresAt.After(time.Now()) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+	if body.MaxUses != nil && *body.MaxUses < 1 {
+		http.Error(w, "max_uses must be at least 1", http.StatusBadRequest)
+		return
+	}
+	// bcrypt only looks at the first 72 bytes
+	if len(body.Password) > 72 {
+		http.Error(w, "password must be at most 72 bytes", http.StatusBadRequest)
+		return
+	}
+
+	token, hash, err := middleware.NewShareLinkToken()
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	link := models.ShareLink{
+		ItemID:    itemID,
+		TokenHash: hash,
+		Role:      body.Role,
+		ExpiresAt: body.ExpiresAt,
+		MaxUses:   body.MaxUses,
+		CreatedBy: userID,
+	}
+	if body.Password != "" {
+		passwordHash, err := bcrypt.GenerateFromPassword([]byte(body.Password), bcrypt.DefaultCost)
+		if err != nil {
+			log.Printf("Error hashing share link password: %v", err)
+			http.Error(w, "Failed to create share link", http.StatusInternalServerError)
+			return
+		}
+		link.PasswordHash = string(passwordHash)
+	}
+	if err := h.Links.CreateShareLink(r.Context(), &link); err != nil {
+		log.Printf("Error creating share link: %v", err)
+		http.Error(w, "Failed to create share link", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(shareLinkResponse{ShareLink: link, Token: token})
+}
+
+// ListShareLinksHandler lists the item's share links that haven't been
+// revoked
+func (h *ShareLinkHandler) ListShareLinksHandler(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "item_id"))
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	links, err := h.Links.ListShareLinks(r.Context(), itemID)
+	if err != nil {
+		log.Printf("Error listing share links: %v", err)
+		http.Error(w, "Failed to retrieve share links", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(links)
+}
+
+// RevokeShareLinkHandler stops one of the item's share links from working.
+// Anyone who already joined the item through it keeps their role.
+func (h *ShareLinkHandler) RevokeShareLinkHandler(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "item_id"))
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+	linkID, err := strconv.Atoi(chi.URLParam(r, "link_id"))
+	if err != nil {
+		http.Error(w, "Invalid link ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Links.RevokeShareLink(r.Context(), itemID, linkID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Share link not found", http.StatusNotFound)
+		} else {
+			log.Printf("Error revoking share link: %v", err)
+			http.Error(w, "Failed to revoke share link", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSharedItemHandler returns the item a share link is for, with its todos
+// if it is a todo list. It needs no sign-in.
+func (h *ShareLinkHandler) GetSharedItemHandler(w http.ResponseWriter, r *http.Request) {
+	link, ok := r.Context().Value(models.ShareLinkKey).(models.ShareLink)
+	if !ok {
+		http.Error(w, "Share link not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	item, err := h.Items.GetItem(r.Context(), link.ItemID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Item not found", http.StatusNotFound)
+		} else {
+			log.Printf("Error getting shared item: %v", err)
+			http.Error(w, "Failed to retrieve item", http.StatusInternalServerError)
+		}
+		return
+	}
+	item.ETag = item.GenerateETag()
+
+	response := sharedItemResponse{Item: item, Role: link.Role}
+	if item.ItemType == models.ItemTypeTodoList {
+		if response.Todos, err = h.Todos.ListTodos(r.Context(), item.ItemID); err != nil {
+			log.Printf("Error listing shared todos: %v", err)
+			http.Error(w, "Failed to retrieve todos", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Don't let shared caches keep what may be behind a password
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// JoinSharedItemHandler gives the current user the share link's role on
+// its item, so it shows up with their other items and they can edit it
+// with an editor link. Someone who already has a role on the item keeps
+// theirs. Each new collaborator counts as one use of the link.
+func (h *ShareLinkHandler) JoinSharedItemHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+	link, ok := r.Context().Value(models.ShareLinkKey).(models.ShareLink)
+	if !ok {
+		http.Error(w, "Share link not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	role, err := h.Shares.GetRole(r.Context(), link.ItemID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		role = link.Role
+		err = h.joinThroughLink(r, link, userID)
+	}
+	switch {
+	case errors.Is(err, errLinkRoleTooStrong):
+		http.Error(w, "Forbidden - this link's role can now share or delete the item", http.StatusForbidden)
+		return
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "Share link not found or expired", http.StatusNotFound)
+		return
+	case err != nil:
+		log.Printf("Error joining shared item: %v", err)
+		http.Error(w, "Failed to join item", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"item_id": link.ItemID, "role": role})
+}
+
+var errLinkRoleTooStrong = errors.New("share link role can share or delete the item")
+
+// joinThroughLink counts a use of the link and gives userID its role. The
+// role is checked again, since a custom role can be given more permissions
+// after the link was made.
+func (h *ShareLinkHandler) joinThroughLink(r *http.Request, link models.ShareLink, userID int) error {
+	tooStrong, err := linkRoleTooStrong(r, h.Shares, link.Role)
+	if err != nil {
+		return err
+	}
+	if tooStrong {
+		return errLinkRoleTooStrong
+	}
+	if err := h.Links.UseShareLink(r.Context(), link.LinkID); err != nil {
+		return err
+	}
+	return h.Shares.ShareItem(r.Context(), link.ItemID, userID, link.Role, link.CreatedBy)
+}
+
+// linkRoleTooStrong reports whether a role can share or delete an item.
+// Whoever has a link can't be trusted with that, whatever the role is
+// called.
+func linkRoleTooStrong(r *http.Request, shares store.ShareStore, role string) (bool, error) {
+	for _, permission := range []string{"can_share", "can_delete"} {
+		granted, err := shares.RoleHasPermission(r.Context(), role, permission)
+		if err != nil || granted {
+			return granted, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/middleware"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+func newShareLinkRouter(m *store.Memory) http.Handler {
+	h := &ShareLinkHandler{Links: m, Items: m, Todos: m, Shares: m, Roles: m}
+	r := chi.NewRouter()
+	r.Use(asUser)
+	r.With(middleware.Authorize(m, "can_share")).Post("/items/{item_id}/share-links", h.CreateShareLinkHandler)
+	r.With(middleware.AuthorizeShareLink(m, "can_view")).Get("/shared/{token}", h.GetSharedItemHandler)
+	r.With(middleware.AuthorizeShareLink(m, "can_view")).Post("/shared/{token}/join", h.JoinSharedItemHandler)
+	return r
+}
+
+// newSharedList returns the ID of a todo list owned by a new user
+func newSharedList(t *testing.T, m *store.Memory) (itemID, owner int) {
+	t.Helper()
+	owner = newTestUser(t, m, "owner")
+	item := models.Item{Name: "Groceries", ItemType: models.ItemTypeTodoList, Content: json.RawMessage(`[]`)}
+	if err := m.CreateItem(context.Background(), &item, owner); err != nil {
+		t.Fatal(err)
+	}
+	return item.ItemID, owner
+}
+
+// createShareLink makes a link as owner and returns its token
+func createShareLink(t *testing.T, router http.Handler, itemID, owner int, body string) string {
+	t.Helper()
+	w := do(t, router, "POST", "/items/"+strconv.Itoa(itemID)+"/share-links", owner, body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create link %s: got %d %s", body, w.Code, w.Body)
+	}
+	var created shareLinkResponse
+	json.NewDecoder(w.Body).Decode(&created)
+	return created.Token
+}
+
+func TestShareLinkRoles(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	for _, role := range []models.Role{
+		{Name: "completer", Permissions: []string{"can_view", "can_complete"}},
+		{Name: "curator", Permissions: []string{"can_view", "can_share"}},
+		{Name: "janitor", Permissions: []string{"can_view", "can_delete"}},
+	} {
+		if err := m.CreateRole(context.Background(), &role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	router := newShareLinkRouter(m)
+
+	for role, want := range map[string]int{
+		"viewer":    http.StatusCreated,
+		"editor":    http.StatusCreated,
+		"completer": http.StatusCreated,
+		"owner":     http.StatusBadRequest,
+		"curator":   http.StatusBadRequest,
+		"janitor":   http.StatusBadRequest,
+		"nobody":    http.StatusBadRequest,
+	} {
+		w := do(t, router, "POST", "/items/"+strconv.Itoa(itemID)+"/share-links", owner, `{"role": "`+role+`"}`)
+		if w.Code != want {
+			t.Errorf("%s link: got %d %s, want %d", role, w.Code, w.Body, want)
+		}
+	}
+
+	// A role given can_share after the link was made can't be joined with
+	token := createShareLink(t, router, itemID, owner, `{"role": "completer"}`)
+	if err := m.AddRolePermission(context.Background(), "completer", "can_share"); err != nil {
+		t.Fatal(err)
+	}
+	friend := newTestUser(t, m, "friend")
+	if w := do(t, router, "POST", "/shared/"+token+"/join", friend, ""); w.Code != http.StatusForbidden {
+		t.Errorf("join with a role that can now share: got %d %s", w.Code, w.Body)
+	}
+	if _, err := m.GetRole(context.Background(), itemID, friend); err == nil {
+		t.Error("join with a role that can now share: friend got a role")
+	}
+}
+
+func TestShareLinkMaxUsesCountsJoins(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	friend := newTestUser(t, m, "friend")
+	stranger := newTestUser(t, m, "stranger")
+	router := newShareLinkRouter(m)
+
+	token := createShareLink(t, router, itemID, owner, `{"role": "viewer", "max_uses": 1}`)
+
+	// Looking first doesn't use the link up
+	for i := 0; i < 2; i++ {
+		if w := do(t, router, "GET", "/shared/"+token, 0, ""); w.Code != http.StatusOK {
+			t.Fatalf("view %d: got %d %s", i+1, w.Code, w.Body)
+		}
+	}
+	if w := do(t, router, "POST", "/shared/"+token+"/join", friend, ""); w.Code != http.StatusOK {
+		t.Fatalf("join: got %d %s", w.Code, w.Body)
+	}
+	if role, err := m.GetRole(context.Background(), itemID, friend); err != nil || role != "viewer" {
+		t.Errorf("join: got role %q, %v", role, err)
+	}
+
+	if w := do(t, router, "POST", "/shared/"+token+"/join", stranger, ""); w.Code != http.StatusNotFound {
+		t.Errorf("second join: got %d %s", w.Code, w.Body)
+	}
+	if w := do(t, router, "GET", "/shared/"+token, 0, ""); w.Code != http.StatusNotFound {
+		t.Errorf("view after it was used up: got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestShareLinkExpiry(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	router := newShareLinkRouter(m)
+
+	past := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	w := do(t, router, "POST", "/items/"+strconv.Itoa(itemID)+"/share-links", owner, `{"role": "viewer", "expires_at": "`+past+`"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("create expired link: got %d %s", w.Code, w.Body)
+	}
+
+	token, hash, err := middleware.NewShareLinkToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiresAt := time.Now().Add(-time.Second)
+	link := models.ShareLink{ItemID: itemID, TokenHash: hash, Role: "viewer", ExpiresAt: &expiresAt, CreatedBy: owner}
+	if err := m.CreateShareLink(context.Background(), &link); err != nil {
+		t.Fatal(err)
+	}
+	if w := do(t, router, "GET", "/shared/"+token, 0, ""); w.Code != http.StatusNotFound {
+		t.Errorf("view expired link: got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestShareLinkPassword(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	router := newShareLinkRouter(m)
+
+	token := createShareLink(t, router, itemID, owner, `{"role": "viewer", "password": "hunter2"}`)
+
+	for password, want := range map[string]int{
+		"":        http.StatusUnauthorized,
+		"hunter3": http.StatusUnauthorized,
+		"hunter2": http.StatusOK,
+	} {
+		r := httptest.NewRequest("GET", "/shared/"+token, nil)
+		if password != "" {
+			r.Header.Set(middleware.ShareLinkPasswordHeader, password)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("password %q: got %d %s, want %d", password, w.Code, w.Body, want)
+		}
+	}
+}
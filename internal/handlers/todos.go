@@ -2,15 +2,57 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/onyeepeace/todo-api/internal/db"
+	"github.com/onyeepeace/todo-api/internal/events"
 	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
 )
 
-func GetTodosHandler(w http.ResponseWriter, r *http.Request) {
+// TodoHandler serves the /api/items/{item_id}/todos endpoints
+type TodoHandler struct {
+	Todos  store.TodoStore
+	Shares store.ShareStore
+	Events events.Publisher
+	// RequireIfMatch rejects todo mutations without an If-Match header
+	RequireIfMatch bool
+}
+
+// expectedVersion checks the request's If-Match header against the todo and
+// returns the version the write must be conditioned on. It writes the error
+// response itself and returns ok=false when the request can't proceed.
+func (h *TodoHandler) expectedVersion(w http.ResponseWriter, r *http.Request, itemID, todoID int) (version int, ok bool) {
+	match := r.Header.Get("If-Match")
+	if match == "" {
+		if h.RequireIfMatch {
+			http.Error(w, "Precondition Required - If-Match header missing", http.StatusPreconditionRequired)
+			return 0, false
+		}
+		return 0, true
+	}
+
+	current, err := h.Todos.GetTodo(r.Context(), itemID, todoID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Todo not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get todo", http.StatusInternalServerError)
+		}
+		return 0, false
+	}
+
+	if !current.ValidateETag(match) {
+		http.Error(w, "Precondition Failed - Todo has been modified", http.StatusPreconditionFailed)
+		return 0, false
+	}
+
+	return current.Version, true
+}
+
+func (h *TodoHandler) GetTodosHandler(w http.ResponseWriter, r *http.Request) {
 	itemIDStr := chi.URLParam(r, "item_id")
 	itemID, err := strconv.Atoi(itemIDStr)
 	if err != nil {
@@ -18,28 +60,20 @@ func GetTodosHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows, err := db.Query("SELECT todo_id, title, done FROM todos WHERE item_id = $1", itemID)
+	todos, err := h.Todos.ListTodos(r.Context(), itemID)
 	if err != nil {
 		http.Error(w, "Failed to retrieve todos", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var todos []models.Todo
-	for rows.Next() {
-		var todo models.Todo
-		if err := rows.Scan(&todo.TodoID, &todo.Title, &todo.Done); err != nil {
-			http.Error(w, "Failed to scan todo", http.StatusInternalServerError)
-			return
-		}
-		todos = append(todos, todo)
+	for i := range todos {
+		todos[i].ETag = todos[i].GenerateETag()
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(todos)
 }
 
-func CreateTodoHandler(w http.ResponseWriter, r *http.Request) {
+func (h *TodoHandler) CreateTodoHandler(w http.ResponseWriter, r *http.Request) {
 	itemIDStr := chi.URLParam(r, "item_id")
 	itemID, err := strconv.Atoi(itemIDStr)
 	if err != nil {
@@ -52,21 +86,22 @@ func CreateTodoHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	todo.ItemID = itemID
 
-	err = db.QueryRow(
-		"INSERT INTO todos (item_id, title, done) VALUES ($1, $2, $3) RETURNING todo_id",
-		itemID, todo.Title, todo.Done,
-	).Scan(&todo.TodoID)
-	if err != nil {
+	if err := h.Todos.CreateTodo(r.Context(), &todo); err != nil {
 		http.Error(w, "Failed to insert todo", http.StatusInternalServerError)
 		return
 	}
 
+	publishEvent(r, h.Events, h.Shares, models.Event{Type: models.EventTodoCreated, ItemID: itemID, TodoID: todo.TodoID, Todo: &todo})
+
+	todo.ETag = todo.GenerateETag()
+	w.Header().Set("ETag", todo.ETag)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(todo)
 }
 
-func GetTodoByIDHandler(w http.ResponseWriter, r *http.Request) {
+func (h *TodoHandler) GetTodoByIDHandler(w http.ResponseWriter, r *http.Request) {
 	itemIDStr := chi.URLParam(r, "item_id")
 	todoIDStr := chi.URLParam(r, "todo_id")
 	itemID, err1 := strconv.Atoi(itemIDStr)
@@ -76,19 +111,28 @@ func GetTodoByIDHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	row := db.QueryRow("SELECT todo_id, title, done FROM todos WHERE item_id = $1 AND todo_id = $2", itemID, todoID)
-
-	var todo models.Todo
-	if err := row.Scan(&todo.TodoID, &todo.Title, &todo.Done); err != nil {
+	todo, err := h.Todos.GetTodo(r.Context(), itemID, todoID)
+	if err != nil {
 		http.Error(w, "Todo not found", http.StatusNotFound)
 		return
 	}
 
+	todo.ETag = todo.GenerateETag()
+	w.Header().Set("ETag", todo.ETag)
+
+	// Check If-None-Match header for cache validation
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if match == todo.ETag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(todo)
 }
 
-func EditTodoHandler(w http.ResponseWriter, r *http.Request) {
+func (h *TodoHandler) EditTodoHandler(w http.ResponseWriter, r *http.Request) {
 	itemIDStr := chi.URLParam(r, "item_id")
 	todoIDStr := chi.URLParam(r, "todo_id")
 	itemID, err1 := strconv.Atoi(itemIDStr)
@@ -98,28 +142,37 @@ func EditTodoHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var updatedTodo models.Todo
-	if err := json.NewDecoder(r.Body).Decode(&updatedTodo); err != nil {
+	var todo models.Todo
+	if err := json.NewDecoder(r.Body).Decode(&todo); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	todo.ItemID = itemID
+	todo.TodoID = todoID
 
-	query := `UPDATE todos SET title = $1, done = $2 
-              WHERE item_id = $3 AND todo_id = $4 
-              RETURNING todo_id, title, done`
-	row := db.QueryRow(query, updatedTodo.Title, updatedTodo.Done, itemID, todoID)
+	version, ok := h.expectedVersion(w, r, itemID, todoID)
+	if !ok {
+		return
+	}
 
-	var todo models.Todo
-	if err := row.Scan(&todo.TodoID, &todo.Title, &todo.Done); err != nil {
-		http.Error(w, "Todo not found or update failed", http.StatusNotFound)
+	if err := h.Todos.UpdateTodo(r.Context(), &todo, version); err != nil {
+		if errors.Is(err, store.ErrPreconditionFailed) {
+			http.Error(w, "Precondition Failed - Todo has been modified", http.StatusPreconditionFailed)
+		} else {
+			http.Error(w, "Todo not found or update failed", http.StatusNotFound)
+		}
 		return
 	}
 
+	publishEvent(r, h.Events, h.Shares, models.Event{Type: models.EventTodoUpdated, ItemID: itemID, TodoID: todoID, Todo: &todo})
+
+	todo.ETag = todo.GenerateETag()
+	w.Header().Set("ETag", todo.ETag)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(todo)
 }
 
-func DeleteTodoHandler(w http.ResponseWriter, r *http.Request) {
+func (h *TodoHandler) DeleteTodoHandler(w http.ResponseWriter, r *http.Request) {
 	itemIDStr := chi.URLParam(r, "item_id")
 	todoIDStr := chi.URLParam(r, "todo_id")
 	itemID, err1 := strconv.Atoi(itemIDStr)
@@ -129,23 +182,29 @@ func DeleteTodoHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `DELETE FROM todos WHERE item_id = $1 AND todo_id = $2`
-	result, err := db.Exec(query, itemID, todoID)
-	if err != nil {
-		http.Error(w, "Failed to delete todo", http.StatusInternalServerError)
+	version, ok := h.expectedVersion(w, r, itemID, todoID)
+	if !ok {
 		return
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil || rowsAffected == 0 {
-		http.Error(w, "Todo not found", http.StatusNotFound)
+	if err := h.Todos.DeleteTodo(r.Context(), itemID, todoID, version); err != nil {
+		switch {
+		case errors.Is(err, store.ErrPreconditionFailed):
+			http.Error(w, "Precondition Failed - Todo has been modified", http.StatusPreconditionFailed)
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "Todo not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to delete todo", http.StatusInternalServerError)
+		}
 		return
 	}
 
+	publishEvent(r, h.Events, h.Shares, models.Event{Type: models.EventTodoDeleted, ItemID: itemID, TodoID: todoID})
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func MarkTodoDoneHandler(w http.ResponseWriter, r *http.Request) {
+func (h *TodoHandler) MarkTodoDoneHandler(w http.ResponseWriter, r *http.Request) {
 	itemIDStr := chi.URLParam(r, "item_id")
 	todoIDStr := chi.URLParam(r, "todo_id")
 	itemID, err1 := strconv.Atoi(itemIDStr)
@@ -155,11 +214,26 @@ func MarkTodoDoneHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := db.Exec("UPDATE todos SET done = true WHERE item_id = $1 AND todo_id = $2", itemID, todoID)
+	version, ok := h.expectedVersion(w, r, itemID, todoID)
+	if !ok {
+		return
+	}
+
+	todo, err := h.Todos.MarkTodoDone(r.Context(), itemID, todoID, version)
 	if err != nil {
-		http.Error(w, "Failed to mark todo as done", http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, store.ErrPreconditionFailed):
+			http.Error(w, "Precondition Failed - Todo has been modified", http.StatusPreconditionFailed)
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "Todo not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to mark todo as done", http.StatusInternalServerError)
+		}
 		return
 	}
 
+	publishEvent(r, h.Events, h.Shares, models.Event{Type: models.EventTodoUpdated, ItemID: itemID, TodoID: todoID, Todo: &todo})
+
+	w.Header().Set("ETag", todo.GenerateETag())
 	w.WriteHeader(http.StatusNoContent)
-}
\ No newline at end of file
+}
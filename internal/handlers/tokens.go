@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/middleware"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+const (
+	refreshTokenTTL    = 30 * 24 * time.Hour
+	refreshTokenCookie = "refresh_token"
+	// refreshTokenCookiePath keeps the refresh token off every request except
+	// the auth endpoints that need it
+	refreshTokenCookiePath = "/api/auth"
+)
+
+type tokenResponse struct {
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// randomToken returns n random bytes, base64url encoded
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is how refresh tokens and invitation tokens are looked up; the
+// tokens themselves are never stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// startSession signs the user in: it creates a session and issues its first
+// access and refresh tokens
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, userID int, useCookies bool) {
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	sessionID, err := randomToken(16)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	expiresAt := time.Now().Add(refreshTokenTTL)
+
+	session := models.Session{SessionID: sessionID, UserID: userID, ExpiresAt: expiresAt}
+	if err := h.Sessions.CreateSession(r.Context(), &session); err != nil {
+		log.Printf("Error creating session: %v", err)
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	stored := models.RefreshToken{
+		TokenHash: hashToken(refreshToken),
+		UserID:    userID,
+		FamilyID:  sessionID,
+		ExpiresAt: expiresAt,
+	}
+	if err := h.Tokens.CreateRefreshToken(r.Context(), &stored); err != nil {
+		log.Printf("Error storing refresh token: %v", err)
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeTokens(w, userID, sessionID, refreshToken, useCookies)
+}
+
+// writeTokens signs an access token and hands both tokens to the client,
+// either in the response body or as HttpOnly cookies
+func (h *AuthHandler) writeTokens(w http.ResponseWriter, userID int, sessionID, refreshToken string, useCookies bool) {
+	accessToken, err := h.AccessTokens.GenerateJWT(userID, sessionID)
+	if err != nil {
+		log.Printf("Error signing access token: %v", err)
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	response := tokenResponse{ExpiresIn: int(middleware.AccessTokenTTL.Seconds())}
+	if useCookies {
+		http.SetCookie(w, h.cookie(middleware.AccessTokenCookie, accessToken, "/", middleware.AccessTokenTTL))
+		http.SetCookie(w, h.cookie(refreshTokenCookie, refreshToken, refreshTokenCookiePath, refreshTokenTTL))
+	} else {
+		response.Token = accessToken
+		response.RefreshToken = refreshToken
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *AuthHandler) cookie(name, value, path string, maxAge time.Duration) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   !h.InsecureCookies,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+func (h *AuthHandler) clearTokenCookies(w http.ResponseWriter) {
+	for _, c := range []*http.Cookie{
+		h.cookie(middleware.AccessTokenCookie, "", "/", 0),
+		h.cookie(refreshTokenCookie, "", refreshTokenCookiePath, 0),
+	} {
+		c.MaxAge = -1
+		http.SetCookie(w, c)
+	}
+}
+
+// refreshTokenFrom reads the refresh token from the JSON body, falling back
+// to the cookie set in cookie mode
+func refreshTokenFrom(r *http.Request) (token string, fromCookie bool, err error) {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		return "", false, err
+	}
+	if body.RefreshToken != "" {
+		return body.RefreshToken, false, nil
+	}
+
+	cookie, err := r.Cookie(refreshTokenCookie)
+	if err != nil {
+		return "", false, nil
+	}
+	return cookie.Value, true, nil
+}
+
+// RefreshHandler swaps a refresh token for a new access token and a new
+// refresh token. Each refresh token works once.
+func (h *AuthHandler) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	refreshToken, fromCookie, err := refreshTokenFrom(r)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if refreshToken == "" {
+		http.Error(w, "refresh_token is required", http.StatusBadRequest)
+		return
+	}
+
+	nextToken, err := randomToken(32)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	next := models.RefreshToken{
+		TokenHash: hashToken(nextToken),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+
+	if err := h.Tokens.RotateRefreshToken(r.Context(), hashToken(refreshToken), &next); err != nil {
+		switch {
+		case errors.Is(err, store.ErrTokenReused):
+			log.Printf("Warning: refresh token reused, revoked session %s", next.FamilyID)
+			h.Revocations.Revoke(next.FamilyID)
+			h.clearTokenCookies(w)
+			http.Error(w, "Refresh token has already been used - sign in again", http.StatusUnauthorized)
+		case errors.Is(err, store.ErrNotFound):
+			h.clearTokenCookies(w)
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		default:
+			log.Printf("Error rotating refresh token: %v", err)
+			http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeTokens(w, next.UserID, next.FamilyID, nextToken, fromCookie)
+}
+
+// RevokeHandler revokes the session a refresh token belongs to
+func (h *AuthHandler) RevokeHandler(w http.ResponseWriter, r *http.Request) {
+	refreshToken, _, err := refreshTokenFrom(r)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if refreshToken == "" {
+		http.Error(w, "refresh_token is required", http.StatusBadRequest)
+		return
+	}
+
+	// Revoking an unknown or already revoked token is not an error
+	sessionID, err := h.Tokens.RevokeRefreshToken(r.Context(), hashToken(refreshToken))
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Error revoking refresh token: %v", err)
+		http.Error(w, "Failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+	if sessionID != "" {
+		h.Revocations.Revoke(sessionID)
+	}
+
+	h.clearTokenCookies(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutHandler revokes the session the request was made with, so neither
+// its access token nor its refresh token work any more
+func (h *AuthHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := r.Context().Value(models.SessionIDKey).(string)
+	if !ok {
+		http.Error(w, "Session ID not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.Sessions.RevokeSession(r.Context(), sessionID); err != nil {
+		log.Printf("Error revoking session: %v", err)
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+	h.Revocations.Revoke(sessionID)
+
+	h.clearTokenCookies(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAllHandler revokes every session of the current user, signing them
+// out everywhere
+func (h *AuthHandler) LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	sessionIDs, err := h.Sessions.RevokeUserSessions(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error revoking sessions: %v", err)
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+	h.Revocations.Revoke(sessionIDs...)
+
+	h.clearTokenCookies(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JWKSHandler publishes the public keys access tokens are signed with, so
+// other services can verify them
+func (h *AuthHandler) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.AccessTokens.Keys.JWKS())
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/jwtkeys"
+	"github.com/onyeepeace/todo-api/internal/middleware"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+// newAuthRouter serves the token endpoints from an AuthHandler backed by m,
+// plus GET /me, which answers 204 to requests with a working access token
+func newAuthRouter(t *testing.T, m *store.Memory) (http.Handler, *AuthHandler) {
+	t.Helper()
+	keys, err := jwtkeys.Open(jwtkeys.Config{Dir: t.TempDir(), Algorithm: jwtkeys.EdDSA, RotateEvery: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &AuthHandler{
+		Users:        m,
+		Identities:   m,
+		Sessions:     m,
+		Tokens:       m,
+		Invitations:  m,
+		AccessTokens: &middleware.AccessTokens{Keys: keys, Issuer: "todo-api", Audience: "todo-api"},
+		Revocations:  middleware.NewRevocationCache(m, time.Minute),
+	}
+
+	r := chi.NewRouter()
+	r.Post("/auth/refresh", h.RefreshHandler)
+	r.Post("/auth/revoke", h.RevokeHandler)
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.ValidateJWT(h.AccessTokens, h.Revocations))
+		r.With(middleware.RequireSession).Post("/auth/logout", h.LogoutHandler)
+		r.With(middleware.RequireSession).Post("/auth/logout-all", h.LogoutAllHandler)
+		r.Get("/me", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+	})
+	return r, h
+}
+
+// signIn starts a session for userID the way a provider callback does
+func signIn(t *testing.T, h *AuthHandler, userID int) tokenResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.startSession(w, httptest.NewRequest("GET", "/auth/test/callback", nil), userID, false)
+	if w.Code != http.StatusOK {
+		t.Fatalf("sign in: got %d %s", w.Code, w.Body)
+	}
+	var tokens tokenResponse
+	json.NewDecoder(w.Body).Decode(&tokens)
+	return tokens
+}
+
+// refresh swaps a refresh token, returning the response and the new tokens
+func refresh(t *testing.T, router http.Handler, refreshToken string) (*httptest.ResponseRecorder, tokenResponse) {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/auth/refresh", strings.NewReader(`{"refresh_token": "`+refreshToken+`"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	var tokens tokenResponse
+	if w.Code == http.StatusOK {
+		json.NewDecoder(w.Body).Decode(&tokens)
+	}
+	return w, tokens
+}
+
+// authenticated reports whether the access token is accepted
+func authenticated(t *testing.T, router http.Handler, accessToken string) bool {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/me", nil)
+	r.Header.Set("Authorization", "Bearer "+accessToken)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w.Code == http.StatusNoContent
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	m := store.NewMemory()
+	router, h := newAuthRouter(t, m)
+	first := signIn(t, h, newTestUser(t, m, "ada"))
+
+	w, second := refresh(t, router, first.RefreshToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh: got %d %s", w.Code, w.Body)
+	}
+	if second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+		t.Errorf("refresh: got refresh token %q, want a new one", second.RefreshToken)
+	}
+	if !authenticated(t, router, second.Token) {
+		t.Error("the refreshed access token isn't accepted")
+	}
+	if !authenticated(t, router, first.Token) {
+		t.Error("refreshing ended the session")
+	}
+
+	// The new refresh token works once in turn
+	if w, _ := refresh(t, router, second.RefreshToken); w.Code != http.StatusOK {
+		t.Errorf("second refresh: got %d %s", w.Code, w.Body)
+	}
+	if w, _ := refresh(t, router, "not-a-token"); w.Code != http.StatusUnauthorized {
+		t.Errorf("unknown token: got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	m := store.NewMemory()
+	router, h := newAuthRouter(t, m)
+	user := newTestUser(t, m, "ada")
+	first := signIn(t, h, user)
+	otherDevice := signIn(t, h, user)
+
+	_, second := refresh(t, router, first.RefreshToken)
+
+	// Someone replays the token that was already swapped
+	w, _ := refresh(t, router, first.RefreshToken)
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "already been used") {
+		t.Fatalf("reused token: got %d %s", w.Code, w.Body)
+	}
+
+	// Every token from that sign-in stops working, including the newest
+	if w, _ := refresh(t, router, second.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh after reuse: got %d %s", w.Code, w.Body)
+	}
+	for name, token := range map[string]string{"first": first.Token, "second": second.Token} {
+		if authenticated(t, router, token) {
+			t.Errorf("%s access token still works after reuse", name)
+		}
+	}
+
+	// Signing in elsewhere is a separate family
+	if !authenticated(t, router, otherDevice.Token) {
+		t.Error("reuse ended the user's other session")
+	}
+	if w, _ := refresh(t, router, otherDevice.RefreshToken); w.Code != http.StatusOK {
+		t.Errorf("refreshing the other session: got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	m := store.NewMemory()
+	router, h := newAuthRouter(t, m)
+	tokens := signIn(t, h, newTestUser(t, m, "ada"))
+
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest("POST", "/auth/revoke", strings.NewReader(`{"refresh_token": "`+tokens.RefreshToken+`"}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusNoContent {
+			t.Errorf("revoke %d: got %d %s", i+1, w.Code, w.Body)
+		}
+	}
+	if w, _ := refresh(t, router, tokens.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh after revoking: got %d %s", w.Code, w.Body)
+	}
+	if authenticated(t, router, tokens.Token) {
+		t.Error("access token still works after revoking")
+	}
+}
+
+// logout posts to one of the logout endpoints with the access token
+func logout(t *testing.T, router http.Handler, path, accessToken string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest("POST", path, nil)
+	r.Header.Set("Authorization", "Bearer "+accessToken)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestLogout(t *testing.T) {
+	m := store.NewMemory()
+	router, h := newAuthRouter(t, m)
+	user := newTestUser(t, m, "ada")
+	tokens := signIn(t, h, user)
+	otherDevice := signIn(t, h, user)
+
+	if w := logout(t, router, "/auth/logout", tokens.Token); w.Code != http.StatusNoContent {
+		t.Fatalf("logout: got %d %s", w.Code, w.Body)
+	}
+	// The access token stops working at once, not when it expires
+	if authenticated(t, router, tokens.Token) {
+		t.Error("access token still works after logging out")
+	}
+	if w, _ := refresh(t, router, tokens.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh after logging out: got %d %s", w.Code, w.Body)
+	}
+	if !authenticated(t, router, otherDevice.Token) {
+		t.Error("logging out ended the user's other session")
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	m := store.NewMemory()
+	router, h := newAuthRouter(t, m)
+	user := newTestUser(t, m, "ada")
+	sessions := []tokenResponse{signIn(t, h, user), signIn(t, h, user), signIn(t, h, user)}
+	someoneElse := signIn(t, h, newTestUser(t, m, "grace"))
+
+	if w := logout(t, router, "/auth/logout-all", sessions[0].Token); w.Code != http.StatusNoContent {
+		t.Fatalf("logout-all: got %d %s", w.Code, w.Body)
+	}
+	for i, tokens := range sessions {
+		if authenticated(t, router, tokens.Token) {
+			t.Errorf("session %d: access token still works", i+1)
+		}
+		if w, _ := refresh(t, router, tokens.RefreshToken); w.Code != http.StatusUnauthorized {
+			t.Errorf("session %d: refresh got %d %s", i+1, w.Code, w.Body)
+		}
+	}
+	if !authenticated(t, router, someoneElse.Token) {
+		t.Error("logout-all ended another user's session")
+	}
+
+	// Signing in again afterwards works as normal
+	if again := signIn(t, h, user); !authenticated(t, router, again.Token) {
+		t.Error("new session after logout-all isn't accepted")
+	}
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+// OfferTransferHandler offers the item to another user. Once they accept,
+// they own it and the current owner becomes an editor. A new offer replaces
+// any earlier one.
+func (h *ItemHandler) OfferTransferHandler(w http.ResponseWriter, r *http.Request) {
+	userID, itemID, ok := h.ownerParams(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		UserID int `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if body.UserID == 0 {
+		http.Error(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+	if body.UserID == userID {
+		http.Error(w, "You already own this item", http.StatusBadRequest)
+		return
+	}
+
+	transfer := models.OwnershipTransfer{ItemID: itemID, FromUserID: userID, ToUserID: body.UserID}
+	if err := h.Transfers.OfferTransfer(r.Context(), &transfer); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+		} else {
+			log.Printf("Error offering ownership transfer: %v", err)
+			http.Error(w, "Failed to offer ownership transfer", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(transfer)
+}
+
+// GetTransferHandler returns the item's pending ownership transfer
+func (h *ItemHandler) GetTransferHandler(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(chi.URLParam(r, "item_id"))
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	transfer, err := h.Transfers.GetTransfer(r.Context(), itemID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "No ownership transfer pending", http.StatusNotFound)
+		} else {
+			log.Printf("Error getting ownership transfer: %v", err)
+			http.Error(w, "Failed to retrieve ownership transfer", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfer)
+}
+
+// CancelTransferHandler withdraws the item's pending ownership transfer
+func (h *ItemHandler) CancelTransferHandler(w http.ResponseWriter, r *http.Request) {
+	_, itemID, ok := h.ownerParams(w, r)
+	if !ok {
+		return
+	}
+
+	h.deleteTransfer(w, r, itemID)
+}
+
+// AcceptTransferHandler takes over an item offered to the current user.
+// They don't need a role on the item yet.
+func (h *ItemHandler) AcceptTransferHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	itemID, err := strconv.Atoi(chi.URLParam(r, "item_id"))
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Transfers.AcceptTransfer(r.Context(), itemID, userID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "No ownership transfer pending for you", http.StatusNotFound)
+		case errors.Is(err, store.ErrLastOwner):
+			writeLastOwner(w)
+		default:
+			log.Printf("Error accepting ownership transfer: %v", err)
+			http.Error(w, "Failed to accept ownership transfer", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeCollaborator(w, r, itemID, userID)
+}
+
+// DeclineTransferHandler turns down an item offered to the current user
+func (h *ItemHandler) DeclineTransferHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	itemID, err := strconv.Atoi(chi.URLParam(r, "item_id"))
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	transfer, err := h.Transfers.GetTransfer(r.Context(), itemID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && transfer.ToUserID != userID) {
+		http.Error(w, "No ownership transfer pending for you", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting ownership transfer: %v", err)
+		http.Error(w, "Failed to decline ownership transfer", http.StatusInternalServerError)
+		return
+	}
+
+	h.deleteTransfer(w, r, itemID)
+}
+
+// ListIncomingTransfersHandler lists the items offered to the current user
+func (h *ItemHandler) ListIncomingTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	transfers, err := h.Transfers.ListTransfersTo(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error listing ownership transfers: %v", err)
+		http.Error(w, "Failed to retrieve ownership transfers", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfers)
+}
+
+func (h *ItemHandler) deleteTransfer(w http.ResponseWriter, r *http.Request, itemID int) {
+	if err := h.Transfers.DeleteTransfer(r.Context(), itemID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "No ownership transfer pending", http.StatusNotFound)
+		} else {
+			log.Printf("Error deleting ownership transfer: %v", err)
+			http.Error(w, "Failed to delete ownership transfer", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownerParams returns the current user and the item in the route, and
+// writes an error unless the user owns the item
+func (h *ItemHandler) ownerParams(w http.ResponseWriter, r *http.Request) (userID, itemID int, ok bool) {
+	userID, ok = r.Context().Value(models.UserIDKey).(int)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return 0, 0, false
+	}
+
+	itemID, err := strconv.Atoi(chi.URLParam(r, "item_id"))
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	role, err := h.Shares.GetRole(r.Context(), itemID, userID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Error getting user role: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return 0, 0, false
+	}
+	if role != "owner" {
+		http.Error(w, "Forbidden - only owners can transfer ownership", http.StatusForbidden)
+		return 0, 0, false
+	}
+	return userID, itemID, true
+}
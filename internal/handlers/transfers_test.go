@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+func TestOwnershipTransfer(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	recipient, bystander := newTestUser(t, m, "recipient"), newTestUser(t, m, "bystander")
+	router := newCollaboratorRouter(m)
+	transfer := "/items/" + strconv.Itoa(itemID) + "/transfer"
+
+	for _, tt := range []struct {
+		body string
+		want int
+	}{
+		{`{}`, http.StatusBadRequest},
+		{`{"user_id": ` + strconv.Itoa(owner) + `}`, http.StatusBadRequest},
+		{`{"user_id": 999}`, http.StatusNotFound},
+	} {
+		if w := do(t, router, "POST", transfer, owner, tt.body); w.Code != tt.want {
+			t.Errorf("offer %s: got %d %s, want %d", tt.body, w.Code, w.Body, tt.want)
+		}
+	}
+
+	if w := do(t, router, "POST", transfer, owner, `{"user_id": `+strconv.Itoa(recipient)+`}`); w.Code != http.StatusCreated {
+		t.Fatalf("offer: got %d %s", w.Code, w.Body)
+	}
+	if w := do(t, router, "GET", transfer, owner, ""); w.Code != http.StatusOK {
+		t.Errorf("owner looking at the offer: got %d %s", w.Code, w.Body)
+	}
+
+	// The recipient sees it among their offers before they have any role
+	w := do(t, router, "GET", "/me/transfers", recipient, "")
+	var incoming []models.OwnershipTransfer
+	json.NewDecoder(w.Body).Decode(&incoming)
+	if len(incoming) != 1 || incoming[0].ItemID != itemID || incoming[0].FromUserID != owner {
+		t.Errorf("incoming transfers: got %+v", incoming)
+	}
+
+	if w := do(t, router, "POST", transfer+"/accept", bystander, ""); w.Code != http.StatusNotFound {
+		t.Errorf("someone else accepting: got %d %s", w.Code, w.Body)
+	}
+	if w := do(t, router, "POST", transfer+"/decline", bystander, ""); w.Code != http.StatusNotFound {
+		t.Errorf("someone else declining: got %d %s", w.Code, w.Body)
+	}
+
+	w = do(t, router, "POST", transfer+"/accept", recipient, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("accept: got %d %s", w.Code, w.Body)
+	}
+	var accepted models.Collaborator
+	json.NewDecoder(w.Body).Decode(&accepted)
+	if accepted.UserID != recipient || accepted.Role != "owner" {
+		t.Errorf("accept: got %+v", accepted)
+	}
+	// The old owner stays on as an editor
+	if role := roleOf(t, m, itemID, owner); role != "editor" {
+		t.Errorf("old owner: got role %q, want editor", role)
+	}
+	if w := do(t, router, "POST", transfer+"/accept", recipient, ""); w.Code != http.StatusNotFound {
+		t.Errorf("accepting twice: got %d %s", w.Code, w.Body)
+	}
+	if w := do(t, router, "POST", transfer, owner, `{"user_id": `+strconv.Itoa(bystander)+`}`); w.Code != http.StatusForbidden {
+		t.Errorf("old owner offering the item again: got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestOnlyOwnersOfferTransfers(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	curatorRole := models.Role{Name: "curator", Permissions: []string{"can_view", "can_share"}}
+	if err := m.CreateRole(context.Background(), &curatorRole); err != nil {
+		t.Fatal(err)
+	}
+	curator := newTestUser(t, m, "curator")
+	share(t, m, itemID, curator, "curator", owner)
+	router := newCollaboratorRouter(m)
+
+	w := do(t, router, "POST", "/items/"+strconv.Itoa(itemID)+"/transfer", curator, `{"user_id": `+strconv.Itoa(curator)+`}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "only owners") {
+		t.Errorf("curator offering the item to themselves: got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestTransferLapsesWhenOwnerIsDemoted(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	coOwner, recipient := newTestUser(t, m, "co-owner"), newTestUser(t, m, "recipient")
+	share(t, m, itemID, coOwner, "owner", owner)
+	router := newCollaboratorRouter(m)
+	transfer := "/items/" + strconv.Itoa(itemID) + "/transfer"
+
+	if w := do(t, router, "POST", transfer, owner, `{"user_id": `+strconv.Itoa(recipient)+`}`); w.Code != http.StatusCreated {
+		t.Fatalf("offer: got %d %s", w.Code, w.Body)
+	}
+	if w := do(t, router, "PATCH", collaboratorPath(itemID, owner), coOwner, `{"role": "viewer"}`); w.Code != http.StatusOK {
+		t.Fatalf("demoting the owner who made the offer: got %d %s", w.Code, w.Body)
+	}
+	if w := do(t, router, "POST", transfer+"/accept", recipient, ""); w.Code != http.StatusNotFound {
+		t.Errorf("accepting an offer from someone no longer an owner: got %d %s", w.Code, w.Body)
+	}
+	if role := roleOf(t, m, itemID, recipient); role != "" {
+		t.Errorf("recipient got role %q", role)
+	}
+}
+
+func TestDeclineAndCancelTransfer(t *testing.T) {
+	m := store.NewMemory()
+	itemID, owner := newSharedList(t, m)
+	recipient := newTestUser(t, m, "recipient")
+	router := newCollaboratorRouter(m)
+	transfer := "/items/" + strconv.Itoa(itemID) + "/transfer"
+	offer := `{"user_id": ` + strconv.Itoa(recipient) + `}`
+
+	do(t, router, "POST", transfer, owner, offer)
+	if w := do(t, router, "POST", transfer+"/decline", recipient, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("decline: got %d %s", w.Code, w.Body)
+	}
+	if w := do(t, router, "GET", transfer, owner, ""); w.Code != http.StatusNotFound {
+		t.Errorf("offer after declining: got %d %s", w.Code, w.Body)
+	}
+
+	do(t, router, "POST", transfer, owner, offer)
+	if w := do(t, router, "DELETE", transfer, owner, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("cancel: got %d %s", w.Code, w.Body)
+	}
+	if w := do(t, router, "POST", transfer+"/accept", recipient, ""); w.Code != http.StatusNotFound {
+		t.Errorf("accepting a cancelled offer: got %d %s", w.Code, w.Body)
+	}
+	if w := do(t, router, "DELETE", transfer, owner, ""); w.Code != http.StatusNotFound {
+		t.Errorf("cancelling with nothing pending: got %d %s", w.Code, w.Body)
+	}
+}
@@ -5,19 +5,29 @@ import (
 	"log"
 	"net/http"
 
-	"github.com/onyeepeace/todo-api/internal/db"
 	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/oauth"
+	"github.com/onyeepeace/todo-api/internal/store"
 )
 
-func LookupUserHandler(w http.ResponseWriter, r *http.Request) {
+// UserHandler serves the /api/users endpoints
+type UserHandler struct {
+	Users          store.UserStore
+	Identities     store.IdentityStore
+	PersonalTokens store.PersonalTokenStore
+	// Providers are the identity providers accounts can be linked from
+	Providers *oauth.Registry
+	States    *oauth.States
+}
+
+func (h *UserHandler) LookupUserHandler(w http.ResponseWriter, r *http.Request) {
 	email := r.URL.Query().Get("email")
 	if email == "" {
 		http.Error(w, "Email is required", http.StatusBadRequest)
 		return
 	}
 
-	var userID int
-	err := db.QueryRow("SELECT user_id FROM users WHERE email = $1", email).Scan(&userID)
+	user, err := h.Users.GetUserByEmail(r.Context(), email)
 	if err != nil {
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
@@ -25,24 +35,18 @@ func LookupUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"user_id": userID,
+		"user_id": user.UserID,
 	})
 }
 
-func GetCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+func (h *UserHandler) GetCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(models.UserIDKey).(int)
 	if !ok {
 		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
 		return
 	}
 
-	var user models.User
-	err := db.QueryRow(`
-		SELECT user_id, email, username, created_at 
-		FROM users 
-		WHERE user_id = $1
-	`, userID).Scan(&user.UserID, &user.Email, &user.Username, &user.CreatedAt)
-
+	user, err := h.Users.GetUser(r.Context(), userID)
 	if err != nil {
 		log.Printf("Error getting user details: %v", err)
 		http.Error(w, "Failed to get user details", http.StatusInternalServerError)
@@ -51,4 +55,4 @@ func GetCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user)
-} 
\ No newline at end of file
+}
@@ -0,0 +1,125 @@
+// Package itemtypes validates item content against the JSON Schema of the
+// item's type.
+package itemtypes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/onyeepeace/todo-api/internal/store"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+var (
+	ErrUnknownType    = errors.New("unknown item type")
+	ErrInvalidSchema  = errors.New("invalid schema")
+	ErrInvalidContent = errors.New("content does not match the item type's schema")
+)
+
+// Registry compiles each type's schema once and validates content with it.
+// Types can't be changed after they are created, so compiled schemas never
+// go stale.
+type Registry struct {
+	types store.ItemTypeStore
+
+	mu       sync.Mutex
+	compiled map[string]*jsonschema.Schema
+}
+
+func NewRegistry(types store.ItemTypeStore) *Registry {
+	return &Registry{types: types, compiled: make(map[string]*jsonschema.Schema)}
+}
+
+// Validate checks content against the named type's schema
+func (r *Registry) Validate(ctx context.Context, itemType string, content json.RawMessage) error {
+	schema, err := r.schema(ctx, itemType)
+	if err != nil {
+		return err
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidContent, err)
+	}
+
+	if err := schema.Validate(value); err != nil {
+		var validationErr *jsonschema.ValidationError
+		if errors.As(err, &validationErr) {
+			return fmt.Errorf("%w: %s", ErrInvalidContent, describe(validationErr))
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *Registry) schema(ctx context.Context, itemType string) (*jsonschema.Schema, error) {
+	r.mu.Lock()
+	schema, ok := r.compiled[itemType]
+	r.mu.Unlock()
+	if ok {
+		return schema, nil
+	}
+
+	stored, err := r.types.GetItemType(ctx, itemType)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, fmt.Errorf("%w %q", ErrUnknownType, itemType)
+		}
+		return nil, err
+	}
+
+	schema, err = Compile(stored.Name, stored.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.compiled[itemType] = schema
+	r.mu.Unlock()
+	return schema, nil
+}
+
+// Compile parses a type's schema. Schemas must be self-contained: references
+// to other documents are refused rather than fetched.
+func Compile(name string, schema json.RawMessage) (*jsonschema.Schema, error) {
+	url := "item-type://" + name
+
+	compiler := jsonschema.NewCompiler()
+	compiler.LoadURL = func(s string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("external schema references are not allowed: %s", s)
+	}
+	if err := compiler.AddResource(url, bytes.NewReader(schema)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+
+	compiled, err := compiler.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	return compiled, nil
+}
+
+// describe flattens a validation error to its root causes on one line
+func describe(err *jsonschema.ValidationError) string {
+	if len(err.Causes) == 0 {
+		location := err.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		return location + ": " + err.Message
+	}
+
+	causes := make([]string, 0, len(err.Causes))
+	for _, cause := range err.Causes {
+		causes = append(causes, describe(cause))
+	}
+	return strings.Join(causes, "; ")
+}
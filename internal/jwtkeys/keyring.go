@@ -0,0 +1,423 @@
+// Package jwtkeys manages the asymmetric keys access tokens are signed with.
+//
+// Keys live in a directory as PKCS #8 PEM files named <kid>.pem, so every
+// replica pointed at the same directory signs and verifies with the same
+// keys. The key ring rotates on a schedule: a new key is published for an
+// overlap period before it starts signing, so anyone caching the JWKS has
+// seen it by then, and a retired key keeps verifying for another overlap
+// period so tokens it signed don't stop working early.
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+
+	rsaKeyBits = 2048
+)
+
+var ErrNoSigningKey = errors.New("no signing key")
+
+// Key is one signing key. Algorithm follows from the key type, so a token
+// can only ever be verified with the algorithm its key was made for.
+type Key struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+	CreatedAt time.Time
+}
+
+func (k *Key) Public() crypto.PublicKey {
+	return k.Private.Public()
+}
+
+type Config struct {
+	Dir string
+	// Algorithm is used for newly generated keys; existing keys keep theirs
+	Algorithm string
+	// RotateEvery is how old the newest key gets before another is generated.
+	// Zero turns generation off, for keys that are imported instead.
+	RotateEvery time.Duration
+	// Overlap is how long a new key is published before it signs, and how
+	// long a retired key still verifies. It is never shorter than the
+	// lifetime of the tokens being signed.
+	Overlap time.Duration
+}
+
+type KeyRing struct {
+	config Config
+
+	mu      sync.RWMutex
+	keys    map[string]*Key
+	signing *Key
+}
+
+// Open loads the key directory, creating it and its first key if needed.
+// With generation turned off, a key must have been imported first.
+func Open(config Config) (*KeyRing, error) {
+	switch config.Algorithm {
+	case RS256, EdDSA:
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", config.Algorithm)
+	}
+	if config.RotateEvery != 0 && config.RotateEvery <= config.Overlap {
+		return nil, fmt.Errorf("key rotation interval %s must be longer than the overlap %s", config.RotateEvery, config.Overlap)
+	}
+	if err := os.MkdirAll(config.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating key directory: %v", err)
+	}
+
+	ring := &KeyRing{config: config}
+	if err := ring.Refresh(); err != nil {
+		return nil, err
+	}
+	return ring, nil
+}
+
+// Run refreshes the key ring every interval until stop is closed
+func (r *KeyRing) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := r.Refresh(); err != nil {
+				log.Printf("Error refreshing signing keys: %v", err)
+			}
+		}
+	}
+}
+
+// Refresh reloads the key directory, generating a new key when the newest
+// one is due for rotation and deleting keys nothing can be signed with any
+// more
+func (r *KeyRing) Refresh() error {
+	now := time.Now()
+
+	keys, err := loadKeys(r.config.Dir)
+	if err != nil {
+		return err
+	}
+
+	if r.config.RotateEvery != 0 && (len(keys) == 0 || now.Sub(keys[len(keys)-1].CreatedAt) >= r.config.RotateEvery) {
+		key, err := generateKey(r.config.Dir, r.config.Algorithm)
+		if err != nil {
+			return fmt.Errorf("error generating signing key: %v", err)
+		}
+		log.Printf("Generated signing key %s", key.ID)
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return ErrNoSigningKey
+	}
+
+	// The newest key that has been published for long enough signs. Until
+	// there is one, e.g. on first start, the oldest key does.
+	signing := 0
+	for i, key := range keys {
+		if now.Sub(key.CreatedAt) >= r.config.Overlap {
+			signing = i
+		}
+	}
+
+	// Keys before the signing key were retired when their successor started
+	// signing, and are kept until tokens they signed have expired
+	expired := 0
+	for ; expired < signing; expired++ {
+		retiredAt := keys[expired+1].CreatedAt.Add(r.config.Overlap)
+		if now.Sub(retiredAt) < r.config.Overlap {
+			break
+		}
+		err := os.Remove(filepath.Join(r.config.Dir, keys[expired].ID+".pem"))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Error deleting retired signing key %s: %v", keys[expired].ID, err)
+		}
+	}
+	keys = keys[expired:]
+	signing -= expired
+
+	byID := make(map[string]*Key, len(keys))
+	for _, key := range keys {
+		byID[key.ID] = key
+	}
+
+	r.mu.Lock()
+	r.keys = byID
+	r.signing = keys[signing]
+	r.mu.Unlock()
+	return nil
+}
+
+// SigningKey returns the key new tokens are signed with
+func (r *KeyRing) SigningKey() (*Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.signing == nil {
+		return nil, ErrNoSigningKey
+	}
+	return r.signing, nil
+}
+
+// Key returns the key with the given ID, if tokens signed with it are still
+// accepted
+func (r *KeyRing) Key(id string) (*Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[id]
+	return key, ok
+}
+
+// Sign signs a token with the current signing key and names the key in its
+// kid header
+func (r *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	key, err := r.SigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// Keyfunc finds the key a token was signed with by its kid, for
+// jwt.Parse. The token must use that key's algorithm, so a token can't pick
+// a weaker algorithm than the key was made for.
+func (r *KeyRing) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := r.Key(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("key %q is not for %s", kid, token.Method.Alg())
+	}
+	return key.Public(), nil
+}
+
+// Keys returns every published key, oldest first
+func (r *KeyRing) Keys() []*Key {
+	r.mu.RLock()
+	keys := make([]*Key, 0, len(r.keys))
+	for _, key := range r.keys {
+		keys = append(keys, key)
+	}
+	r.mu.RUnlock()
+
+	sortKeys(keys)
+	return keys
+}
+
+func loadKeys(dir string) ([]*Key, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading key directory: %v", err)
+	}
+
+	var keys []*Key
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pem") {
+			continue
+		}
+		key, err := loadKey(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			// Another replica may have deleted it since the listing
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("error loading %s: %v", entry.Name(), err)
+		}
+		keys = append(keys, key)
+	}
+
+	sortKeys(keys)
+	return keys, nil
+}
+
+func loadKey(path string) (*Key, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := parseKey(data)
+	if err != nil {
+		return nil, err
+	}
+	key.ID = strings.TrimSuffix(filepath.Base(path), ".pem")
+	key.CreatedAt = info.ModTime()
+	return key, nil
+}
+
+func parseKey(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("not a PKCS #8 PEM private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		if private.N.BitLen() < rsaKeyBits {
+			return nil, fmt.Errorf("RSA keys must be at least %d bits", rsaKeyBits)
+		}
+		return &Key{Algorithm: RS256, Private: private}, nil
+	case ed25519.PrivateKey:
+		return &Key{Algorithm: EdDSA, Private: private}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+}
+
+// Import adds a key supplied from elsewhere, e.g. a secrets store, to the
+// key directory. Its ID is derived from the public key, so replicas
+// importing the same key agree on it and importing it again does nothing.
+// Like a generated key, it is published for the overlap period before it
+// signs.
+func Import(dir string, data []byte) (*Key, error) {
+	key, err := parseKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+	id := hex.EncodeToString(sum[:8])
+
+	if existing, err := loadKey(filepath.Join(dir, id+".pem")); err == nil {
+		return existing, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating key directory: %v", err)
+	}
+	return writeKey(dir, id, key.Private)
+}
+
+// generateKey writes a new key with a random ID to the directory
+func generateKey(dir, algorithm string) (*Key, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	var private crypto.Signer
+	var err error
+	switch algorithm {
+	case RS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case EdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return writeKey(dir, hex.EncodeToString(id), private)
+}
+
+// writeKey saves a key as <id>.pem. The file is renamed into place so other
+// replicas never read a partial key.
+func writeKey(dir, id string, private crypto.Signer) (*Key, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".key-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := pem.Encode(tmp, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, id+".pem")
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+	return loadKey(path)
+}
+
+func sortKeys(keys []*Key) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+}
+
+// ConfigFromEnv builds a Config from the JWT_* environment variables,
+// falling back to RS256 keys in ./keys rotated every 30 days
+func ConfigFromEnv() (Config, error) {
+	config := Config{
+		Dir:         os.Getenv("JWT_KEY_DIR"),
+		Algorithm:   os.Getenv("JWT_ALG"),
+		RotateEvery: 30 * 24 * time.Hour,
+		Overlap:     time.Hour,
+	}
+	if config.Dir == "" {
+		config.Dir = "keys"
+	}
+	if config.Algorithm == "" {
+		config.Algorithm = RS256
+	}
+
+	for name, d := range map[string]*time.Duration{
+		"JWT_KEY_ROTATION": &config.RotateEvery,
+		"JWT_KEY_OVERLAP":  &config.Overlap,
+	} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %v", name, err)
+		}
+		*d = parsed
+	}
+	return config, nil
+}
@@ -0,0 +1,192 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// age makes the key look like it was created ago
+func age(t *testing.T, dir string, key *Key, ago time.Duration) {
+	t.Helper()
+	created := time.Now().Add(-ago)
+	if err := os.Chtimes(filepath.Join(dir, key.ID+".pem"), created, created); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func signingKey(t *testing.T, ring *KeyRing) *Key {
+	t.Helper()
+	key, err := ring.SigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// sign returns a token signed by the ring's current key
+func sign(t *testing.T, ring *KeyRing) string {
+	t.Helper()
+	token, err := ring.Sign(jwt.RegisteredClaims{Subject: "ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func verifies(ring *KeyRing, token string) bool {
+	_, err := jwt.Parse(token, ring.Keyfunc, jwt.WithValidMethods([]string{RS256, EdDSA}))
+	return err == nil
+}
+
+func published(ring *KeyRing, key *Key) bool {
+	for _, jwk := range ring.JWKS().Keys {
+		if jwk.KeyID == key.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	ring, err := Open(Config{Dir: dir, Algorithm: EdDSA, RotateEvery: 24 * time.Hour, Overlap: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// On first start the only key signs straight away
+	first := signingKey(t, ring)
+	oldToken := sign(t, ring)
+
+	// Once it is due, a new key is published but doesn't sign yet
+	age(t, dir, first, 25*time.Hour)
+	if err := ring.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	keys := ring.Keys()
+	if len(keys) != 2 {
+		t.Fatalf("got %d keys after rotating, want 2", len(keys))
+	}
+	second := keys[1]
+	if !published(ring, second) {
+		t.Error("the new key isn't in the JWKS")
+	}
+	if signingKey(t, ring).ID != first.ID {
+		t.Error("the new key signs before the overlap is over")
+	}
+
+	// After the overlap it signs, and the old key still verifies
+	age(t, dir, second, 90*time.Minute)
+	if err := ring.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if signingKey(t, ring).ID != second.ID {
+		t.Error("the new key doesn't sign after the overlap")
+	}
+	if !verifies(ring, oldToken) || !published(ring, first) {
+		t.Error("the retired key stopped verifying during the overlap")
+	}
+	if !verifies(ring, sign(t, ring)) {
+		t.Error("a token from the new key doesn't verify")
+	}
+
+	// Once the retired key's overlap is over, it is deleted
+	age(t, dir, second, 3*time.Hour)
+	if err := ring.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if verifies(ring, oldToken) || published(ring, first) {
+		t.Error("the retired key still verifies after its overlap")
+	}
+	if _, err := os.Stat(filepath.Join(dir, first.ID+".pem")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the retired key's file is still there: %v", err)
+	}
+}
+
+func TestReplicasShareKeys(t *testing.T) {
+	dir := t.TempDir()
+	config := Config{Dir: dir, Algorithm: EdDSA, RotateEvery: 24 * time.Hour, Overlap: time.Hour}
+	a, err := Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signingKey(t, a).ID != signingKey(t, b).ID {
+		t.Error("replicas on the same directory sign with different keys")
+	}
+	if !verifies(b, sign(t, a)) {
+		t.Error("a token from one replica doesn't verify on another")
+	}
+}
+
+func TestImport(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	dir := t.TempDir()
+	// Without generation, there must be a key to sign with
+	if _, err := Open(Config{Dir: dir, Algorithm: EdDSA}); !errors.Is(err, ErrNoSigningKey) {
+		t.Fatalf("opening an empty directory: got %v", err)
+	}
+
+	imported, err := Import(dir, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Import(dir, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != imported.ID {
+		t.Errorf("importing the same key again gave ID %s, then %s", imported.ID, again.ID)
+	}
+
+	ring, err := Open(Config{Dir: dir, Algorithm: EdDSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signingKey(t, ring).ID != imported.ID || len(ring.Keys()) != 1 {
+		t.Errorf("got signing key %s of %d, want the imported one", signingKey(t, ring).ID, len(ring.Keys()))
+	}
+
+	if _, err := Import(dir, []byte("not a key")); err == nil {
+		t.Error("importing garbage: got no error")
+	}
+}
+
+func TestKeyfuncRejectsOtherAlgorithms(t *testing.T) {
+	ring, err := Open(Config{Dir: t.TempDir(), Algorithm: EdDSA, RotateEvery: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A token naming the Ed25519 key but claiming to be HMAC signed
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "ada"})
+	token.Header["kid"] = signingKey(t, ring).ID
+	forged, err := token.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(forged, ring.Keyfunc); err == nil {
+		t.Error("a token using another algorithm verified")
+	}
+}
@@ -0,0 +1,103 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config says where to send email from. Without an SMTP server, email is
+// only logged.
+type Config struct {
+	// Addr is the SMTP server's host:port
+	Addr string
+	From string
+}
+
+// ConfigFromEnv reads SMTP_ADDR and SMTP_FROM. The SMTP credentials are
+// secrets and are set separately.
+func ConfigFromEnv() Config {
+	return Config{Addr: os.Getenv("SMTP_ADDR"), From: os.Getenv("SMTP_FROM")}
+}
+
+// SMTP sends email through an SMTP server, authenticating with PLAIN auth
+// once credentials are set. The server has to support STARTTLS for the
+// credentials to be sent.
+type SMTP struct {
+	Config
+
+	mu       sync.RWMutex
+	username string
+	password string
+}
+
+func NewSMTP(config Config) *SMTP {
+	return &SMTP{Config: config}
+}
+
+// SetCredentials replaces the SMTP username and password, e.g. when they
+// are rotated
+func (s *SMTP) SetCredentials(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.username, s.password = username, password
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP_ADDR: %w", err)
+	}
+
+	s.mu.RLock()
+	var auth smtp.Auth
+	if s.username != "" {
+		auth = smtp.PlainAuth("", s.username, s.password, host)
+	}
+	s.mu.RUnlock()
+
+	return smtp.SendMail(s.Addr, auth, s.From, []string{msg.To}, format(s.From, msg))
+}
+
+// Log writes email to the log instead of sending it, for local development
+type Log struct{}
+
+func (Log) Send(ctx context.Context, msg Message) error {
+	log.Printf("Email to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", header(from))
+	fmt.Fprintf(&b, "To: %s\r\n", header(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", header(msg.Subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// header keeps a value on its header line, so a subject taken from user
+// input can't add headers of its own
+func header(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
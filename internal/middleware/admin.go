@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+// AdminChecker reports whether a user is an admin
+type AdminChecker interface {
+	IsAdmin(ctx context.Context, userID int) (bool, error)
+}
+
+// RequireAdmin only lets admins through, for endpoints that change how the
+// whole API behaves, such as what roles grant
+func RequireAdmin(checker AdminChecker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := r.Context().Value(models.UserIDKey).(int)
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			admin, err := checker.IsAdmin(r.Context(), userID)
+			if err != nil {
+				log.Printf("Error checking admin: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if !admin {
+				http.Error(w, "Forbidden - only admins can do this", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
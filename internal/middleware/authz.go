@@ -1,7 +1,7 @@
 package middleware
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 	"net/http"
 	"strconv"
@@ -15,8 +15,18 @@ var (
 	ErrForbidden    = errors.New("forbidden")
 )
 
-// Authorize middleware checks if the user has the required permission
-func Authorize(db *sql.DB, requiredPermission string) func(http.Handler) http.Handler {
+// PermissionChecker reports whether a user's role on an item grants a permission
+type PermissionChecker interface {
+	HasPermission(ctx context.Context, userID, itemID int, permission string) (bool, error)
+}
+
+// Authorize middleware checks if the user's role on the item in the route
+// grants the required permission: can_view, can_edit, can_complete,
+// can_share or can_delete. Editing an item doesn't imply deleting or
+// sharing it. Roles, built-in or custom, grant whatever the role_permissions
+// table says. People who only have a share link are checked by
+// AuthorizeShareLink.
+func Authorize(checker PermissionChecker, requiredPermission string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			userID, ok := r.Context().Value(models.UserIDKey).(int)
@@ -41,26 +51,14 @@ func Authorize(db *sql.DB, requiredPermission string) func(http.Handler) http.Ha
 			}
 
 			// Check if user has the required permission
-			var exists bool
-			err = db.QueryRow(`
-				SELECT EXISTS (
-					SELECT 1 FROM user_roles ur
-					JOIN roles r ON ur.role_id = r.role_id
-					JOIN role_permissions rp ON r.role_id = rp.role_id
-					JOIN permissions p ON rp.permission_id = p.permission_id
-					WHERE ur.user_id = $1
-					AND ur.item_id = $2
-					AND p.name = $3
-				)
-			`, userID, itemID, requiredPermission).Scan(&exists)
-
+			exists, err := checker.HasPermission(r.Context(), userID, itemID, requiredPermission)
 			if err != nil {
 				http.Error(w, "Internal server error", http.StatusInternalServerError)
 				return
 			}
 
 			if !exists {
-				http.Error(w, "Forbidden", http.StatusForbidden)
+				http.Error(w, "Forbidden - your role on this item doesn't grant "+requiredPermission, http.StatusForbidden)
 				return
 			}
 
@@ -68,48 +66,3 @@ func Authorize(db *sql.DB, requiredPermission string) func(http.Handler) http.Ha
 		})
 	}
 }
-
-// GetUserPermissions returns all permissions a user has for an item
-func GetUserPermissions(db *sql.DB, userID, itemID int) ([]string, error) {
-	rows, err := db.Query(`
-		SELECT DISTINCT p.name 
-		FROM user_roles ur
-		JOIN roles r ON ur.role_id = r.role_id
-		JOIN role_permissions rp ON r.role_id = rp.role_id
-		JOIN permissions p ON rp.permission_id = p.permission_id
-		WHERE ur.user_id = $1 AND ur.item_id = $2
-	`, userID, itemID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var permissions []string
-	for rows.Next() {
-		var perm string
-		if err := rows.Scan(&perm); err != nil {
-			return nil, err
-		}
-		permissions = append(permissions, perm)
-	}
-
-	return permissions, nil
-}
-
-// CheckPermission checks if a user has a specific permission for an item
-func CheckPermission(db *sql.DB, userID, itemID int, permission string) (bool, error) {
-	var exists bool
-	err := db.QueryRow(`
-		SELECT EXISTS (
-			SELECT 1 FROM user_roles ur
-			JOIN roles r ON ur.role_id = r.role_id
-			JOIN role_permissions rp ON r.role_id = rp.role_id
-			JOIN permissions p ON rp.permission_id = p.permission_id
-			WHERE ur.user_id = $1
-			AND ur.item_id = $2
-			AND p.name = $3
-		)
-	`, userID, itemID, permission).Scan(&exists)
-
-	return exists, err
-}
\ No newline at end of file
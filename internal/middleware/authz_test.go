@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+func TestRequireItemTypeAfterAuthorize(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemory()
+	owner := newTestUser(t, m, "owner")
+	note := models.Item{Name: "Plans", ItemType: models.ItemTypeNote, Content: json.RawMessage(`{"text": ""}`)}
+	if err := m.CreateItem(ctx, &note, owner); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/api/items/"+strconv.Itoa(note.ItemID)+"/todos/", nil)
+	r.Header.Set("X-Test-User", strconv.Itoa(owner))
+	w := httptest.NewRecorder()
+	router := chi.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, _ := strconv.Atoi(r.Header.Get("X-Test-User"))
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), models.UserIDKey, userID)))
+		})
+	})
+	router.With(Authorize(m, "can_view"), RequireItemType(m, models.ItemTypeTodoList)).Get("/api/items/{item_id}/todos/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusConflict {
+		t.Errorf("todos of a note: got %d %s", w.Code, w.Body)
+	}
+}
+
+// failingItems is an ItemGetter whose store is down
+type failingItems struct{}
+
+func (failingItems) GetItem(ctx context.Context, itemID int) (models.Item, error) {
+	return models.Item{}, errors.New("connection refused")
+}
+
+func TestRequireItemTypeWithoutItem(t *testing.T) {
+	tests := []struct {
+		name  string
+		items ItemGetter
+		want  int
+	}{
+		{"deleted item", store.NewMemory(), http.StatusNotFound},
+		{"store error", failingItems{}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		router := chi.NewRouter()
+		router.With(RequireItemType(tt.items, models.ItemTypeTodoList)).Get("/api/items/{item_id}/todos/", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/items/1/todos/", nil))
+		if w.Code != tt.want {
+			t.Errorf("%s: got %d %s, want %d", tt.name, w.Code, w.Body, tt.want)
+		}
+	}
+}
+
+// newTestUser adds a user to the store and returns their ID
+func newTestUser(t *testing.T, m *store.Memory, name string) int {
+	t.Helper()
+	user := models.User{Email: name + "@example.com", Username: name}
+	identity := models.Identity{Provider: "test", Subject: name, Email: user.Email, EmailVerified: true}
+	if err := m.CreateUserWithIdentity(context.Background(), &user, &identity); err != nil {
+		t.Fatalf("creating user %s: %v", name, err)
+	}
+	return user.UserID
+}
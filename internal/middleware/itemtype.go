@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+)
+
+// ItemGetter looks up the item a request is for
+type ItemGetter interface {
+	GetItem(ctx context.Context, itemID int) (models.Item, error)
+}
+
+// RequireItemType rejects requests for items of any other type, for routes
+// that only make sense for one kind of item. It runs after Authorize, but
+// the item can still be deleted in between.
+func RequireItemType(items ItemGetter, itemType string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			itemID, err := strconv.Atoi(chi.URLParam(r, "item_id"))
+			if err != nil {
+				http.Error(w, "Invalid item ID", http.StatusBadRequest)
+				return
+			}
+
+			item, err := items.GetItem(r.Context(), itemID)
+			if errors.Is(err, store.ErrNotFound) {
+				http.Error(w, "Item not found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Printf("Error getting item: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			if item.ItemType != itemType {
+				http.Error(w, "Conflict - this is a "+item.ItemType+" item, not a "+itemType, http.StatusConflict)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -2,58 +2,141 @@ package middleware
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
-	"github.com/joho/godotenv"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/onyeepeace/todo-api/internal/jwtkeys"
 	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
 )
 
-func init() {
-	envErr := godotenv.Load()
-	if envErr != nil {
-		log.Println("Warning: .env file not found.")
-	}
+// AccessTokenTTL is how long an access token lasts. Clients get a new one
+// from POST /api/auth/refresh.
+const AccessTokenTTL = 15 * time.Minute
+
+// AccessTokenCookie carries the access token for browser clients that use
+// HttpOnly cookies instead of the Authorization header
+const AccessTokenCookie = "access_token"
+
+// AccessTokens signs access tokens with the key ring's current key and
+// verifies them against its published keys
+type AccessTokens struct {
+	Keys     *jwtkeys.KeyRing
+	Issuer   string
+	Audience string
+	// PersonalTokens looks up personal access tokens, which are accepted in
+	// place of a JWT. They aren't accepted if it is nil.
+	PersonalTokens PersonalTokenChecker
 }
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
+// GenerateJWT signs an access token for the user's session. Every token gets
+// its own ID.
+func (a *AccessTokens) GenerateJWT(userID int, sessionID string) (string, error) {
+	tokenID := make([]byte, 16)
+	if _, err := rand.Read(tokenID); err != nil {
+		return "", err
+	}
 
-func GenerateJWT(userID int) (string, error) {
+	now := time.Now()
 	claims := &models.JWTClaims{
-		UserID: userID,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: jwt.TimeFunc().Add(24 * time.Hour).Unix(),
+		UserID:    userID,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(tokenID),
+			Issuer:    a.Issuer,
+			Subject:   fmt.Sprint(userID),
+			Audience:  jwt.ClaimStrings{a.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return a.Keys.Sign(claims)
 }
 
-func ValidateJWT(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header missing", http.StatusUnauthorized)
-			return
-		}
+// parse verifies a token's signature and claims against the key ring
+func (a *AccessTokens) parse(tokenStr string) (*models.JWTClaims, error) {
+	claims := &models.JWTClaims{}
+	_, err := jwt.ParseWithClaims(tokenStr, claims, a.Keys.Keyfunc,
+		jwt.WithValidMethods([]string{jwtkeys.RS256, jwtkeys.EdDSA}),
+		jwt.WithIssuer(a.Issuer),
+		jwt.WithAudience(a.Audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.SessionID == "" {
+		return nil, errors.New("token has no session")
+	}
+	return claims, nil
+}
 
-		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-		claims := &models.JWTClaims{}
+// ValidateJWT authenticates requests by their access token and rejects
+// tokens whose session has been revoked. revocations may be nil to skip
+// that check. A personal access token works too; its scopes go in the
+// context for RequireScope.
+func ValidateJWT(tokens *AccessTokens, revocations *RevocationCache) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
+			if authHeader == "" {
+				cookie, err := r.Cookie(AccessTokenCookie)
+				if err != nil {
+					http.Error(w, "Authorization header missing", http.StatusUnauthorized)
+					return
+				}
+				tokenStr = cookie.Value
+			}
 
-		token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
+			if isPersonalToken(tokenStr) && tokens.PersonalTokens != nil {
+				token, err := tokens.PersonalTokens.UsePersonalToken(r.Context(), HashPersonalToken(tokenStr))
+				if errors.Is(err, store.ErrNotFound) {
+					http.Error(w, "Invalid token", http.StatusUnauthorized)
+					return
+				}
+				if err != nil {
+					log.Printf("Error checking personal access token: %v", err)
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+					return
+				}
+
+				ctx := context.WithValue(r.Context(), models.UserIDKey, token.UserID)
+				ctx = context.WithValue(ctx, models.ScopesKey, token.Scopes)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			claims, err := tokens.parse(tokenStr)
+			if err != nil {
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+
+			if revocations != nil {
+				revoked, err := revocations.Revoked(r.Context(), claims.SessionID)
+				if err != nil {
+					log.Printf("Error checking session %s: %v", claims.SessionID, err)
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+					return
+				}
+				if revoked {
+					http.Error(w, "Session has been revoked", http.StatusUnauthorized)
+					return
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), models.UserIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, models.SessionIDKey, claims.SessionID)
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
-		if err != nil || !token.Valid {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
-
-		r = r.WithContext(context.WithValue(r.Context(), models.UserIDKey, claims.UserID))
-		next.ServeHTTP(w, r)
-	})
-}
\ No newline at end of file
+	}
+}
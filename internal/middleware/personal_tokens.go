@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+// PersonalTokenPrefix starts every personal access token, so they can be
+// told apart from JWTs and spotted by secret scanners
+const PersonalTokenPrefix = "todo_pat_"
+
+// PersonalTokenChecker looks up personal access tokens by their hash
+type PersonalTokenChecker interface {
+	UsePersonalToken(ctx context.Context, tokenHash string) (models.PersonalToken, error)
+}
+
+// NewPersonalToken returns a new personal access token and the hash it is
+// stored under
+func NewPersonalToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = PersonalTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return token, HashPersonalToken(token), nil
+}
+
+// HashPersonalToken is how personal access tokens are looked up; the tokens
+// themselves are never stored
+func HashPersonalToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// scopes returns the scopes the request's personal access token was granted,
+// and false for requests made with a session's access token
+func scopes(r *http.Request) ([]string, bool) {
+	scopes, ok := r.Context().Value(models.ScopesKey).([]string)
+	return scopes, ok
+}
+
+// RequireScope limits requests made with a personal access token to those
+// whose token was granted scope. Requests made with a session's access
+// token aren't limited.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if granted, ok := scopes(r); ok && !hasScope(granted, scope) {
+				http.Error(w, "Forbidden - token is missing the "+scope+" scope", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireReadWriteScope is RequireScope with the read scope for GET and HEAD
+// requests and the write scope for everything else
+func RequireReadWriteScope(read, write string) func(http.Handler) http.Handler {
+	requireRead, requireWrite := RequireScope(read), RequireScope(write)
+	return func(next http.Handler) http.Handler {
+		readHandler, writeHandler := requireRead(next), requireWrite(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				readHandler.ServeHTTP(w, r)
+			} else {
+				writeHandler.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// RequireSession rejects personal access tokens, for endpoints that manage
+// the account itself, such as minting more tokens
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := scopes(r); ok {
+			http.Error(w, "Forbidden - sign in to do this", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func hasScope(granted []string, scope string) bool {
+	for _, s := range granted {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// isPersonalToken reports whether a bearer token is a personal access token
+// rather than a JWT
+func isPersonalToken(token string) bool {
+	return strings.HasPrefix(token, PersonalTokenPrefix)
+}
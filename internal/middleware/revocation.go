@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SessionChecker reports whether a session can no longer be used
+type SessionChecker interface {
+	SessionRevoked(ctx context.Context, sessionID string) (bool, error)
+}
+
+// RevocationCache remembers session lookups so ValidateJWT doesn't cost a
+// database round-trip per request. Revocations made through this process
+// apply at once; ones made by other replicas are noticed within ttl.
+type RevocationCache struct {
+	checker SessionChecker
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	entries map[string]revocationEntry
+}
+
+type revocationEntry struct {
+	revoked bool
+	expires time.Time
+}
+
+// maxRevocationEntries bounds the cache; expired entries are swept once it
+// grows past this
+const maxRevocationEntries = 10000
+
+func NewRevocationCache(checker SessionChecker, ttl time.Duration) *RevocationCache {
+	return &RevocationCache{
+		checker: checker,
+		ttl:     ttl,
+		entries: make(map[string]revocationEntry),
+	}
+}
+
+// Revoked reports whether the session has been revoked
+func (c *RevocationCache) Revoked(ctx context.Context, sessionID string) (bool, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[sessionID]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := c.checker.SessionRevoked(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	// A revoked session never comes back, so remember that for as long as
+	// any access token for it could still be presented
+	expires := now.Add(c.ttl)
+	if revoked {
+		expires = now.Add(AccessTokenTTL)
+	}
+	c.store(sessionID, revocationEntry{revoked: revoked, expires: expires})
+	return revoked, nil
+}
+
+// Revoke records sessions this process has just revoked
+func (c *RevocationCache) Revoke(sessionIDs ...string) {
+	if c == nil {
+		return
+	}
+	expires := time.Now().Add(AccessTokenTTL)
+	for _, sessionID := range sessionIDs {
+		c.store(sessionID, revocationEntry{revoked: true, expires: expires})
+	}
+}
+
+func (c *RevocationCache) store(sessionID string, entry revocationEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= maxRevocationEntries {
+		now := time.Now()
+		for id, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, id)
+			}
+		}
+	}
+	c.entries[sessionID] = entry
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeSessions is a SessionChecker that counts lookups
+type fakeSessions struct {
+	revoked map[string]bool
+	lookups int
+	err     error
+}
+
+func (f *fakeSessions) SessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	f.lookups++
+	return f.revoked[sessionID], f.err
+}
+
+func TestRevocationCacheRemembersLookups(t *testing.T) {
+	ctx := context.Background()
+	sessions := &fakeSessions{revoked: map[string]bool{"gone": true}}
+	cache := NewRevocationCache(sessions, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if revoked, err := cache.Revoked(ctx, "live"); err != nil || revoked {
+			t.Fatalf("live session: got %v, %v", revoked, err)
+		}
+		if revoked, err := cache.Revoked(ctx, "gone"); err != nil || !revoked {
+			t.Fatalf("revoked session: got %v, %v", revoked, err)
+		}
+	}
+	if sessions.lookups != 2 {
+		t.Errorf("got %d lookups, want one per session", sessions.lookups)
+	}
+}
+
+func TestRevocationCacheRevokeAppliesAtOnce(t *testing.T) {
+	ctx := context.Background()
+	sessions := &fakeSessions{}
+	cache := NewRevocationCache(sessions, time.Minute)
+
+	cache.Revoked(ctx, "session")
+	cache.Revoke("session")
+	if revoked, _ := cache.Revoked(ctx, "session"); !revoked {
+		t.Error("a session revoked through the cache is still live")
+	}
+	if sessions.lookups != 1 {
+		t.Errorf("got %d lookups, want 1", sessions.lookups)
+	}
+
+	// Handlers without a cache can call Revoke on nil
+	var none *RevocationCache
+	none.Revoke("session")
+}
+
+func TestRevocationCacheNoticesOtherReplicasAfterTTL(t *testing.T) {
+	ctx := context.Background()
+	sessions := &fakeSessions{revoked: map[string]bool{}}
+	cache := NewRevocationCache(sessions, 20*time.Millisecond)
+
+	cache.Revoked(ctx, "session")
+	// Another replica revokes it in the database
+	sessions.revoked["session"] = true
+	if revoked, _ := cache.Revoked(ctx, "session"); revoked {
+		t.Error("the cached answer was not used")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if revoked, _ := cache.Revoked(ctx, "session"); !revoked {
+		t.Error("a revocation elsewhere wasn't noticed after the TTL")
+	}
+}
+
+func TestRevocationCacheDoesNotRememberErrors(t *testing.T) {
+	ctx := context.Background()
+	sessions := &fakeSessions{err: errors.New("database is down")}
+	cache := NewRevocationCache(sessions, time.Minute)
+
+	if _, err := cache.Revoked(ctx, "session"); err == nil {
+		t.Fatal("got no error")
+	}
+	sessions.err = nil
+	sessions.revoked = map[string]bool{"session": true}
+	if revoked, err := cache.Revoked(ctx, "session"); err != nil || !revoked {
+		t.Errorf("after the database recovered: got %v, %v", revoked, err)
+	}
+}
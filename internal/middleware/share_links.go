@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/onyeepeace/todo-api/internal/models"
+	"github.com/onyeepeace/todo-api/internal/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ShareLinkPasswordHeader carries the password of a password-protected
+// share link
+const ShareLinkPasswordHeader = "X-Share-Password"
+
+// ShareLinkChecker looks up share links by their hash and says what their
+// roles grant
+type ShareLinkChecker interface {
+	GetShareLink(ctx context.Context, tokenHash string) (models.ShareLink, error)
+	RoleHasPermission(ctx context.Context, role, permission string) (bool, error)
+}
+
+// NewShareLinkToken returns a new share link token and the hash it is
+// stored under
+func NewShareLinkToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashShareLinkToken(token), nil
+}
+
+// HashShareLinkToken is how share links are looked up; like personal access
+// tokens, the tokens themselves are never stored
+func HashShareLinkToken(token string) string {
+	return HashPersonalToken(token)
+}
+
+// AuthorizeShareLink is Authorize for the share link whose token is in the
+// route: the link must still work, its password must be in the
+// X-Share-Password header if it has one, and its role must grant the
+// required permission. The link is put in the request context under
+// models.ShareLinkKey. Looking at an item through a link doesn't use it up;
+// only joining does.
+func AuthorizeShareLink(links ShareLinkChecker, requiredPermission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			link, err := links.GetShareLink(r.Context(), HashShareLinkToken(chi.URLParam(r, "token")))
+			if errors.Is(err, store.ErrNotFound) {
+				http.Error(w, "Share link not found or expired", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Printf("Error getting share link: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			if link.PasswordHash != "" {
+				password := r.Header.Get(ShareLinkPasswordHeader)
+				if password == "" {
+					http.Error(w, "This link needs a password", http.StatusUnauthorized)
+					return
+				}
+				if bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) != nil {
+					http.Error(w, "Incorrect password", http.StatusUnauthorized)
+					return
+				}
+			}
+
+			granted, err := links.RoleHasPermission(r.Context(), link.Role, requiredPermission)
+			if err != nil {
+				log.Printf("Error checking share link permission: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if !granted {
+				http.Error(w, "Forbidden - this link doesn't grant "+requiredPermission, http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), models.ShareLinkKey, link)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
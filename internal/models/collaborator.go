@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Collaborator is someone with a role on an item
+type Collaborator struct {
+	UserID   int    `json:"user_id"`
+	Email    string `json:"email"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	// SharedBy is who gave them the role; it is unset if that user is gone
+	SharedBy int       `json:"shared_by,omitempty"`
+	SharedAt time.Time `json:"shared_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Doc is the merged CRDT state of an item edited collaboratively
+type Doc struct {
+	ItemID   int             `json:"item_id"`
+	Snapshot json.RawMessage `json:"snapshot"`
+	// Content is the item's content, which a document without a snapshot
+	// starts from
+	Content json.RawMessage `json:"-"`
+	// LastUpdateID is the newest update folded into Snapshot
+	LastUpdateID int64     `json:"last_update_id"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// DocUpdate is one incremental change to a Doc as submitted by a client
+type DocUpdate struct {
+	UpdateID  int64           `json:"update_id"`
+	ItemID    int             `json:"item_id"`
+	UserID    int             `json:"user_id"`
+	Update    json.RawMessage `json:"update"`
+	CreatedAt time.Time       `json:"created_at"`
+}
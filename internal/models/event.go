@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Change event types streamed to collaborators
+const (
+	EventItemCreated = "item.created"
+	EventItemUpdated = "item.updated"
+	EventItemDeleted = "item.deleted"
+	EventTodoCreated = "todo.created"
+	EventTodoUpdated = "todo.updated"
+	EventTodoDeleted = "todo.deleted"
+	EventDocUpdated  = "doc.updated"
+
+	EventCollaboratorChanged = "collaborator.changed"
+	EventCollaboratorRemoved = "collaborator.removed"
+)
+
+// Event describes a change to an item or one of its todos
+type Event struct {
+	EventID   int64      `json:"event_id,omitempty"`
+	Type      string     `json:"type"`
+	ItemID    int        `json:"item_id"`
+	TodoID    int        `json:"todo_id,omitempty"`
+	UserID    int        `json:"user_id,omitempty"` // the collaborator a collaborator event is about
+	ActorID   int        `json:"actor_id"`
+	Item      *Item      `json:"item,omitempty"`
+	Todo      *Todo      `json:"todo,omitempty"`
+	DocUpdate *DocUpdate `json:"doc_update,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// Audience is the users who had a role on the item when the event happened
+	Audience []int `json:"-"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Identity is an account at an identity provider that a user signs in with.
+// A user can have several, one per provider account.
+type Identity struct {
+	IdentityID    int        `json:"identity_id"`
+	UserID        int        `json:"user_id"`
+	Provider      string     `json:"provider"`
+	Subject       string     `json:"subject"`
+	Email         string     `json:"email,omitempty"`
+	EmailVerified bool       `json:"email_verified"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastSignInAt  *time.Time `json:"last_sign_in_at,omitempty"`
+}
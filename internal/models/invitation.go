@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Invitation shares an item with an email address that has no account yet.
+// It turns into a role on the item when someone signs in with that address
+// verified, or follows the invitation's link.
+type Invitation struct {
+	InvitationID int       `json:"invitation_id"`
+	ItemID       int       `json:"item_id"`
+	Email        string    `json:"email"`
+	Role         string    `json:"role"`
+	InvitedBy    int       `json:"invited_by"`
+	TokenHash    string    `json:"-"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -1,7 +1,6 @@
 package models
 
 import (
-	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -10,27 +9,25 @@ import (
 type Item struct {
 	ItemID    int             `json:"item_id"`
 	Name      string          `json:"name"`
+	ItemType  string          `json:"item_type"`
 	Content   json.RawMessage `json:"content"`
+	Version   int             `json:"version"`
 	ETag      string          `json:"etag,omitempty"`
 	CreatedAt time.Time       `json:"created_at"`
 	UpdatedAt time.Time       `json:"updated_at"`
 }
 
-// GenerateETag creates a hash of the item's content and metadata
-func (i *Item) GenerateETag() string {
-	// Combine relevant fields into a string
-	content := fmt.Sprintf("%d-%s-%s-%s",
-		i.ItemID,
-		i.Name,
-		string(i.Content),
-		i.UpdatedAt.Format(time.RFC3339Nano),
-	)
+// ItemWithAccess is an item as seen by a particular user
+type ItemWithAccess struct {
+	Item
+	Role     string `json:"role"`      // owner, editor, or viewer
+	SharedBy string `json:"shared_by"` // email of user who shared it (null if owner)
+}
 
-	// Generate SHA-256 hash
-	hash := sha256.Sum256([]byte(content))
-	
-	// Return as hex string with quotes as per HTTP ETag spec
-	return fmt.Sprintf(`"%x"`, hash[:])
+// GenerateETag derives the entity tag from the item's version, which every
+// mutation increments
+func (i *Item) GenerateETag() string {
+	return fmt.Sprintf(`"item-%d-v%d"`, i.ItemID, i.Version)
 }
 
 // ValidateETag checks if the provided ETag matches the item's current state
@@ -40,3 +37,16 @@ func (i *Item) ValidateETag(etag string) bool {
 	}
 	return i.GenerateETag() == etag
 }
+
+// ParseItemETag extracts the version from an ETag produced by GenerateETag
+// for the given item
+func ParseItemETag(itemID int, etag string) (version int, ok bool) {
+	var id int
+	if _, err := fmt.Sscanf(etag, `"item-%d-v%d"`, &id, &version); err != nil {
+		return 0, false
+	}
+	if id != itemID || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Built-in item types
+const (
+	// ItemTypeNote holds free text as {"text": "..."}, which can be edited
+	// collaboratively through the item's document
+	ItemTypeNote = "note"
+	// ItemTypeTodoList keeps its entries under /todos, so its content stays
+	// an empty array
+	ItemTypeTodoList = "todo-list"
+	// ItemTypeLegacy is for items from before there were types that had
+	// content of their own; it accepts any JSON
+	ItemTypeLegacy = "legacy"
+)
+
+// ItemType names a kind of item and the JSON Schema its content must satisfy
+type ItemType struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Schema      json.RawMessage `json:"schema"`
+	BuiltIn     bool            `json:"built_in"`
+	CreatedBy   int             `json:"created_by,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// DefaultContent is the content an item of the given type starts with when
+// none is supplied
+func DefaultContent(itemType string) json.RawMessage {
+	switch itemType {
+	case ItemTypeNote:
+		return json.RawMessage(`{"text":""}`)
+	case ItemTypeTodoList:
+		return json.RawMessage("[]")
+	default:
+		return json.RawMessage("{}")
+	}
+}
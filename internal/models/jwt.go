@@ -1,12 +1,24 @@
 package models
 
-import "github.com/dgrijalva/jwt-go"
+import "github.com/golang-jwt/jwt/v5"
 
 type JWTClaims struct {
 	UserID int `json:"user_id"`
-	jwt.StandardClaims
+	// SessionID names the session the token was issued for
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
 }
 
 type contextKey string
 
-const UserIDKey contextKey = "user_id"
\ No newline at end of file
+const UserIDKey contextKey = "user_id"
+
+const SessionIDKey contextKey = "session_id"
+
+// ScopesKey holds the scopes of the personal access token a request was
+// made with. Requests made with a session's access token have none and
+// aren't limited.
+const ScopesKey contextKey = "scopes"
+
+// ShareLinkKey holds the share link a request to /api/shared was made with
+const ShareLinkKey contextKey = "share_link"
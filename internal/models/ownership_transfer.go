@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// OwnershipTransfer is an owner's offer to hand an item to someone else.
+// Accepting it makes them an owner and the previous owner an editor.
+type OwnershipTransfer struct {
+	ItemID     int       `json:"item_id"`
+	FromUserID int       `json:"from_user_id"`
+	ToUserID   int       `json:"to_user_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -3,4 +3,5 @@ package models
 type Permission struct {
 	PermissionID int    `json:"permission_id"`
 	Name         string `json:"name"`
-} 
\ No newline at end of file
+	Description  string `json:"description"`
+}
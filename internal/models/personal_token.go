@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Scopes a personal access token can be limited to. They cap what the
+// token can do whatever the user's role on an item.
+const (
+	ScopeItemsRead  = "items:read"
+	ScopeItemsWrite = "items:write"
+	ScopeUsersRead  = "users:read"
+)
+
+// Scopes lists every scope a personal access token can have
+var Scopes = []string{ScopeItemsRead, ScopeItemsWrite, ScopeUsersRead}
+
+// PersonalToken is the stored half of a personal access token, for scripts
+// and the CLI. Like refresh tokens, only the SHA-256 of the token is kept.
+type PersonalToken struct {
+	TokenID    int        `json:"token_id"`
+	TokenHash  string     `json:"-"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
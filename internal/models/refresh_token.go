@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// RefreshToken is the stored half of a refresh token. Only the SHA-256 of
+// the token handed to the client is kept. Every refresh replaces the token
+// with a new one in the same family, so a token that comes back after it
+// has been used means it was copied.
+type RefreshToken struct {
+	TokenID   int64      `json:"token_id"`
+	TokenHash string     `json:"-"`
+	UserID    int        `json:"user_id"`
+	FamilyID  string     `json:"family_id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
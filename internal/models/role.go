@@ -1,6 +1,14 @@
 package models
 
+import "time"
+
 type Role struct {
-	RoleID int    `json:"role_id"`
-	Name   string `json:"name"` 
-} 
\ No newline at end of file
+	RoleID      int    `json:"role_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// BuiltIn roles are the seeded owner, editor and viewer, which can't be
+	// changed
+	BuiltIn     bool      `json:"built_in"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -3,4 +3,4 @@ package models
 type RolePermission struct {
 	PermissionID int `json:"permission_id"`
 	RoleID       int `json:"role_id"`
-} 
\ No newline at end of file
+}
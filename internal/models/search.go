@@ -0,0 +1,11 @@
+package models
+
+// SearchResult is a single ranked hit from GET /api/search
+type SearchResult struct {
+	Type    string  `json:"type"` // item or todo
+	ItemID  int     `json:"item_id"`
+	TodoID  int     `json:"todo_id,omitempty"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"` // HTML: escaped text with matched terms in <mark></mark>
+	Rank    float64 `json:"rank"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Session is one sign-in. Access tokens name it in their sid claim and its
+// refresh tokens share its ID as their family.
+type Session struct {
+	SessionID string     `json:"session_id"`
+	UserID    int        `json:"user_id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
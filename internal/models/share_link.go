@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// ShareLink gives whoever has its URL a role on an item, without being
+// added as a collaborator. It can expire, need a password or only work a
+// number of times.
+type ShareLink struct {
+	LinkID       int    `json:"link_id"`
+	ItemID       int    `json:"item_id"`
+	TokenHash    string `json:"-"`
+	Role         string `json:"role"`
+	PasswordHash string `json:"-"`
+	// PasswordProtected is whether opening the link needs a password
+	PasswordProtected bool       `json:"password_protected"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	MaxUses           *int       `json:"max_uses,omitempty"`
+	UseCount          int        `json:"use_count"`
+	CreatedBy         int        `json:"created_by"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
@@ -1,12 +1,30 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type Todo struct {
-	TodoID    	int       	`json:"todo_id"`
-	ItemID 		int    		`json:"item_id"`
-	Title 		string 		`json:"title"`
-	Done  		bool   		`json:"done"`
-	CreatedAt 	time.Time 	`json:"created_at"`
-	UpdatedAt 	time.Time 	`json:"updated_at"`
+	TodoID    int       `json:"todo_id"`
+	ItemID    int       `json:"item_id"`
+	Title     string    `json:"title"`
+	Done      bool      `json:"done"`
+	Version   int       `json:"version"`
+	ETag      string    `json:"etag,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// GenerateETag returns the entity tag for this version of the todo
+func (t *Todo) GenerateETag() string {
+	return fmt.Sprintf(`"todo-%d-v%d"`, t.TodoID, t.Version)
+}
+
+// ValidateETag checks if the provided ETag matches the todo's current version
+func (t *Todo) ValidateETag(etag string) bool {
+	if etag == "" {
+		return false
+	}
+	return etag == "*" || t.GenerateETag() == etag
 }
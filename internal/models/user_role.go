@@ -8,4 +8,4 @@ type UserRole struct {
 	UserID    int       `json:"user_id"`
 	CreatedAt time.Time `json:"created_at"`
 	CreatedBy int       `json:"created_by"`
-} 
\ No newline at end of file
+}
@@ -0,0 +1,115 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+const githubAPI = "https://api.github.com"
+
+// githubProvider signs users in with GitHub. GitHub doesn't do OpenID
+// Connect for users, so who signed in comes from its API.
+type githubProvider struct {
+	credentials
+	api string
+}
+
+func newGitHub(config Config) *githubProvider {
+	p := &githubProvider{api: githubAPI}
+	p.config = oauth2.Config{
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret,
+		RedirectURL:  config.RedirectURL,
+		Scopes:       config.Scopes,
+		Endpoint:     github.Endpoint,
+	}
+	if len(p.config.Scopes) == 0 {
+		p.config.Scopes = []string{"read:user", "user:email"}
+	}
+	return p
+}
+
+func (p *githubProvider) Name() string {
+	return "github"
+}
+
+func (p *githubProvider) AuthCodeURL(flow Flow) (string, error) {
+	return p.oauth2Config().AuthCodeURL(flow.State, flow.authCodeOptions()...), nil
+}
+
+func (p *githubProvider) Exchange(ctx context.Context, code string, flow Flow) (Identity, error) {
+	config := p.oauth2Config()
+	token, err := config.Exchange(ctx, code, flow.exchangeOptions()...)
+	if err != nil {
+		return Identity{}, fmt.Errorf("github: code exchange failed: %v", err)
+	}
+	client := config.Client(ctx, token)
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.get(client, "/user", &user); err != nil {
+		return Identity{}, err
+	}
+	if user.ID == 0 {
+		return Identity{}, fmt.Errorf("github: user has no ID")
+	}
+
+	identity := Identity{
+		Provider: "github",
+		Subject:  strconv.FormatInt(user.ID, 10),
+		Name:     user.Name,
+	}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+
+	// The profile's public email may be unverified or missing, so use the
+	// primary address instead
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.get(client, "/user/emails", &emails); err != nil {
+		return Identity{}, err
+	}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+		}
+	}
+
+	return identity, nil
+}
+
+func (p *githubProvider) get(client *http.Client, path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, p.api+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("github: %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("github: %s returned %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("github: decoding %s: %v", path, err)
+	}
+	return nil
+}
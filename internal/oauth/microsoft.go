@@ -0,0 +1,29 @@
+package oauth
+
+import "fmt"
+
+const microsoftLogin = "https://login.microsoftonline.com/"
+
+// newMicrosoft signs users in with Microsoft Entra ID. The common,
+// organizations and consumers tenants issue ID tokens from the user's own
+// tenant, so the issuer is checked against the token's tenant ID instead of
+// the discovery document. Microsoft doesn't say whether an email address is
+// verified, so it never is.
+func newMicrosoft(config Config) *oidcProvider {
+	if config.Tenant == "" {
+		config.Tenant = "common"
+	}
+	config.Issuer = microsoftLogin + config.Tenant + "/v2.0"
+
+	p := newOIDC(config)
+	switch config.Tenant {
+	case "common", "organizations", "consumers":
+		p.checkIssuer = func(issuer string, claims idTokenClaims) error {
+			if claims.TenantID == "" || issuer != microsoftLogin+claims.TenantID+"/v2.0" {
+				return fmt.Errorf("ID token issuer %q does not match its tenant", issuer)
+			}
+			return nil
+		}
+	}
+	return p
+}
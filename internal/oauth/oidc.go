@@ -0,0 +1,151 @@
+package oauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const discoveryTimeout = 10 * time.Second
+
+// idTokenClaims are the ID token claims an Identity is built from
+type idTokenClaims struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	// TenantID is only sent by Microsoft
+	TenantID string `json:"tid"`
+}
+
+// oidcProvider signs users in with OpenID Connect. Who signed in comes from
+// the verified ID token rather than a userinfo call.
+type oidcProvider struct {
+	credentials
+	name   string
+	issuer string
+	// checkIssuer replaces the usual exact issuer check, for multi-tenant
+	// providers whose issuer varies by tenant
+	checkIssuer func(issuer string, claims idTokenClaims) error
+
+	discoveryMu sync.Mutex
+	discovered  *oidc.Provider
+}
+
+func newOIDC(config Config) *oidcProvider {
+	p := &oidcProvider{name: config.Name, issuer: config.Issuer}
+	p.config = oauth2.Config{
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret,
+		RedirectURL:  config.RedirectURL,
+		Scopes:       config.Scopes,
+	}
+	if len(p.config.Scopes) == 0 {
+		p.config.Scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+	return p
+}
+
+func (p *oidcProvider) Name() string {
+	return p.name
+}
+
+// discover fetches the issuer's configuration once it is first needed, and
+// tries again next time if that fails
+func (p *oidcProvider) discover(ctx context.Context) (*oidc.Provider, error) {
+	p.discoveryMu.Lock()
+	defer p.discoveryMu.Unlock()
+
+	if p.discovered != nil {
+		return p.discovered, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+	if p.checkIssuer != nil {
+		// The discovery document names a placeholder issuer
+		ctx = oidc.InsecureIssuerURLContext(ctx, p.issuer)
+	}
+
+	discovered, err := oidc.NewProvider(ctx, p.issuer)
+	if err != nil {
+		return nil, fmt.Errorf("%s: discovery failed: %v", p.name, err)
+	}
+	p.discovered = discovered
+	return discovered, nil
+}
+
+func (p *oidcProvider) oauth2Config(ctx context.Context) (*oauth2.Config, *oidc.Provider, error) {
+	discovered, err := p.discover(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	config := p.credentials.oauth2Config()
+	config.Endpoint = discovered.Endpoint()
+	return config, discovered, nil
+}
+
+func (p *oidcProvider) AuthCodeURL(flow Flow) (string, error) {
+	config, _, err := p.oauth2Config(context.Background())
+	if err != nil {
+		return "", err
+	}
+	opts := append(flow.authCodeOptions(), oidc.Nonce(flow.Nonce))
+	return config.AuthCodeURL(flow.State, opts...), nil
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code string, flow Flow) (Identity, error) {
+	config, discovered, err := p.oauth2Config(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	token, err := config.Exchange(ctx, code, flow.exchangeOptions()...)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: code exchange failed: %v", p.name, err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Identity{}, fmt.Errorf("%s: no ID token in the token response", p.name)
+	}
+
+	verifier := discovered.Verifier(&oidc.Config{
+		ClientID:        config.ClientID,
+		SkipIssuerCheck: p.checkIssuer != nil,
+	})
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: invalid ID token: %v", p.name, err)
+	}
+	// An ID token from someone else's sign-in won't carry this one's nonce
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(flow.Nonce)) != 1 {
+		return Identity{}, errors.New(p.name + ": ID token nonce doesn't match")
+	}
+
+	var claims idTokenClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return Identity{}, fmt.Errorf("%s: invalid ID token claims: %v", p.name, err)
+	}
+	if p.checkIssuer != nil {
+		if err := p.checkIssuer(idToken.Issuer, claims); err != nil {
+			return Identity{}, fmt.Errorf("%s: %v", p.name, err)
+		}
+	}
+	if claims.Subject == "" {
+		return Identity{}, errors.New(p.name + ": ID token has no subject")
+	}
+
+	return Identity{
+		Provider:      p.name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
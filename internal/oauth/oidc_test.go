@@ -0,0 +1,211 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID    = "todo-api"
+	testKeyID       = "test-key"
+	testRedirectURL = "https://todo.example.com/api/auth/test/callback"
+)
+
+// fakeOIDC is an OpenID Connect provider with discovery, JWKS and token
+// endpoints. The token endpoint returns an ID token with whatever claims the
+// test set.
+type fakeOIDC struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	// issuer is what discovery names as the issuer, the server URL unless a
+	// test changes it
+	issuer string
+	claims jwt.MapClaims
+}
+
+func newFakeOIDC(t *testing.T) *fakeOIDC {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeOIDC{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                f.issuer,
+			"authorization_endpoint":                f.URL + "/authorize",
+			"token_endpoint":                        f.URL + "/token",
+			"jwks_uri":                              f.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": testKeyID,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("code") != "code" || r.PostFormValue("code_verifier") == "" {
+			http.Error(w, `{"error": "invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, f.claims)
+		token.Header["kid"] = testKeyID
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			t.Errorf("signing ID token: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	f.issuer = f.URL
+	return f
+}
+
+// testFlow is a sign-in whose ID token should carry nonce "nonce"
+func testFlow() Flow {
+	return Flow{Provider: "test", State: "state", Nonce: "nonce", Verifier: "verifier", ExpiresAt: time.Now().Add(StateTTL)}
+}
+
+// validClaims are ID token claims the provider at issuer should accept
+func validClaims(issuer string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            issuer,
+		"sub":            "user-1",
+		"aud":            testClientID,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          "nonce",
+		"email":          "ada@example.com",
+		"email_verified": true,
+		"name":           "Ada",
+	}
+}
+
+func TestOIDCExchange(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(claims jwt.MapClaims)
+		wantErr string
+	}{
+		{name: "valid ID token", change: func(jwt.MapClaims) {}},
+		{name: "nonce mismatch", change: func(c jwt.MapClaims) { c["nonce"] = "someone else's" }, wantErr: "nonce"},
+		{name: "wrong audience", change: func(c jwt.MapClaims) { c["aud"] = "another-app" }, wantErr: "audience"},
+		{name: "expired", change: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, wantErr: "expired"},
+		{name: "wrong issuer", change: func(c jwt.MapClaims) { c["iss"] = "https://issuer.example.com" }, wantErr: "issuer"},
+		{name: "no subject", change: func(c jwt.MapClaims) { delete(c, "sub") }, wantErr: "subject"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeOIDC(t)
+			f.claims = validClaims(f.URL)
+			tt.change(f.claims)
+			p, err := NewProvider(Config{Name: "test", ClientID: testClientID, ClientSecret: "secret", RedirectURL: testRedirectURL, Issuer: f.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			identity, err := p.Exchange(context.Background(), "code", testFlow())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one about %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Identity{Provider: "test", Subject: "user-1", Email: "ada@example.com", EmailVerified: true, Name: "Ada"}
+			if identity != want {
+				t.Errorf("got %+v, want %+v", identity, want)
+			}
+		})
+	}
+}
+
+func TestOIDCAuthCodeURL(t *testing.T) {
+	f := newFakeOIDC(t)
+	p, err := NewProvider(Config{Name: "test", ClientID: testClientID, RedirectURL: testRedirectURL, Issuer: f.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authURL, err := p.AuthCodeURL(testFlow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{f.URL + "/authorize?", "state=state", "nonce=nonce", "code_challenge_method=S256"} {
+		if !strings.Contains(authURL, want) {
+			t.Errorf("%s doesn't contain %s", authURL, want)
+		}
+	}
+}
+
+func TestMicrosoftTenantIssuer(t *testing.T) {
+	const tenant = "9188040d-6c67-4c5b-b112-36a304b66dad"
+	tests := []struct {
+		name    string
+		change  func(claims jwt.MapClaims)
+		wantErr bool
+	}{
+		{name: "issuer of the token's tenant", change: func(jwt.MapClaims) {}},
+		{name: "issuer of another tenant", change: func(c jwt.MapClaims) { c["iss"] = microsoftLogin + "another-tenant/v2.0" }, wantErr: true},
+		{name: "no tenant ID", change: func(c jwt.MapClaims) { delete(c, "tid") }, wantErr: true},
+		{name: "not a Microsoft issuer", change: func(c jwt.MapClaims) { c["iss"] = "https://issuer.example.com/" + tenant + "/v2.0" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeOIDC(t)
+			// Like Microsoft's, the discovery document names a placeholder
+			f.issuer = microsoftLogin + "{tenantid}/v2.0"
+			f.claims = validClaims(microsoftLogin + tenant + "/v2.0")
+			f.claims["tid"] = tenant
+			tt.change(f.claims)
+
+			p := newMicrosoft(Config{Name: "microsoft", ClientID: testClientID, ClientSecret: "secret", RedirectURL: testRedirectURL})
+			p.issuer = f.URL
+
+			identity, err := p.Exchange(context.Background(), "code", testFlow())
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "does not match its tenant") {
+					t.Fatalf("got error %v, want a tenant mismatch", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if identity.Subject != "user-1" {
+				t.Errorf("got subject %q", identity.Subject)
+			}
+		})
+	}
+}
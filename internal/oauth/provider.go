@@ -0,0 +1,164 @@
+// Package oauth signs users in with external identity providers: Google,
+// Microsoft and any other OpenID Connect issuer, and GitHub.
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+var ErrUnknownProvider = errors.New("unknown identity provider")
+
+// Identity is the account a provider says signed in
+type Identity struct {
+	Provider string
+	// Subject is the provider's ID for the account; it never changes
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider runs the authorization code flow with one identity provider,
+// protected by PKCE and, for OpenID Connect, a nonce
+type Provider interface {
+	Name() string
+	// AuthCodeURL is where the user signs in to start the flow
+	AuthCodeURL(flow Flow) (string, error)
+	// Exchange redeems the authorization code the flow ended with and
+	// returns who signed in
+	Exchange(ctx context.Context, code string, flow Flow) (Identity, error)
+	// SetCredentials swaps the OAuth client credentials, e.g. after the
+	// client secret was rotated
+	SetCredentials(clientID, clientSecret string)
+}
+
+// Config describes one provider. Google, Microsoft and GitHub only need
+// their client credentials; any other name is an OpenID Connect provider
+// found by discovery from Issuer.
+type Config struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Issuer is the OpenID Connect issuer URL, for providers not built in
+	Issuer string
+	// Tenant is the Microsoft Entra tenant: an ID, or common, organizations
+	// or consumers
+	Tenant string
+	Scopes []string
+}
+
+// EnvPrefix is the prefix of a provider's settings and secrets, e.g.
+// MICROSOFT_ for microsoft
+func EnvPrefix(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+}
+
+// ConfigFromEnv reads the <NAME>_* settings of the named provider. Client
+// credentials are secrets and are set separately. The redirect URL defaults
+// to the provider's callback route under PUBLIC_URL.
+func ConfigFromEnv(name string) Config {
+	prefix := EnvPrefix(name)
+	publicURL := strings.TrimRight(os.Getenv("PUBLIC_URL"), "/")
+	config := Config{
+		Name:        name,
+		RedirectURL: os.Getenv(prefix + "REDIRECT_URL"),
+		Issuer:      os.Getenv(prefix + "ISSUER"),
+		Tenant:      os.Getenv(prefix + "TENANT"),
+	}
+	if config.RedirectURL == "" && publicURL != "" {
+		config.RedirectURL = publicURL + "/api/auth/" + name + "/callback"
+	}
+	if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+		config.Scopes = strings.Fields(scopes)
+	}
+	return config
+}
+
+// NewProvider builds the provider a config describes. OpenID Connect
+// discovery happens on first use, so a provider that is down doesn't stop
+// the server from starting.
+func NewProvider(config Config) (Provider, error) {
+	// The provider sends the user back to the callback route with a full
+	// page redirect, so it needs the route's absolute URL
+	if config.RedirectURL == "" {
+		return nil, fmt.Errorf("%s: PUBLIC_URL or %sREDIRECT_URL is required", config.Name, EnvPrefix(config.Name))
+	}
+	switch config.Name {
+	case "github":
+		return newGitHub(config), nil
+	case "google":
+		config.Issuer = "https://accounts.google.com"
+		return newOIDC(config), nil
+	case "microsoft":
+		return newMicrosoft(config), nil
+	default:
+		if config.Issuer == "" {
+			return nil, fmt.Errorf("%s: an issuer URL is required", config.Name)
+		}
+		return newOIDC(config), nil
+	}
+}
+
+// credentials holds a provider's OAuth client, which can change at any time
+type credentials struct {
+	mu     sync.RWMutex
+	config oauth2.Config
+}
+
+func (c *credentials) SetCredentials(clientID, clientSecret string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.ClientID = clientID
+	c.config.ClientSecret = clientSecret
+}
+
+// oauth2Config returns a copy of the client config that is safe to use
+// while the credentials are rotated
+func (c *credentials) oauth2Config() *oauth2.Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	config := c.config
+	config.Scopes = append([]string(nil), c.config.Scopes...)
+	return &config
+}
+
+// Registry holds the configured providers by name
+type Registry struct {
+	providers map[string]Provider
+}
+
+func NewRegistry(providers ...Provider) *Registry {
+	r := &Registry{providers: make(map[string]Provider)}
+	for _, p := range providers {
+		r.providers[p.Name()] = p
+	}
+	return r
+}
+
+func (r *Registry) Get(name string) (Provider, error) {
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownProvider, name)
+	}
+	return p, nil
+}
+
+// Names lists the configured providers alphabetically
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
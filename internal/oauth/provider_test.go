@@ -0,0 +1,28 @@
+package oauth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigFromEnvRedirectURL(t *testing.T) {
+	t.Setenv("PUBLIC_URL", "https://todo.example.com/")
+	if got := ConfigFromEnv("google").RedirectURL; got != "https://todo.example.com/api/auth/google/callback" {
+		t.Errorf("under PUBLIC_URL: got %q", got)
+	}
+
+	t.Setenv("GOOGLE_REDIRECT_URL", "https://login.example.com/google")
+	if got := ConfigFromEnv("google").RedirectURL; got != "https://login.example.com/google" {
+		t.Errorf("with GOOGLE_REDIRECT_URL: got %q", got)
+	}
+}
+
+func TestNewProviderNeedsRedirectURL(t *testing.T) {
+	t.Setenv("PUBLIC_URL", "")
+	t.Setenv("GOOGLE_REDIRECT_URL", "")
+
+	_, err := NewProvider(ConfigFromEnv("google"))
+	if err == nil || !strings.Contains(err.Error(), "GOOGLE_REDIRECT_URL") {
+		t.Errorf("got %v, want an error asking for a redirect URL", err)
+	}
+}
@@ -0,0 +1,190 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/onyeepeace/todo-api/internal/jwtkeys"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// StateTTL is how long a user has to sign in with the provider
+	StateTTL = 10 * time.Minute
+	// StateCookie carries the sign-in attempt between the login and the
+	// callback
+	StateCookie = "oauth_state"
+
+	// stateAudience keeps state cookies and access tokens, which are signed
+	// with the same keys, from being mistaken for each other
+	stateAudience = "oauth-state"
+)
+
+var ErrInvalidState = errors.New("invalid or expired sign-in state")
+
+// Flow is one sign-in attempt. Its values go to the provider when the
+// sign-in starts and are checked when the provider sends the user back.
+type Flow struct {
+	Provider string
+	// State ties the callback to the browser that started the sign-in
+	State string
+	// Nonce ties the ID token to this sign-in
+	Nonce string
+	// Verifier is the PKCE code verifier; the provider only sees its S256
+	// challenge until the code is exchanged
+	Verifier string
+	// UseCookies asks for the tokens as HttpOnly cookies when the sign-in
+	// finishes. The provider's redirect back carries none of the login's
+	// query, so it has to travel with the state.
+	UseCookies bool
+	ExpiresAt  time.Time
+}
+
+// authCodeOptions are the options AuthCodeURL sends for the flow
+func (f Flow) authCodeOptions() []oauth2.AuthCodeOption {
+	return []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(f.Verifier)}
+}
+
+// exchangeOptions are the options Exchange sends for the flow
+func (f Flow) exchangeOptions() []oauth2.AuthCodeOption {
+	return []oauth2.AuthCodeOption{oauth2.VerifierOption(f.Verifier)}
+}
+
+type stateClaims struct {
+	Nonce      string `json:"nonce"`
+	Verifier   string `json:"cv"`
+	UseCookies bool   `json:"cookies,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// StateLog remembers the states that were used, so a callback can't be
+// replayed
+type StateLog interface {
+	// UseOAuthState records a state as used until it expires, and reports
+	// false if it already was
+	UseOAuthState(ctx context.Context, state string, expiresAt time.Time) (bool, error)
+}
+
+// States keeps sign-in attempts in a signed, HttpOnly cookie on the browser
+// that started them. The cookie is signed with the access token keys, so
+// any replica can finish a sign-in another one started.
+type States struct {
+	Keys *jwtkeys.KeyRing
+	Used StateLog
+	// InsecureCookies lets the cookie travel over plain HTTP, for local
+	// development
+	InsecureCookies bool
+}
+
+// Begin starts a sign-in with the named provider and sets its cookie.
+// useCookies is remembered for the callback.
+func (s *States) Begin(w http.ResponseWriter, provider string, useCookies bool) (Flow, error) {
+	state, err := randomString(32)
+	if err != nil {
+		return Flow{}, err
+	}
+	nonce, err := randomString(32)
+	if err != nil {
+		return Flow{}, err
+	}
+	flow := Flow{
+		Provider:   provider,
+		State:      state,
+		Nonce:      nonce,
+		Verifier:   oauth2.GenerateVerifier(),
+		UseCookies: useCookies,
+		ExpiresAt:  time.Now().Add(StateTTL),
+	}
+
+	cookie, err := s.Keys.Sign(&stateClaims{
+		Nonce:      flow.Nonce,
+		Verifier:   flow.Verifier,
+		UseCookies: flow.UseCookies,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        flow.State,
+			Subject:   flow.Provider,
+			Audience:  jwt.ClaimStrings{stateAudience},
+			ExpiresAt: jwt.NewNumericDate(flow.ExpiresAt),
+		},
+	})
+	if err != nil {
+		return Flow{}, err
+	}
+
+	http.SetCookie(w, s.cookie(cookie, StateTTL))
+	return flow, nil
+}
+
+// Finish checks the state the provider sent back against the cookie and
+// returns the sign-in it belongs to. The cookie is cleared, and each state
+// works once: anything else gives ErrInvalidState.
+func (s *States) Finish(w http.ResponseWriter, r *http.Request, provider, state string) (Flow, error) {
+	http.SetCookie(w, s.cookie("", -time.Second))
+
+	cookie, err := r.Cookie(StateCookie)
+	if err != nil || state == "" {
+		return Flow{}, ErrInvalidState
+	}
+
+	claims := &stateClaims{}
+	_, err = jwt.ParseWithClaims(cookie.Value, claims, s.Keys.Keyfunc,
+		jwt.WithValidMethods([]string{jwtkeys.RS256, jwtkeys.EdDSA}),
+		jwt.WithAudience(stateAudience),
+		jwt.WithSubject(provider),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return Flow{}, fmt.Errorf("%w: %v", ErrInvalidState, err)
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.ID), []byte(state)) != 1 {
+		return Flow{}, fmt.Errorf("%w: state doesn't match the cookie", ErrInvalidState)
+	}
+
+	flow := Flow{
+		Provider:   provider,
+		State:      claims.ID,
+		Nonce:      claims.Nonce,
+		Verifier:   claims.Verifier,
+		UseCookies: claims.UseCookies,
+		ExpiresAt:  claims.ExpiresAt.Time,
+	}
+	firstUse, err := s.Used.UseOAuthState(r.Context(), flow.State, flow.ExpiresAt)
+	if err != nil {
+		return Flow{}, err
+	}
+	if !firstUse {
+		return Flow{}, fmt.Errorf("%w: state was already used", ErrInvalidState)
+	}
+	return flow, nil
+}
+
+// cookie is sent on the provider's redirect back to the callback, which is
+// a cross-site navigation, so it can't be SameSite=Strict. It covers /api so
+// linking an identity from the profile can use it too.
+func (s *States) cookie(value string, maxAge time.Duration) *http.Cookie {
+	return &http.Cookie{
+		Name:     StateCookie,
+		Value:    value,
+		Path:     "/api",
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   !s.InsecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// randomString returns n random bytes, base64url encoded
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
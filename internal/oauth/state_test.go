@@ -0,0 +1,77 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/jwtkeys"
+)
+
+// usedStates is a StateLog kept in memory
+type usedStates map[string]bool
+
+func (u usedStates) UseOAuthState(ctx context.Context, state string, expiresAt time.Time) (bool, error) {
+	if u[state] {
+		return false, nil
+	}
+	u[state] = true
+	return true, nil
+}
+
+func newTestStates(t *testing.T) *States {
+	t.Helper()
+	keys, err := jwtkeys.Open(jwtkeys.Config{Dir: t.TempDir(), Algorithm: jwtkeys.EdDSA, RotateEvery: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &States{Keys: keys, Used: usedStates{}}
+}
+
+func TestStatesRememberCookieMode(t *testing.T) {
+	for _, useCookies := range []bool{false, true} {
+		states := newTestStates(t)
+
+		w := httptest.NewRecorder()
+		begun, err := states.Begin(w, "google", useCookies)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The provider sends the user back with only its own parameters
+		r := httptest.NewRequest("GET", "/api/auth/google/callback?code=code&state="+begun.State, nil)
+		for _, cookie := range w.Result().Cookies() {
+			r.AddCookie(cookie)
+		}
+		flow, err := states.Finish(httptest.NewRecorder(), r, "google", begun.State)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if flow.UseCookies != useCookies || flow.Nonce != begun.Nonce || flow.Verifier != begun.Verifier {
+			t.Errorf("finished %+v, began %+v", flow, begun)
+		}
+
+		if _, err := states.Finish(httptest.NewRecorder(), r, "google", begun.State); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("replayed state: got %v, want ErrInvalidState", err)
+		}
+	}
+}
+
+func TestStatesRejectAnotherProvider(t *testing.T) {
+	states := newTestStates(t)
+
+	w := httptest.NewRecorder()
+	begun, err := states.Begin(w, "google", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("GET", "/api/auth/github/callback", nil)
+	for _, cookie := range w.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+	if _, err := states.Finish(httptest.NewRecorder(), r, "github", begun.State); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("got %v, want ErrInvalidState", err)
+	}
+}
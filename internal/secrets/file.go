@@ -0,0 +1,39 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// File reads each secret from a file of the same name in Dir, the way Docker
+// and Kubernetes mount them. Kubernetes updates mounted secrets in place, so
+// rereading the file picks up a rotated value.
+type File struct {
+	Dir string
+}
+
+func (f File) Get(ctx context.Context, name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name[0] == '.' {
+		return "", fmt.Errorf("invalid secret name %q", name)
+	}
+
+	data, err := os.ReadFile(filepath.Join(f.Dir, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return "", err
+	}
+
+	// Files written by hand usually end in a newline that isn't part of the
+	// secret
+	value := strings.TrimRight(string(data), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("%w: %s is empty", ErrNotFound, name)
+	}
+	return value, nil
+}
@@ -0,0 +1,67 @@
+// Package secrets reads credentials and keys from the environment, from
+// mounted files or from Vault, and keeps them up to date so they can be
+// rotated without a restart.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+var ErrNotFound = errors.New("secret not found")
+
+// Provider looks secrets up by name. An empty secret counts as missing, so
+// nothing ever runs with an empty password or key.
+type Provider interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// FromEnv picks the provider named by SECRETS_PROVIDER: env (the default),
+// file or vault
+func FromEnv() (Provider, error) {
+	switch provider := os.Getenv("SECRETS_PROVIDER"); provider {
+	case "", "env":
+		return Env{}, nil
+	case "file":
+		dir := os.Getenv("SECRETS_DIR")
+		if dir == "" {
+			dir = "/run/secrets"
+		}
+		return File{Dir: dir}, nil
+	case "vault":
+		vault := &Vault{
+			Address: os.Getenv("VAULT_ADDR"),
+			Token:   os.Getenv("VAULT_TOKEN"),
+			Mount:   os.Getenv("VAULT_KV_MOUNT"),
+			Path:    os.Getenv("VAULT_SECRET_PATH"),
+			Client:  &http.Client{Timeout: 10 * time.Second},
+		}
+		if vault.Address == "" || vault.Token == "" {
+			return nil, errors.New("VAULT_ADDR and VAULT_TOKEN are required")
+		}
+		if vault.Mount == "" {
+			vault.Mount = "secret"
+		}
+		if vault.Path == "" {
+			vault.Path = "todo-api"
+		}
+		return vault, nil
+	default:
+		return nil, fmt.Errorf("unknown secrets provider %q", provider)
+	}
+}
+
+// Env reads secrets from environment variables of the same name
+type Env struct{}
+
+func (Env) Get(ctx context.Context, name string) (string, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return value, nil
+}
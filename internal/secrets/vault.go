@@ -0,0 +1,77 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Vault reads secrets from one entry of a Vault KV version 2 secrets engine;
+// each secret is a key of that entry. Anything that speaks the same HTTP API
+// works.
+type Vault struct {
+	Address string
+	Token   string
+	// Mount is where the KV engine is mounted, e.g. "secret"
+	Mount string
+	// Path is the entry within the engine, e.g. "todo-api"
+	Path   string
+	Client *http.Client
+}
+
+func (v *Vault) Get(ctx context.Context, name string) (string, error) {
+	data, err := v.read(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := data[name].(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return value, nil
+}
+
+// read fetches the latest version of the entry
+func (v *Vault) read(ctx context.Context) (map[string]interface{}, error) {
+	endpoint := strings.TrimRight(v.Address, "/") + "/v1/" + strings.Trim(v.Mount, "/") + "/data/" + strings.Trim(v.Path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error reading from vault: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return map[string]interface{}{}, nil
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("vault returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var secret struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("error decoding vault response: %v", err)
+	}
+	if secret.Data.Data == nil {
+		return map[string]interface{}{}, nil
+	}
+	return secret.Data.Data, nil
+}
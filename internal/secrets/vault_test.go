@@ -0,0 +1,99 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeVault serves one KV version 2 entry at /v1/secret/data/todo-api,
+// only to requests with the right token
+type fakeVault struct {
+	token string
+
+	mu   sync.Mutex
+	data map[string]interface{}
+}
+
+func (f *fakeVault) set(name string, value interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.data[name] = value
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != f.token {
+		http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet || r.URL.Path != "/v1/secret/data/todo-api" {
+		http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": map[string]interface{}{
+			"data":     f.data,
+			"metadata": map[string]interface{}{"version": 1},
+		},
+	})
+}
+
+func newFakeVault(t *testing.T) (*fakeVault, *Vault) {
+	t.Helper()
+	fake := &fakeVault{token: "s.test", data: map[string]interface{}{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, &Vault{Address: server.URL + "/", Token: "s.test", Mount: "secret", Path: "todo-api", Client: server.Client()}
+}
+
+func TestVaultReadsKVv2(t *testing.T) {
+	fake, vault := newFakeVault(t)
+	fake.set("DB_PASSWORD", "hunter2")
+
+	value, err := vault.Get(context.Background(), "DB_PASSWORD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "hunter2" {
+		t.Errorf("got %q, want %q", value, "hunter2")
+	}
+}
+
+func TestVaultMissingEntry(t *testing.T) {
+	_, vault := newFakeVault(t)
+	vault.Path = "other-app"
+
+	if _, err := vault.Get(context.Background(), "DB_PASSWORD"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestVaultEmptyOrMissingValue(t *testing.T) {
+	fake, vault := newFakeVault(t)
+	fake.set("DB_PASSWORD", "")
+	fake.set("DB_PORT", 5432)
+
+	for _, name := range []string{"DB_PASSWORD", "DB_PORT", "DB_USER"} {
+		if _, err := vault.Get(context.Background(), name); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: got %v, want ErrNotFound", name, err)
+		}
+	}
+}
+
+func TestVaultSendsToken(t *testing.T) {
+	fake, vault := newFakeVault(t)
+	fake.set("DB_PASSWORD", "hunter2")
+	vault.Token = "s.wrong"
+
+	_, err := vault.Get(context.Background(), "DB_PASSWORD")
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want the permission error", err)
+	}
+}
@@ -0,0 +1,107 @@
+package secrets
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Watcher rereads secrets periodically and hands changed values to whatever
+// uses them
+type Watcher struct {
+	provider Provider
+
+	mu      sync.Mutex
+	watches []*watch
+}
+
+type watch struct {
+	names    []string
+	values   map[string]string
+	onChange func(values map[string]string) error
+}
+
+func NewWatcher(provider Provider) *Watcher {
+	return &Watcher{provider: provider}
+}
+
+// Watch reads the named secrets and calls onChange with them, now and again
+// whenever any of them changes. A secret that is missing now is an error;
+// if one goes missing later, or onChange rejects the new values, the old
+// values stay in use and the change is retried on the next refresh.
+func (w *Watcher) Watch(ctx context.Context, onChange func(values map[string]string) error, names ...string) error {
+	values, err := w.read(ctx, names)
+	if err != nil {
+		return err
+	}
+	if err := onChange(values); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.watches = append(w.watches, &watch{names: names, values: values, onChange: onChange})
+	w.mu.Unlock()
+	return nil
+}
+
+// Run refreshes every watched secret every interval until stop is closed
+func (w *Watcher) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			w.Refresh(context.Background())
+		}
+	}
+}
+
+// Refresh rereads every watched secret once
+func (w *Watcher) Refresh(ctx context.Context) {
+	w.mu.Lock()
+	watches := append([]*watch(nil), w.watches...)
+	w.mu.Unlock()
+
+	for _, watch := range watches {
+		values, err := w.read(ctx, watch.names)
+		if err != nil {
+			log.Printf("Error refreshing secrets %s: %v", strings.Join(watch.names, ", "), err)
+			continue
+		}
+		if !changed(watch.values, values) {
+			continue
+		}
+		if err := watch.onChange(values); err != nil {
+			log.Printf("Error applying rotated secrets %s: %v", strings.Join(watch.names, ", "), err)
+			continue
+		}
+		log.Printf("Rotated secrets %s", strings.Join(watch.names, ", "))
+		watch.values = values
+	}
+}
+
+func (w *Watcher) read(ctx context.Context, names []string) (map[string]string, error) {
+	values := make(map[string]string, len(names))
+	for _, name := range names {
+		value, err := w.provider.Get(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+func changed(old, current map[string]string) bool {
+	for name, value := range current {
+		if old[name] != value {
+			return true
+		}
+	}
+	return false
+}
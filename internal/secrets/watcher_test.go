@@ -0,0 +1,82 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWatcherDeliversRotatedSecrets(t *testing.T) {
+	fake, vault := newFakeVault(t)
+	fake.set("DB_USER", "app")
+	fake.set("DB_PASSWORD", "first")
+
+	var got []map[string]string
+	watcher := NewWatcher(vault)
+	err := watcher.Watch(context.Background(), func(values map[string]string) error {
+		got = append(got, values)
+		return nil
+	}, "DB_USER", "DB_PASSWORD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing changed, so nobody is told
+	watcher.Refresh(context.Background())
+	if len(got) != 1 || got[0]["DB_PASSWORD"] != "first" {
+		t.Fatalf("after the first read: got %v", got)
+	}
+
+	fake.set("DB_PASSWORD", "second")
+	watcher.Refresh(context.Background())
+	if len(got) != 2 || got[1]["DB_PASSWORD"] != "second" || got[1]["DB_USER"] != "app" {
+		t.Fatalf("after rotating: got %v", got)
+	}
+}
+
+func TestWatcherKeepsOldValuesWhenRotationFails(t *testing.T) {
+	fake, vault := newFakeVault(t)
+	fake.set("API_KEY", "first")
+
+	var current string
+	reject := true
+	watcher := NewWatcher(vault)
+	err := watcher.Watch(context.Background(), func(values map[string]string) error {
+		if current != "" && reject {
+			return errors.New("rejected")
+		}
+		current = values["API_KEY"]
+		return nil
+	}, "API_KEY")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A secret going missing leaves the old value in use
+	fake.set("API_KEY", "")
+	watcher.Refresh(context.Background())
+	if current != "first" {
+		t.Fatalf("after the secret went missing: got %q", current)
+	}
+
+	// So does a subscriber rejecting the new value, until it accepts it
+	fake.set("API_KEY", "second")
+	watcher.Refresh(context.Background())
+	if current != "first" {
+		t.Fatalf("after the rotation was rejected: got %q", current)
+	}
+	reject = false
+	watcher.Refresh(context.Background())
+	if current != "second" {
+		t.Fatalf("after retrying: got %q", current)
+	}
+}
+
+func TestWatchFailsForMissingSecret(t *testing.T) {
+	_, vault := newFakeVault(t)
+
+	err := NewWatcher(vault).Watch(context.Background(), func(map[string]string) error { return nil }, "DB_PASSWORD")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+// Memory implements every store interface in process. It is intended for
+// tests and local development and loses everything on restart.
+type Memory struct {
+	mu sync.RWMutex
+
+	nextItemID          int
+	nextTodoID          int
+	nextUserID          int
+	nextIdentityID      int
+	nextPersonalTokenID int
+	nextInvitationID    int
+	nextShareLinkID     int
+	nextRoleID          int
+
+	nextDocUpdateID    int64
+	nextRefreshTokenID int64
+
+	items       map[int]models.Item
+	todos       map[int]models.Todo
+	users       map[int]models.User
+	identities  map[int]models.Identity
+	userRoles   map[int]map[int]memoryRole // item_id -> user_id -> role
+	docs        map[int]memoryDoc
+	transfers   map[int]models.OwnershipTransfer // by item_id
+	invitations map[int]models.Invitation
+	shareLinks  map[int]models.ShareLink
+	itemTypes   map[string]models.ItemType
+
+	sessions        map[string]models.Session
+	refreshTokens   map[string]models.RefreshToken // by token hash
+	usedOAuthStates map[string]time.Time           // state -> expiry
+	personalTokens  map[int]models.PersonalToken
+
+	admins map[int]bool
+
+	// roles, permissions, rolePermissions and the built-in itemTypes mirror
+	// the seed data
+	roles           map[string]models.Role // without their permissions
+	permissions     []models.Permission
+	rolePermissions map[string][]string
+}
+
+type memoryDoc struct {
+	doc              models.Doc
+	updates          []models.DocUpdate
+	compactedThrough int64
+}
+
+type memoryRole struct {
+	role      string
+	createdBy int
+	createdAt time.Time
+}
+
+// NewMemory returns an empty in-memory store seeded with the default roles
+func NewMemory() *Memory {
+	return &Memory{
+		items:           make(map[int]models.Item),
+		todos:           make(map[int]models.Todo),
+		users:           make(map[int]models.User),
+		identities:      make(map[int]models.Identity),
+		userRoles:       make(map[int]map[int]memoryRole),
+		docs:            make(map[int]memoryDoc),
+		transfers:       make(map[int]models.OwnershipTransfer),
+		invitations:     make(map[int]models.Invitation),
+		shareLinks:      make(map[int]models.ShareLink),
+		sessions:        make(map[string]models.Session),
+		refreshTokens:   make(map[string]models.RefreshToken),
+		usedOAuthStates: make(map[string]time.Time),
+		personalTokens:  make(map[int]models.PersonalToken),
+		itemTypes: map[string]models.ItemType{
+			models.ItemTypeNote: {
+				Name:        models.ItemTypeNote,
+				Description: "Free text that collaborators can edit together",
+				Schema:      json.RawMessage(`{"type": "object", "properties": {"text": {"type": "string"}}, "required": ["text"]}`),
+				BuiltIn:     true,
+			},
+			models.ItemTypeTodoList: {
+				Name:        models.ItemTypeTodoList,
+				Description: "A list of todos kept under /todos",
+				Schema:      json.RawMessage(`{"type": "array", "maxItems": 0}`),
+				BuiltIn:     true,
+			},
+			models.ItemTypeLegacy: {
+				Name:        models.ItemTypeLegacy,
+				Description: "An item from before there were types, whose content can be any JSON",
+				Schema:      json.RawMessage(`{}`),
+				BuiltIn:     true,
+			},
+		},
+		admins:     make(map[int]bool),
+		nextRoleID: 3,
+		roles: map[string]models.Role{
+			"owner":  {RoleID: 1, Name: "owner", Description: "Full control over the item and can manage other users' access", BuiltIn: true},
+			"editor": {RoleID: 2, Name: "editor", Description: "Can view and edit the item content", BuiltIn: true},
+			"viewer": {RoleID: 3, Name: "viewer", Description: "Can only view the item content", BuiltIn: true},
+		},
+		permissions: []models.Permission{
+			{PermissionID: 1, Name: "can_view", Description: "Can view the item content"},
+			{PermissionID: 2, Name: "can_edit", Description: "Can modify the item content"},
+			{PermissionID: 3, Name: "can_share", Description: "Can share the item with other users"},
+			{PermissionID: 4, Name: "can_delete", Description: "Can delete the item"},
+			{PermissionID: 5, Name: "can_complete", Description: "Can mark todos as done"},
+		},
+		rolePermissions: map[string][]string{
+			"owner":  {"can_view", "can_edit", "can_share", "can_delete", "can_complete"},
+			"editor": {"can_view", "can_edit", "can_complete"},
+			"viewer": {"can_view"},
+		},
+	}
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func (m *Memory) GetDoc(ctx context.Context, itemID int) (models.Doc, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	item, ok := m.items[itemID]
+	if !ok {
+		return models.Doc{}, ErrNotFound
+	}
+	doc := m.docs[itemID].doc
+	doc.ItemID = itemID
+	doc.Content = item.Content
+	return doc, nil
+}
+
+func (m *Memory) ApplyDocUpdate(ctx context.Context, update *models.DocUpdate, merge DocMerge, keepUpdates int) (models.Doc, models.Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.items[update.ItemID]
+	if !ok {
+		return models.Doc{}, item, ErrNotFound
+	}
+	state := m.docs[update.ItemID]
+
+	merged, content, err := merge(state.doc.Snapshot, item.Content, update.Update)
+	if err != nil {
+		return state.doc, item, err
+	}
+
+	now := time.Now()
+	m.nextDocUpdateID++
+	update.UpdateID = m.nextDocUpdateID
+	update.CreatedAt = now
+
+	state.doc = models.Doc{
+		ItemID:       update.ItemID,
+		Snapshot:     merged,
+		LastUpdateID: update.UpdateID,
+		UpdatedAt:    now,
+	}
+	state.updates = append(state.updates, *update)
+	if keepUpdates > 0 && len(state.updates) > keepUpdates {
+		dropped := len(state.updates) - keepUpdates
+		state.compactedThrough = state.updates[dropped-1].UpdateID
+		state.updates = append([]models.DocUpdate(nil), state.updates[dropped:]...)
+	}
+	m.docs[update.ItemID] = state
+
+	item.Content = content
+	item.Version++
+	item.UpdatedAt = now
+	m.items[update.ItemID] = item
+
+	return state.doc, item, nil
+}
+
+func (m *Memory) DocUpdatesSince(ctx context.Context, itemID int, afterID int64) ([]models.DocUpdate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.items[itemID]; !ok {
+		return nil, ErrNotFound
+	}
+	state := m.docs[itemID]
+	if afterID < state.compactedThrough {
+		return nil, ErrCompacted
+	}
+
+	updates := []models.DocUpdate{}
+	for _, update := range state.updates {
+		if update.UpdateID > afterID {
+			updates = append(updates, update)
+		}
+	}
+
+	return updates, nil
+}
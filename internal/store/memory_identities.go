@@ -0,0 +1,150 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func (m *Memory) GetIdentity(ctx context.Context, provider, subject string) (models.Identity, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, identity := range m.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return models.Identity{}, ErrNotFound
+}
+
+func (m *Memory) ListIdentities(ctx context.Context, userID int) ([]models.Identity, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var identities []models.Identity
+	for _, identity := range m.identities {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	sort.Slice(identities, func(i, j int) bool { return identities[i].IdentityID < identities[j].IdentityID })
+	return identities, nil
+}
+
+func (m *Memory) GetUserByVerifiedEmail(ctx context.Context, email string) (models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var found *models.Identity
+	for _, identity := range m.identities {
+		if !identity.EmailVerified || !strings.EqualFold(identity.Email, email) {
+			continue
+		}
+		if found == nil || identity.IdentityID < found.IdentityID {
+			identity := identity
+			found = &identity
+		}
+	}
+	if found == nil {
+		return models.User{}, ErrNotFound
+	}
+	return m.users[found.UserID], nil
+}
+
+func (m *Memory) CreateUserWithIdentity(ctx context.Context, user *models.User, identity *models.Identity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Mirror the unique constraints on the users table
+	for _, existing := range m.users {
+		if existing.Email == user.Email || existing.Username == user.Username {
+			return ErrConflict
+		}
+	}
+	if m.hasIdentity(identity.Provider, identity.Subject) {
+		return ErrConflict
+	}
+
+	now := time.Now()
+	m.nextUserID++
+	user.UserID = m.nextUserID
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	m.users[user.UserID] = *user
+
+	identity.UserID = user.UserID
+	m.addIdentity(identity, now)
+	return nil
+}
+
+func (m *Memory) LinkIdentity(ctx context.Context, identity *models.Identity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.hasIdentity(identity.Provider, identity.Subject) {
+		return ErrConflict
+	}
+	m.addIdentity(identity, time.Now())
+	return nil
+}
+
+func (m *Memory) RecordSignIn(ctx context.Context, identity *models.Identity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.identities[identity.IdentityID]
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now()
+	stored.Email = identity.Email
+	stored.EmailVerified = identity.EmailVerified
+	stored.LastSignInAt = &now
+	m.identities[stored.IdentityID] = stored
+	*identity = stored
+	return nil
+}
+
+func (m *Memory) UnlinkIdentity(ctx context.Context, userID, identityID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	identity, ok := m.identities[identityID]
+	if !ok || identity.UserID != userID {
+		return ErrNotFound
+	}
+
+	count := 0
+	for _, other := range m.identities {
+		if other.UserID == userID {
+			count++
+		}
+	}
+	if count <= 1 {
+		return ErrLastIdentity
+	}
+
+	delete(m.identities, identityID)
+	return nil
+}
+
+func (m *Memory) hasIdentity(provider, subject string) bool {
+	for _, existing := range m.identities {
+		if existing.Provider == provider && existing.Subject == subject {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) addIdentity(identity *models.Identity, now time.Time) {
+	m.nextIdentityID++
+	identity.IdentityID = m.nextIdentityID
+	identity.CreatedAt = now
+	identity.LastSignInAt = &now
+	m.identities[identity.IdentityID] = *identity
+}
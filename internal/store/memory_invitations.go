@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func (m *Memory) CreateInvitation(ctx context.Context, invitation *models.Invitation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.rolePermissions[invitation.Role]; !ok {
+		return fmt.Errorf("unknown role %q", invitation.Role)
+	}
+	if _, ok := m.items[invitation.ItemID]; !ok {
+		return ErrNotFound
+	}
+
+	for id, existing := range m.invitations {
+		if existing.ItemID == invitation.ItemID && strings.EqualFold(existing.Email, invitation.Email) {
+			invitation.InvitationID = id
+		}
+	}
+	if invitation.InvitationID == 0 {
+		m.nextInvitationID++
+		invitation.InvitationID = m.nextInvitationID
+	}
+	invitation.CreatedAt = time.Now()
+	m.invitations[invitation.InvitationID] = *invitation
+	return nil
+}
+
+func (m *Memory) ListInvitations(ctx context.Context, itemID int) ([]models.Invitation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var invitations []models.Invitation
+	for _, invitation := range m.invitations {
+		if invitation.ItemID == itemID && time.Now().Before(invitation.ExpiresAt) {
+			invitations = append(invitations, invitation)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool { return invitations[i].InvitationID > invitations[j].InvitationID })
+	return invitations, nil
+}
+
+func (m *Memory) AcceptInvitation(ctx context.Context, tokenHash string, userID int) (models.Invitation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	invitations := m.acceptInvitations(func(i models.Invitation) bool { return i.TokenHash == tokenHash }, userID)
+	if len(invitations) == 0 {
+		return models.Invitation{}, ErrNotFound
+	}
+	return invitations[0], nil
+}
+
+func (m *Memory) DeclineInvitation(ctx context.Context, tokenHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, invitation := range m.invitations {
+		if invitation.TokenHash == tokenHash && time.Now().Before(invitation.ExpiresAt) {
+			delete(m.invitations, id)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *Memory) RevokeInvitation(ctx context.Context, itemID, invitationID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	invitation, ok := m.invitations[invitationID]
+	if !ok || invitation.ItemID != itemID {
+		return ErrNotFound
+	}
+	delete(m.invitations, invitationID)
+	return nil
+}
+
+func (m *Memory) ClaimInvitations(ctx context.Context, email string, userID int) ([]models.Invitation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.acceptInvitations(func(i models.Invitation) bool { return strings.EqualFold(i.Email, email) }, userID), nil
+}
+
+// acceptInvitations must be called with m.mu held
+func (m *Memory) acceptInvitations(match func(models.Invitation) bool, userID int) []models.Invitation {
+	var accepted []models.Invitation
+	for id, invitation := range m.invitations {
+		if !match(invitation) || !time.Now().Before(invitation.ExpiresAt) {
+			continue
+		}
+		delete(m.invitations, id)
+		accepted = append(accepted, invitation)
+
+		if _, ok := m.userRoles[invitation.ItemID][userID]; ok {
+			continue
+		}
+		m.userRoles[invitation.ItemID][userID] = memoryRole{
+			role:      invitation.Role,
+			createdBy: invitation.InvitedBy,
+			createdAt: time.Now(),
+		}
+	}
+	sort.Slice(accepted, func(i, j int) bool { return accepted[i].ItemID < accepted[j].ItemID })
+	return accepted
+}
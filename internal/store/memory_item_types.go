@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func (m *Memory) ListItemTypes(ctx context.Context) ([]models.ItemType, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var itemTypes []models.ItemType
+	for _, itemType := range m.itemTypes {
+		itemTypes = append(itemTypes, itemType)
+	}
+	sort.Slice(itemTypes, func(i, j int) bool {
+		if itemTypes[i].BuiltIn != itemTypes[j].BuiltIn {
+			return itemTypes[i].BuiltIn
+		}
+		return itemTypes[i].Name < itemTypes[j].Name
+	})
+
+	return itemTypes, nil
+}
+
+func (m *Memory) GetItemType(ctx context.Context, name string) (models.ItemType, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	itemType, ok := m.itemTypes[name]
+	if !ok {
+		return itemType, ErrNotFound
+	}
+	return itemType, nil
+}
+
+func (m *Memory) CreateItemType(ctx context.Context, itemType *models.ItemType) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.itemTypes[itemType.Name]; ok {
+		return ErrConflict
+	}
+
+	itemType.BuiltIn = false
+	itemType.CreatedAt = time.Now()
+	m.itemTypes[itemType.Name] = *itemType
+
+	return nil
+}
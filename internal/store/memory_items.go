@@ -0,0 +1,257 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func (m *Memory) ListItems(ctx context.Context, q ItemQuery) ([]models.ItemWithAccess, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var items []models.ItemWithAccess
+	for itemID, roles := range m.userRoles {
+		if _, ok := roles[q.UserID]; !ok {
+			continue
+		}
+		item := m.itemWithAccess(itemID, q.UserID)
+		if matchesItemQuery(q, item) {
+			items = append(items, item)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return compareItemKeys(q, q.CursorFor(items[i]), q.CursorFor(items[j])) < 0
+	})
+
+	if q.After != nil {
+		start := sort.Search(len(items), func(i int) bool {
+			return compareItemKeys(q, q.CursorFor(items[i]), q.After) > 0
+		})
+		items = items[start:]
+	}
+
+	if q.Limit > 0 && len(items) > q.Limit {
+		items = items[:q.Limit]
+	}
+
+	return items, nil
+}
+
+func (m *Memory) GetItemForUser(ctx context.Context, itemID, userID int) (models.ItemWithAccess, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.userRoles[itemID][userID]; !ok {
+		return models.ItemWithAccess{}, ErrNotFound
+	}
+	return m.itemWithAccess(itemID, userID), nil
+}
+
+func (m *Memory) GetItem(ctx context.Context, itemID int) (models.Item, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	item, ok := m.items[itemID]
+	if !ok {
+		return item, ErrNotFound
+	}
+	return item, nil
+}
+
+func (m *Memory) CreateItem(ctx context.Context, item *models.Item, ownerID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.itemTypes[item.ItemType]; !ok {
+		return fmt.Errorf("unknown item type %q", item.ItemType)
+	}
+
+	now := time.Now()
+	m.nextItemID++
+	item.ItemID = m.nextItemID
+	item.Version = 1
+	item.CreatedAt = now
+	item.UpdatedAt = now
+
+	m.items[item.ItemID] = *item
+	m.userRoles[item.ItemID] = map[int]memoryRole{
+		ownerID: {role: "owner", createdBy: ownerID, createdAt: now},
+	}
+
+	return nil
+}
+
+func (m *Memory) UpdateItem(ctx context.Context, itemID int, name string, content json.RawMessage, expectedVersion int) (models.Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, err := m.versionedItem(itemID, expectedVersion)
+	if err != nil {
+		return models.Item{}, err
+	}
+
+	current.Name = name
+	if content != nil {
+		current.Content = content
+	}
+	current.Version++
+	current.UpdatedAt = time.Now()
+	m.items[itemID] = current
+
+	return current, nil
+}
+
+func (m *Memory) PatchItem(ctx context.Context, itemID int, patch ContentPatch, expectedVersion int) (models.Item, error) {
+	// patch may read from the store itself, e.g. to look up the item type's
+	// schema, so it runs unlocked and the result is only saved if nobody
+	// changed the item in the meantime
+	for {
+		m.mu.RLock()
+		current, err := m.versionedItem(itemID, expectedVersion)
+		m.mu.RUnlock()
+		if err != nil {
+			return models.Item{}, err
+		}
+
+		content, err := patch(current)
+		if err != nil {
+			return current, err
+		}
+
+		m.mu.Lock()
+		if latest, ok := m.items[itemID]; !ok || latest.Version != current.Version {
+			m.mu.Unlock()
+			continue
+		}
+		current.Content = content
+		current.Version++
+		current.UpdatedAt = time.Now()
+		m.items[itemID] = current
+		m.mu.Unlock()
+
+		return current, nil
+	}
+}
+
+func (m *Memory) DeleteItem(ctx context.Context, itemID, expectedVersion int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.versionedItem(itemID, expectedVersion); err != nil {
+		return err
+	}
+
+	for todoID, todo := range m.todos {
+		if todo.ItemID == itemID {
+			delete(m.todos, todoID)
+		}
+	}
+	for id, invitation := range m.invitations {
+		if invitation.ItemID == itemID {
+			delete(m.invitations, id)
+		}
+	}
+	for id, link := range m.shareLinks {
+		if link.ItemID == itemID {
+			delete(m.shareLinks, id)
+		}
+	}
+	delete(m.userRoles, itemID)
+	delete(m.transfers, itemID)
+	delete(m.docs, itemID)
+	delete(m.items, itemID)
+
+	return nil
+}
+
+// versionedItem must be called with m.mu held
+func (m *Memory) versionedItem(itemID, expectedVersion int) (models.Item, error) {
+	item, ok := m.items[itemID]
+	if !ok {
+		return item, ErrNotFound
+	}
+	if expectedVersion != 0 && item.Version != expectedVersion {
+		return item, &ItemConflictError{Current: item}
+	}
+	return item, nil
+}
+
+// itemWithAccess must be called with m.mu held
+func (m *Memory) itemWithAccess(itemID, userID int) models.ItemWithAccess {
+	role := m.userRoles[itemID][userID]
+	item := models.ItemWithAccess{
+		Item: m.items[itemID],
+		Role: role.role,
+	}
+
+	// Only set SharedBy if the item was shared (role is not owner)
+	if role.role != "owner" {
+		if sharer, ok := m.users[role.createdBy]; ok {
+			item.SharedBy = sharer.Email
+		}
+	}
+
+	return item
+}
+
+func matchesItemQuery(q ItemQuery, item models.ItemWithAccess) bool {
+	if len(q.Roles) > 0 {
+		found := false
+		for _, role := range q.Roles {
+			if item.Role == role {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.SharedBy != "" && item.SharedBy != q.SharedBy {
+		return false
+	}
+	if q.NamePrefix != "" && !strings.HasPrefix(item.Name, q.NamePrefix) {
+		return false
+	}
+	if q.ItemType != "" && item.ItemType != q.ItemType {
+		return false
+	}
+	if !q.CreatedAfter.IsZero() && item.CreatedAt.Before(q.CreatedAfter) {
+		return false
+	}
+	if !q.CreatedBefore.IsZero() && !item.CreatedAt.Before(q.CreatedBefore) {
+		return false
+	}
+	if !q.UpdatedAfter.IsZero() && item.UpdatedAt.Before(q.UpdatedAfter) {
+		return false
+	}
+	if !q.UpdatedBefore.IsZero() && !item.UpdatedAt.Before(q.UpdatedBefore) {
+		return false
+	}
+	return true
+}
+
+// compareItemKeys orders two cursors the way the query sorts, returning a
+// negative number when a comes first
+func compareItemKeys(q ItemQuery, a, b *ItemCursor) int {
+	c := 0
+	if q.Sort == SortByName {
+		c = strings.Compare(a.Name, b.Name)
+	} else {
+		c = a.Time.Compare(b.Time)
+	}
+	if c == 0 {
+		c = a.ItemID - b.ItemID
+	}
+	if q.Desc {
+		return -c
+	}
+	return c
+}
@@ -0,0 +1,24 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+func (m *Memory) UseOAuthState(ctx context.Context, state string, expiresAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for used, expiry := range m.usedOAuthStates {
+		if expiry.Before(now) {
+			delete(m.usedOAuthStates, used)
+		}
+	}
+
+	if _, ok := m.usedOAuthStates[state]; ok {
+		return false, nil
+	}
+	m.usedOAuthStates[state] = expiresAt
+	return true, nil
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func (m *Memory) CreatePersonalToken(ctx context.Context, token *models.PersonalToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.personalTokens {
+		if existing.TokenHash == token.TokenHash {
+			return ErrConflict
+		}
+	}
+
+	m.nextPersonalTokenID++
+	token.TokenID = m.nextPersonalTokenID
+	token.CreatedAt = time.Now()
+	token.Scopes = append([]string(nil), token.Scopes...)
+	m.personalTokens[token.TokenID] = *token
+	return nil
+}
+
+func (m *Memory) ListPersonalTokens(ctx context.Context, userID int) ([]models.PersonalToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var tokens []models.PersonalToken
+	for _, token := range m.personalTokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].TokenID > tokens[j].TokenID })
+	return tokens, nil
+}
+
+func (m *Memory) UsePersonalToken(ctx context.Context, tokenHash string) (models.PersonalToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for tokenID, token := range m.personalTokens {
+		if token.TokenHash != tokenHash {
+			continue
+		}
+		if token.RevokedAt != nil || !token.ExpiresAt.After(now) {
+			break
+		}
+		token.LastUsedAt = &now
+		m.personalTokens[tokenID] = token
+		return token, nil
+	}
+	return models.PersonalToken{}, ErrNotFound
+}
+
+func (m *Memory) RevokePersonalToken(ctx context.Context, userID, tokenID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.personalTokens[tokenID]
+	if !ok || token.UserID != userID || token.RevokedAt != nil {
+		return ErrNotFound
+	}
+	now := time.Now()
+	token.RevokedAt = &now
+	m.personalTokens[tokenID] = token
+	return nil
+}
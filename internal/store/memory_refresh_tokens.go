@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func (m *Memory) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for hash, existing := range m.refreshTokens {
+		if existing.UserID == token.UserID && existing.ExpiresAt.Before(now) {
+			delete(m.refreshTokens, hash)
+		}
+	}
+
+	m.addRefreshToken(token, now)
+	return nil
+}
+
+func (m *Memory) RotateRefreshToken(ctx context.Context, tokenHash string, next *models.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	current, ok := m.refreshTokens[tokenHash]
+	if !ok || current.RevokedAt != nil || current.ExpiresAt.Before(now) {
+		return ErrNotFound
+	}
+
+	if current.UsedAt != nil {
+		next.FamilyID = current.FamilyID
+		m.revokeSession(current.FamilyID, now)
+		return ErrTokenReused
+	}
+
+	current.UsedAt = &now
+	m.refreshTokens[tokenHash] = current
+
+	next.UserID = current.UserID
+	next.FamilyID = current.FamilyID
+	m.addRefreshToken(next, now)
+
+	if session, ok := m.sessions[current.FamilyID]; ok {
+		session.ExpiresAt = next.ExpiresAt
+		m.sessions[current.FamilyID] = session
+	}
+	return nil
+}
+
+func (m *Memory) RevokeRefreshToken(ctx context.Context, tokenHash string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.refreshTokens[tokenHash]
+	if !ok {
+		return "", ErrNotFound
+	}
+	m.revokeSession(token.FamilyID, time.Now())
+	return token.FamilyID, nil
+}
+
+// addRefreshToken must be called with m.mu held
+func (m *Memory) addRefreshToken(token *models.RefreshToken, now time.Time) {
+	m.nextRefreshTokenID++
+	token.TokenID = m.nextRefreshTokenID
+	token.CreatedAt = now
+	token.UsedAt = nil
+	token.RevokedAt = nil
+	m.refreshTokens[token.TokenHash] = *token
+}
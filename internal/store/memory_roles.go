@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func (m *Memory) ListRoles(ctx context.Context) ([]models.Role, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var roles []models.Role
+	for name := range m.roles {
+		roles = append(roles, m.role(name))
+	}
+	sort.Slice(roles, func(i, j int) bool {
+		if roles[i].BuiltIn != roles[j].BuiltIn {
+			return roles[i].BuiltIn
+		}
+		return roles[i].RoleID < roles[j].RoleID
+	})
+	return roles, nil
+}
+
+func (m *Memory) GetRoleByName(ctx context.Context, name string) (models.Role, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.roles[name]; !ok {
+		return models.Role{}, ErrNotFound
+	}
+	return m.role(name), nil
+}
+
+func (m *Memory) CreateRole(ctx context.Context, role *models.Role) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.roles[role.Name]; ok {
+		return ErrConflict
+	}
+	for _, permission := range role.Permissions {
+		if !m.permissionExists(permission) {
+			return ErrNotFound
+		}
+	}
+
+	m.nextRoleID++
+	m.roles[role.Name] = models.Role{
+		RoleID:      m.nextRoleID,
+		Name:        role.Name,
+		Description: role.Description,
+		CreatedAt:   time.Now(),
+	}
+	m.rolePermissions[role.Name] = append([]string(nil), role.Permissions...)
+	*role = m.role(role.Name)
+	return nil
+}
+
+func (m *Memory) DeleteRole(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.customRole(name); err != nil {
+		return err
+	}
+	for _, users := range m.userRoles {
+		for _, role := range users {
+			if role.role == name {
+				return ErrConflict
+			}
+		}
+	}
+	for _, invitation := range m.invitations {
+		if invitation.Role == name && time.Now().Before(invitation.ExpiresAt) {
+			return ErrConflict
+		}
+	}
+	for _, link := range m.shareLinks {
+		if link.Role == name && linkWorks(link) {
+			return ErrConflict
+		}
+	}
+
+	delete(m.roles, name)
+	delete(m.rolePermissions, name)
+	return nil
+}
+
+func (m *Memory) AddRolePermission(ctx context.Context, role, permission string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.customRole(role); err != nil {
+		return err
+	}
+	if !m.permissionExists(permission) {
+		return ErrNotFound
+	}
+	for _, p := range m.rolePermissions[role] {
+		if p == permission {
+			return nil
+		}
+	}
+	m.rolePermissions[role] = append(m.rolePermissions[role], permission)
+	return nil
+}
+
+func (m *Memory) RemoveRolePermission(ctx context.Context, role, permission string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.customRole(role); err != nil {
+		return err
+	}
+	permissions := m.rolePermissions[role]
+	for i, p := range permissions {
+		if p == permission {
+			m.rolePermissions[role] = append(permissions[:i:i], permissions[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *Memory) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]models.Permission(nil), m.permissions...), nil
+}
+
+// role returns the named role with its permissions. It must be called with
+// m.mu held.
+func (m *Memory) role(name string) models.Role {
+	role := m.roles[name]
+	role.Permissions = append([]string{}, m.rolePermissions[name]...)
+	sort.Strings(role.Permissions)
+	return role
+}
+
+// customRole returns ErrNotFound or ErrConflict unless the named role exists
+// and isn't built in. It must be called with m.mu held.
+func (m *Memory) customRole(name string) error {
+	role, ok := m.roles[name]
+	if !ok {
+		return ErrNotFound
+	}
+	if role.BuiltIn {
+		return ErrConflict
+	}
+	return nil
+}
+
+// permissionExists must be called with m.mu held
+func (m *Memory) permissionExists(name string) bool {
+	for _, p := range m.permissions {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
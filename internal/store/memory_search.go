@@ -0,0 +1,161 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+// Search does a case-insensitive match of every query term. Ranking is the
+// share of terms found, which is enough to exercise the API without Postgres.
+func (m *Memory) Search(ctx context.Context, userID int, query string, limit int) ([]models.SearchResult, error) {
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) == 0 {
+		return []models.SearchResult{}, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	canView := func(itemID int) bool {
+		role, ok := m.userRoles[itemID][userID]
+		if !ok {
+			return false
+		}
+		for _, p := range m.rolePermissions[role.role] {
+			if p == "can_view" {
+				return true
+			}
+		}
+		return false
+	}
+
+	results := []models.SearchResult{}
+	for itemID, item := range m.items {
+		if !canView(itemID) {
+			continue
+		}
+		text := strings.Join(append([]string{item.Name}, jsonStrings(item.Content)...), " ")
+		if rank := matchTerms(text, terms); rank > 0 {
+			results = append(results, models.SearchResult{
+				Type:    "item",
+				ItemID:  itemID,
+				Title:   item.Name,
+				Snippet: highlightTerms(text, terms),
+				Rank:    rank,
+			})
+		}
+	}
+	for todoID, todo := range m.todos {
+		if !canView(todo.ItemID) {
+			continue
+		}
+		if rank := matchTerms(todo.Title, terms); rank > 0 {
+			results = append(results, models.SearchResult{
+				Type:    "todo",
+				ItemID:  todo.ItemID,
+				TodoID:  todoID,
+				Title:   todo.Title,
+				Snippet: highlightTerms(todo.Title, terms),
+				Rank:    rank,
+			})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		if results[i].ItemID != results[j].ItemID {
+			return results[i].ItemID < results[j].ItemID
+		}
+		return results[i].TodoID < results[j].TodoID
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// matchTerms returns the fraction of terms that appear in text
+func matchTerms(text string, terms []string) float64 {
+	lower := strings.ToLower(text)
+	found := 0
+	for _, term := range terms {
+		if strings.Contains(lower, term) {
+			found++
+		}
+	}
+	return float64(found) / float64(len(terms))
+}
+
+// highlightTerms HTML-escapes text and wraps every occurrence of the terms
+// in <mark></mark>
+func highlightTerms(text string, terms []string) string {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		// Case folding changed byte offsets, so positions can't be mapped back
+		return html.EscapeString(text)
+	}
+	marked := make([]bool, len(text))
+	for _, term := range terms {
+		for start := 0; ; {
+			i := strings.Index(lower[start:], term)
+			if i < 0 {
+				break
+			}
+			for k := start + i; k < start+i+len(term); k++ {
+				marked[k] = true
+			}
+			start += i + len(term)
+		}
+	}
+
+	var b strings.Builder
+	for start := 0; start < len(text); {
+		end := start
+		for end < len(text) && marked[end] == marked[start] {
+			end++
+		}
+		if marked[start] {
+			b.WriteString("<mark>" + html.EscapeString(text[start:end]) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(text[start:end]))
+		}
+		start = end
+	}
+	return b.String()
+}
+
+// jsonStrings collects every string value in a JSON document
+func jsonStrings(raw json.RawMessage) []string {
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil
+	}
+
+	var out []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case string:
+			out = append(out, v)
+		case []interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		case map[string]interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		}
+	}
+	walk(doc)
+	return out
+}
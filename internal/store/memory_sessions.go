@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func (m *Memory) CreateSession(ctx context.Context, session *models.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for sessionID, existing := range m.sessions {
+		if existing.UserID == session.UserID && existing.ExpiresAt.Before(now) {
+			m.deleteSession(sessionID)
+		}
+	}
+
+	if _, ok := m.sessions[session.SessionID]; ok {
+		return ErrConflict
+	}
+	session.CreatedAt = now
+	session.RevokedAt = nil
+	m.sessions[session.SessionID] = *session
+
+	return nil
+}
+
+func (m *Memory) SessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, ok := m.sessions[sessionID]
+	if !ok {
+		return true, nil
+	}
+	return session.RevokedAt != nil || !session.ExpiresAt.After(time.Now()), nil
+}
+
+func (m *Memory) RevokeSession(ctx context.Context, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.revokeSession(sessionID, time.Now())
+	return nil
+}
+
+func (m *Memory) RevokeUserSessions(ctx context.Context, userID int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var sessionIDs []string
+	for sessionID, session := range m.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			m.revokeSession(sessionID, now)
+			sessionIDs = append(sessionIDs, sessionID)
+		}
+	}
+	sort.Strings(sessionIDs)
+
+	return sessionIDs, nil
+}
+
+// revokeSession must be called with m.mu held
+func (m *Memory) revokeSession(sessionID string, now time.Time) {
+	if session, ok := m.sessions[sessionID]; ok && session.RevokedAt == nil {
+		session.RevokedAt = &now
+		m.sessions[sessionID] = session
+	}
+	for hash, token := range m.refreshTokens {
+		if token.FamilyID == sessionID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			m.refreshTokens[hash] = token
+		}
+	}
+}
+
+// deleteSession must be called with m.mu held
+func (m *Memory) deleteSession(sessionID string) {
+	delete(m.sessions, sessionID)
+	for hash, token := range m.refreshTokens {
+		if token.FamilyID == sessionID {
+			delete(m.refreshTokens, hash)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func (m *Memory) CreateShareLink(ctx context.Context, link *models.ShareLink) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.rolePermissions[link.Role]; !ok {
+		return fmt.Errorf("unknown role %q", link.Role)
+	}
+	if _, ok := m.items[link.ItemID]; !ok {
+		return ErrNotFound
+	}
+
+	m.nextShareLinkID++
+	link.LinkID = m.nextShareLinkID
+	link.PasswordProtected = link.PasswordHash != ""
+	link.CreatedAt = time.Now()
+	m.shareLinks[link.LinkID] = *link
+	return nil
+}
+
+func (m *Memory) ListShareLinks(ctx context.Context, itemID int) ([]models.ShareLink, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var links []models.ShareLink
+	for _, link := range m.shareLinks {
+		if link.ItemID == itemID {
+			links = append(links, link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].LinkID > links[j].LinkID })
+	return links, nil
+}
+
+func (m *Memory) GetShareLink(ctx context.Context, tokenHash string) (models.ShareLink, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, link := range m.shareLinks {
+		if link.TokenHash == tokenHash && linkWorks(link) {
+			return link, nil
+		}
+	}
+	return models.ShareLink{}, ErrNotFound
+}
+
+func (m *Memory) UseShareLink(ctx context.Context, linkID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.shareLinks[linkID]
+	if !ok || !linkWorks(link) {
+		return ErrNotFound
+	}
+	link.UseCount++
+	m.shareLinks[linkID] = link
+	return nil
+}
+
+// RevokeShareLink forgets the link, since revoked links are never returned
+func (m *Memory) RevokeShareLink(ctx context.Context, itemID, linkID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.shareLinks[linkID]
+	if !ok || link.ItemID != itemID {
+		return ErrNotFound
+	}
+	delete(m.shareLinks, linkID)
+	return nil
+}
+
+// linkWorks mirrors the shareLinkWorks condition
+func linkWorks(link models.ShareLink) bool {
+	if link.ExpiresAt != nil && !time.Now().Before(*link.ExpiresAt) {
+		return false
+	}
+	return link.MaxUses == nil || link.UseCount < *link.MaxUses
+}
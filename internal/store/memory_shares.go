@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func (m *Memory) ShareItem(ctx context.Context, itemID, userID int, role string, sharedBy int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := m.rolePermissions[role]; !ok {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	if _, ok := m.items[itemID]; !ok {
+		return ErrNotFound
+	}
+
+	previous, hadRole := m.userRoles[itemID][userID]
+	m.userRoles[itemID][userID] = memoryRole{role: role, createdBy: sharedBy, createdAt: time.Now()}
+	if !m.hasOwner(itemID) {
+		if hadRole {
+			m.userRoles[itemID][userID] = previous
+		}
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func (m *Memory) ItemUserIDs(ctx context.Context, itemID int) ([]int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var userIDs []int
+	for userID := range m.userRoles[itemID] {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Ints(userIDs)
+
+	return userIDs, nil
+}
+
+func (m *Memory) HasPermission(ctx context.Context, userID, itemID int, permission string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	role, ok := m.userRoles[itemID][userID]
+	if !ok {
+		return false, nil
+	}
+
+	for _, p := range m.rolePermissions[role.role] {
+		if p == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *Memory) ListCollaborators(ctx context.Context, itemID int) ([]models.Collaborator, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var collaborators []models.Collaborator
+	for userID, role := range m.userRoles[itemID] {
+		user := m.users[userID]
+		collaborators = append(collaborators, models.Collaborator{
+			UserID:   userID,
+			Email:    user.Email,
+			Username: user.Username,
+			Role:     role.role,
+			SharedBy: role.createdBy,
+			SharedAt: role.createdAt,
+		})
+	}
+	sort.Slice(collaborators, func(i, j int) bool {
+		a, b := collaborators[i], collaborators[j]
+		if !a.SharedAt.Equal(b.SharedAt) {
+			return a.SharedAt.Before(b.SharedAt)
+		}
+		return a.UserID < b.UserID
+	})
+
+	return collaborators, nil
+}
+
+func (m *Memory) GetRole(ctx context.Context, itemID, userID int) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	role, ok := m.userRoles[itemID][userID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return role.role, nil
+}
+
+func (m *Memory) ChangeRole(ctx context.Context, itemID, userID int, role string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.rolePermissions[role]; !ok {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	existing, ok := m.userRoles[itemID][userID]
+	if !ok {
+		return ErrNotFound
+	}
+	previous := existing
+	existing.role = role
+	m.userRoles[itemID][userID] = existing
+	if !m.hasOwner(itemID) {
+		m.userRoles[itemID][userID] = previous
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func (m *Memory) RevokeAccess(ctx context.Context, itemID, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.userRoles[itemID][userID]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.userRoles[itemID], userID)
+	if !m.hasOwner(itemID) {
+		m.userRoles[itemID][userID] = existing
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func (m *Memory) RoleHasPermission(ctx context.Context, role, permission string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, p := range m.rolePermissions[role] {
+		if p == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// hasOwner mirrors the item_has_owner trigger. It must be called with m.mu
+// held.
+func (m *Memory) hasOwner(itemID int) bool {
+	for _, role := range m.userRoles[itemID] {
+		if role.role == "owner" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func (m *Memory) ListTodos(ctx context.Context, itemID int) ([]models.Todo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var todos []models.Todo
+	for _, todo := range m.todos {
+		if todo.ItemID == itemID {
+			todos = append(todos, todo)
+		}
+	}
+
+	sort.Slice(todos, func(i, j int) bool {
+		return todos[i].TodoID < todos[j].TodoID
+	})
+
+	return todos, nil
+}
+
+func (m *Memory) GetTodo(ctx context.Context, itemID, todoID int) (models.Todo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	todo, ok := m.todos[todoID]
+	if !ok || todo.ItemID != itemID {
+		return models.Todo{}, ErrNotFound
+	}
+	return todo, nil
+}
+
+func (m *Memory) CreateTodo(ctx context.Context, todo *models.Todo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.items[todo.ItemID]; !ok {
+		return ErrNotFound
+	}
+
+	now := time.Now()
+	m.nextTodoID++
+	todo.TodoID = m.nextTodoID
+	todo.Version = 1
+	todo.CreatedAt = now
+	todo.UpdatedAt = now
+	m.todos[todo.TodoID] = *todo
+
+	return nil
+}
+
+func (m *Memory) UpdateTodo(ctx context.Context, todo *models.Todo, expectedVersion int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, err := m.versionedTodo(todo.ItemID, todo.TodoID, expectedVersion)
+	if err != nil {
+		return err
+	}
+
+	current.Title = todo.Title
+	current.Done = todo.Done
+	current.Version++
+	current.UpdatedAt = time.Now()
+	m.todos[todo.TodoID] = current
+	*todo = current
+
+	return nil
+}
+
+func (m *Memory) MarkTodoDone(ctx context.Context, itemID, todoID, expectedVersion int) (models.Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	todo, err := m.versionedTodo(itemID, todoID, expectedVersion)
+	if err != nil {
+		return todo, err
+	}
+
+	todo.Done = true
+	todo.Version++
+	todo.UpdatedAt = time.Now()
+	m.todos[todoID] = todo
+
+	return todo, nil
+}
+
+func (m *Memory) DeleteTodo(ctx context.Context, itemID, todoID, expectedVersion int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.versionedTodo(itemID, todoID, expectedVersion); err != nil {
+		return err
+	}
+
+	delete(m.todos, todoID)
+	return nil
+}
+
+// versionedTodo must be called with m.mu held
+func (m *Memory) versionedTodo(itemID, todoID, expectedVersion int) (models.Todo, error) {
+	todo, ok := m.todos[todoID]
+	if !ok || todo.ItemID != itemID {
+		return models.Todo{}, ErrNotFound
+	}
+	if expectedVersion != 0 && todo.Version != expectedVersion {
+		return models.Todo{}, ErrPreconditionFailed
+	}
+	return todo, nil
+}
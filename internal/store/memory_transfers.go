@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func (m *Memory) OfferTransfer(ctx context.Context, transfer *models.OwnershipTransfer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.items[transfer.ItemID]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := m.users[transfer.ToUserID]; !ok {
+		return ErrNotFound
+	}
+
+	transfer.CreatedAt = time.Now()
+	m.transfers[transfer.ItemID] = *transfer
+	return nil
+}
+
+func (m *Memory) GetTransfer(ctx context.Context, itemID int) (models.OwnershipTransfer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	transfer, ok := m.transfers[itemID]
+	if !ok {
+		return models.OwnershipTransfer{}, ErrNotFound
+	}
+	return transfer, nil
+}
+
+func (m *Memory) ListTransfersTo(ctx context.Context, userID int) ([]models.OwnershipTransfer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var transfers []models.OwnershipTransfer
+	for _, transfer := range m.transfers {
+		if transfer.ToUserID == userID {
+			transfers = append(transfers, transfer)
+		}
+	}
+	sort.Slice(transfers, func(i, j int) bool { return transfers[i].ItemID < transfers[j].ItemID })
+	return transfers, nil
+}
+
+func (m *Memory) AcceptTransfer(ctx context.Context, itemID, toUserID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	transfer, ok := m.transfers[itemID]
+	if !ok || transfer.ToUserID != toUserID {
+		return ErrNotFound
+	}
+	from, ok := m.userRoles[itemID][transfer.FromUserID]
+	if !ok || from.role != "owner" {
+		return ErrNotFound
+	}
+
+	from.role = "editor"
+	m.userRoles[itemID][transfer.FromUserID] = from
+	m.userRoles[itemID][toUserID] = memoryRole{role: "owner", createdBy: transfer.FromUserID, createdAt: time.Now()}
+	delete(m.transfers, itemID)
+	return nil
+}
+
+func (m *Memory) DeleteTransfer(ctx context.Context, itemID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.transfers[itemID]; !ok {
+		return ErrNotFound
+	}
+	delete(m.transfers, itemID)
+	return nil
+}
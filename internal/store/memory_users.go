@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func (m *Memory) GetUser(ctx context.Context, userID int) (models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (m *Memory) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	return m.findUser(func(u models.User) bool { return u.Email == email })
+}
+
+func (m *Memory) IsAdmin(ctx context.Context, userID int) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.admins[userID], nil
+}
+
+// SetAdmin makes a user an admin or not. The Postgres store has no
+// equivalent; admins are set in the database.
+func (m *Memory) SetAdmin(userID int, admin bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.admins[userID] = admin
+}
+
+func (m *Memory) findUser(match func(models.User) bool) (models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if match(user) {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
@@ -0,0 +1,13 @@
+package store
+
+import "database/sql"
+
+// Postgres implements every store interface on top of a *sql.DB
+type Postgres struct {
+	db *sql.DB
+}
+
+// NewPostgres returns a Postgres store using the given connection pool
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
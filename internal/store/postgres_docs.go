@@ -0,0 +1,167 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func (p *Postgres) GetDoc(ctx context.Context, itemID int) (models.Doc, error) {
+	doc := models.Doc{ItemID: itemID}
+
+	var snapshot, content []byte
+	var updatedAt sql.NullTime
+	err := p.db.QueryRowContext(ctx, `
+		SELECT d.snapshot, i.content, COALESCE(d.last_update_id, 0), d.updated_at
+		FROM items i
+		LEFT JOIN item_docs d ON d.item_id = i.item_id
+		WHERE i.item_id = $1
+	`, itemID).Scan(&snapshot, &content, &doc.LastUpdateID, &updatedAt)
+	if err == sql.ErrNoRows {
+		return doc, ErrNotFound
+	}
+	if err != nil {
+		return doc, err
+	}
+
+	if snapshot != nil {
+		doc.Snapshot = snapshot
+	}
+	doc.Content = content
+	doc.UpdatedAt = updatedAt.Time
+	return doc, nil
+}
+
+func (p *Postgres) ApplyDocUpdate(ctx context.Context, update *models.DocUpdate, merge DocMerge, keepUpdates int) (models.Doc, models.Item, error) {
+	doc := models.Doc{ItemID: update.ItemID}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return doc, models.Item{}, err
+	}
+	defer tx.Rollback()
+
+	// Lock the item so concurrent updates to the same document merge in
+	// turn, and a document without a snapshot yet starts from the content
+	// as it is now
+	var content []byte
+	err = tx.QueryRowContext(ctx, "SELECT content FROM items WHERE item_id = $1 FOR UPDATE", update.ItemID).Scan(&content)
+	if err == sql.ErrNoRows {
+		return doc, models.Item{}, ErrNotFound
+	}
+	if err != nil {
+		return doc, models.Item{}, err
+	}
+
+	var snapshot []byte
+	err = tx.QueryRowContext(ctx, "SELECT snapshot FROM item_docs WHERE item_id = $1", update.ItemID).Scan(&snapshot)
+	if err != nil && err != sql.ErrNoRows {
+		return doc, models.Item{}, err
+	}
+
+	merged, content, err := merge(snapshot, content, update.Update)
+	if err != nil {
+		return doc, models.Item{}, err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO item_doc_updates (item_id, user_id, payload)
+		VALUES ($1, NULLIF($2, 0), $3::jsonb)
+		RETURNING update_id, created_at
+	`, update.ItemID, update.UserID, []byte(update.Update)).Scan(&update.UpdateID, &update.CreatedAt)
+	if err != nil {
+		return doc, models.Item{}, err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO item_docs (item_id, snapshot, last_update_id, updated_at)
+		VALUES ($1, $2::jsonb, $3, NOW())
+		ON CONFLICT (item_id) DO UPDATE
+		SET snapshot = EXCLUDED.snapshot, last_update_id = EXCLUDED.last_update_id, updated_at = EXCLUDED.updated_at
+		RETURNING snapshot, last_update_id, updated_at
+	`, update.ItemID, []byte(merged), update.UpdateID).Scan(&doc.Snapshot, &doc.LastUpdateID, &doc.UpdatedAt)
+	if err != nil {
+		return doc, models.Item{}, err
+	}
+
+	item, err := scanItem(tx.QueryRowContext(ctx, `
+		UPDATE items
+		SET content = $1::jsonb, version = version + 1, updated_at = NOW()
+		WHERE item_id = $2
+		RETURNING `+itemColumns,
+		[]byte(content), update.ItemID,
+	))
+	if err != nil {
+		return doc, item, err
+	}
+
+	// Compact the log: everything in it is already part of the snapshot, so
+	// only keep enough for clients catching up from a recent update
+	if keepUpdates > 0 {
+		_, err = tx.ExecContext(ctx, `
+			WITH dropped AS (
+				DELETE FROM item_doc_updates
+				WHERE item_id = $1 AND update_id <= (
+					SELECT update_id FROM item_doc_updates
+					WHERE item_id = $1
+					ORDER BY update_id DESC
+					OFFSET $2 LIMIT 1
+				)
+				RETURNING update_id
+			)
+			UPDATE item_docs
+			SET compacted_through = GREATEST(compacted_through, (SELECT MAX(update_id) FROM dropped))
+			WHERE item_id = $1
+		`, update.ItemID, keepUpdates)
+		if err != nil {
+			return doc, item, err
+		}
+	}
+
+	return doc, item, tx.Commit()
+}
+
+func (p *Postgres) DocUpdatesSince(ctx context.Context, itemID int, afterID int64) ([]models.DocUpdate, error) {
+	var compactedThrough int64
+	err := p.db.QueryRowContext(ctx, `
+		SELECT COALESCE(d.compacted_through, 0)
+		FROM items i
+		LEFT JOIN item_docs d ON d.item_id = i.item_id
+		WHERE i.item_id = $1
+	`, itemID).Scan(&compactedThrough)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if afterID < compactedThrough {
+		return nil, ErrCompacted
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT update_id, item_id, COALESCE(user_id, 0), payload, created_at
+		FROM item_doc_updates
+		WHERE item_id = $1 AND update_id > $2
+		ORDER BY update_id
+	`, itemID, afterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	updates := []models.DocUpdate{}
+	for rows.Next() {
+		var update models.DocUpdate
+		var payload []byte
+		if err := rows.Scan(&update.UpdateID, &update.ItemID, &update.UserID, &payload, &update.CreatedAt); err != nil {
+			return nil, err
+		}
+		update.Update = json.RawMessage(payload)
+		updates = append(updates, update)
+	}
+
+	return updates, rows.Err()
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+const identityColumns = "identity_id, user_id, provider, subject, COALESCE(email, ''), email_verified, created_at, last_sign_in_at"
+
+func (p *Postgres) GetIdentity(ctx context.Context, provider, subject string) (models.Identity, error) {
+	identity, err := scanIdentity(p.db.QueryRowContext(ctx,
+		"SELECT "+identityColumns+" FROM user_identities WHERE provider = $1 AND subject = $2",
+		provider, subject,
+	))
+	if err == sql.ErrNoRows {
+		return identity, ErrNotFound
+	}
+	return identity, err
+}
+
+func (p *Postgres) ListIdentities(ctx context.Context, userID int) ([]models.Identity, error) {
+	rows, err := p.db.QueryContext(ctx,
+		"SELECT "+identityColumns+" FROM user_identities WHERE user_id = $1 ORDER BY identity_id",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []models.Identity
+	for rows.Next() {
+		identity, err := scanIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+
+	return identities, rows.Err()
+}
+
+func (p *Postgres) GetUserByVerifiedEmail(ctx context.Context, email string) (models.User, error) {
+	return p.getUser(ctx, `user_id = (
+		SELECT user_id FROM user_identities
+		WHERE email_verified AND LOWER(email) = LOWER($1)
+		ORDER BY identity_id
+		LIMIT 1
+	)`, email)
+}
+
+func (p *Postgres) CreateUserWithIdentity(ctx context.Context, user *models.User, identity *models.Identity) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	created, err := scanUser(tx.QueryRowContext(ctx,
+		"INSERT INTO users (email, username) VALUES ($1, $2) ON CONFLICT DO NOTHING RETURNING "+userColumns,
+		user.Email, user.Username,
+	))
+	if err == sql.ErrNoRows {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	identity.UserID = created.UserID
+	if err := insertIdentity(ctx, tx, identity); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	*user = created
+	return nil
+}
+
+func (p *Postgres) LinkIdentity(ctx context.Context, identity *models.Identity) error {
+	return insertIdentity(ctx, p.db, identity)
+}
+
+func (p *Postgres) RecordSignIn(ctx context.Context, identity *models.Identity) error {
+	updated, err := scanIdentity(p.db.QueryRowContext(ctx, `
+		UPDATE user_identities
+		SET email = NULLIF($2, ''), email_verified = $3, last_sign_in_at = CURRENT_TIMESTAMP
+		WHERE identity_id = $1
+		RETURNING `+identityColumns,
+		identity.IdentityID, identity.Email, identity.EmailVerified,
+	))
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	*identity = updated
+	return nil
+}
+
+func (p *Postgres) UnlinkIdentity(ctx context.Context, userID, identityID int) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the user's identities so two unlinks can't remove the last two
+	var count int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM (
+			SELECT identity_id FROM user_identities WHERE user_id = $1 FOR UPDATE
+		) identities
+	`, userID).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		"DELETE FROM user_identities WHERE identity_id = $1 AND user_id = $2",
+		identityID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	if count <= 1 {
+		return ErrLastIdentity
+	}
+
+	return tx.Commit()
+}
+
+func insertIdentity(ctx context.Context, q queryRower, identity *models.Identity) error {
+	created, err := scanIdentity(q.QueryRowContext(ctx, `
+		INSERT INTO user_identities (user_id, provider, subject, email, email_verified, last_sign_in_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, CURRENT_TIMESTAMP)
+		ON CONFLICT (provider, subject) DO NOTHING
+		RETURNING `+identityColumns,
+		identity.UserID, identity.Provider, identity.Subject, identity.Email, identity.EmailVerified,
+	))
+	if err == sql.ErrNoRows {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	*identity = created
+	return nil
+}
+
+func scanIdentity(row rowScanner) (models.Identity, error) {
+	var identity models.Identity
+	var lastSignInAt sql.NullTime
+	err := row.Scan(
+		&identity.IdentityID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.EmailVerified,
+		&identity.CreatedAt,
+		&lastSignInAt,
+	)
+	if lastSignInAt.Valid {
+		identity.LastSignInAt = &lastSignInAt.Time
+	}
+	return identity, err
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+// invitationColumns are selected from item_invitations as i joined with
+// roles as r
+const invitationColumns = "i.invitation_id, i.item_id, i.email, r.name, COALESCE(i.invited_by, 0), i.token_hash, i.expires_at, i.created_at"
+
+func (p *Postgres) CreateInvitation(ctx context.Context, invitation *models.Invitation) error {
+	// Expired invitations can't be accepted, so there's no need to keep them
+	if _, err := p.db.ExecContext(ctx, "DELETE FROM item_invitations WHERE expires_at < NOW()"); err != nil {
+		return err
+	}
+
+	err := p.db.QueryRowContext(ctx, `
+		INSERT INTO item_invitations (item_id, email, role_id, invited_by, token_hash, expires_at)
+		SELECT $1, $2, role_id, $3, $4, $5 FROM roles WHERE name = $6
+		ON CONFLICT (item_id, LOWER(email)) DO UPDATE
+		SET email = EXCLUDED.email, role_id = EXCLUDED.role_id, invited_by = EXCLUDED.invited_by,
+			token_hash = EXCLUDED.token_hash, expires_at = EXCLUDED.expires_at, created_at = CURRENT_TIMESTAMP
+		RETURNING invitation_id, created_at
+	`, invitation.ItemID, invitation.Email, invitation.InvitedBy, invitation.TokenHash, invitation.ExpiresAt, invitation.Role,
+	).Scan(&invitation.InvitationID, &invitation.CreatedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("unknown role %q", invitation.Role)
+	}
+	return err
+}
+
+func (p *Postgres) ListInvitations(ctx context.Context, itemID int) ([]models.Invitation, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT `+invitationColumns+`
+		FROM item_invitations i
+		JOIN roles r ON i.role_id = r.role_id
+		WHERE i.item_id = $1 AND i.expires_at > NOW()
+		ORDER BY i.created_at DESC, i.invitation_id DESC
+	`, itemID)
+	if err != nil {
+		return nil, err
+	}
+	return scanInvitations(rows)
+}
+
+func (p *Postgres) AcceptInvitation(ctx context.Context, tokenHash string, userID int) (models.Invitation, error) {
+	invitations, err := p.acceptInvitations(ctx, "token_hash = $1", tokenHash, userID)
+	if err != nil {
+		return models.Invitation{}, err
+	}
+	if len(invitations) == 0 {
+		return models.Invitation{}, ErrNotFound
+	}
+	return invitations[0], nil
+}
+
+func (p *Postgres) DeclineInvitation(ctx context.Context, tokenHash string) error {
+	return p.deleteInvitation(ctx, "token_hash = $1 AND expires_at > NOW()", tokenHash)
+}
+
+func (p *Postgres) RevokeInvitation(ctx context.Context, itemID, invitationID int) error {
+	return p.deleteInvitation(ctx, "item_id = $1 AND invitation_id = $2", itemID, invitationID)
+}
+
+func (p *Postgres) ClaimInvitations(ctx context.Context, email string, userID int) ([]models.Invitation, error) {
+	return p.acceptInvitations(ctx, "LOWER(email) = LOWER($1)", email, userID)
+}
+
+// acceptInvitations turns the pending invitations matching where, which
+// uses $1, into roles for userID. Everything happens in one statement, so
+// an invitation can only be accepted once.
+func (p *Postgres) acceptInvitations(ctx context.Context, where string, arg interface{}, userID int) ([]models.Invitation, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		WITH accepted AS (
+			DELETE FROM item_invitations
+			WHERE `+where+` AND expires_at > NOW()
+			RETURNING *
+		), granted AS (
+			INSERT INTO user_roles (item_id, user_id, role_id, created_by)
+			SELECT item_id, $2, role_id, invited_by FROM accepted
+			ON CONFLICT (item_id, user_id) DO NOTHING
+		)
+		SELECT `+invitationColumns+`
+		FROM accepted i
+		JOIN roles r ON i.role_id = r.role_id
+		ORDER BY i.item_id
+	`, arg, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanInvitations(rows)
+}
+
+func (p *Postgres) deleteInvitation(ctx context.Context, where string, args ...interface{}) error {
+	result, err := p.db.ExecContext(ctx, "DELETE FROM item_invitations WHERE "+where, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanInvitations(rows *sql.Rows) ([]models.Invitation, error) {
+	defer rows.Close()
+
+	var invitations []models.Invitation
+	for rows.Next() {
+		var invitation models.Invitation
+		if err := rows.Scan(
+			&invitation.InvitationID,
+			&invitation.ItemID,
+			&invitation.Email,
+			&invitation.Role,
+			&invitation.InvitedBy,
+			&invitation.TokenHash,
+			&invitation.ExpiresAt,
+			&invitation.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, rows.Err()
+}
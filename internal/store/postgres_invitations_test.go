@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func TestPostgresClaimInvitations(t *testing.T) {
+	p, _ := newTestPostgres(t)
+	ctx := context.Background()
+	owner, newcomer := newPostgresUser(t, p, "owner"), newPostgresUser(t, p, "newcomer")
+	pending, expired := newPostgresList(t, p, owner), newPostgresList(t, p, owner)
+
+	for itemID, expiresAt := range map[int]time.Time{pending: time.Now().Add(time.Hour), expired: time.Now().Add(-time.Minute)} {
+		invitation := models.Invitation{
+			ItemID:    itemID,
+			Email:     "Newcomer@Example.com",
+			Role:      "editor",
+			InvitedBy: owner,
+			TokenHash: "hash-" + strconv.Itoa(itemID),
+			ExpiresAt: expiresAt,
+		}
+		if err := p.CreateInvitation(ctx, &invitation); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The address matches whatever its case, and only unexpired invitations
+	// are claimed
+	claimed, err := p.ClaimInvitations(ctx, "newcomer@example.com", newcomer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 1 || claimed[0].ItemID != pending {
+		t.Fatalf("claimed %+v, want the invitation to item %d", claimed, pending)
+	}
+	if role, err := p.GetRole(ctx, pending, newcomer); err != nil || role != "editor" {
+		t.Errorf("got role %q, %v, want editor", role, err)
+	}
+	if _, err := p.GetRole(ctx, expired, newcomer); err == nil {
+		t.Error("expired invitation gave a role")
+	}
+
+	if claimed, err := p.ClaimInvitations(ctx, "newcomer@example.com", newcomer); err != nil || len(claimed) != 0 {
+		t.Errorf("claiming again: got %+v, %v, want nothing", claimed, err)
+	}
+}
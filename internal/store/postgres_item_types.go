@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+const itemTypeColumns = "name, COALESCE(description, ''), schema, built_in, COALESCE(created_by, 0), created_at"
+
+func (p *Postgres) ListItemTypes(ctx context.Context) ([]models.ItemType, error) {
+	rows, err := p.db.QueryContext(ctx, "SELECT "+itemTypeColumns+" FROM item_types ORDER BY built_in DESC, name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var itemTypes []models.ItemType
+	for rows.Next() {
+		itemType, err := scanItemType(rows)
+		if err != nil {
+			return nil, err
+		}
+		itemTypes = append(itemTypes, itemType)
+	}
+
+	return itemTypes, rows.Err()
+}
+
+func (p *Postgres) GetItemType(ctx context.Context, name string) (models.ItemType, error) {
+	itemType, err := scanItemType(p.db.QueryRowContext(ctx, "SELECT "+itemTypeColumns+" FROM item_types WHERE name = $1", name))
+	if err == sql.ErrNoRows {
+		return itemType, ErrNotFound
+	}
+	return itemType, err
+}
+
+func (p *Postgres) CreateItemType(ctx context.Context, itemType *models.ItemType) error {
+	created, err := scanItemType(p.db.QueryRowContext(ctx, `
+		INSERT INTO item_types (name, description, schema, created_by)
+		VALUES ($1, $2, $3::jsonb, NULLIF($4, 0))
+		ON CONFLICT (name) DO NOTHING
+		RETURNING `+itemTypeColumns,
+		itemType.Name, itemType.Description, []byte(itemType.Schema), itemType.CreatedBy,
+	))
+	if err == sql.ErrNoRows {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	*itemType = created
+	return nil
+}
+
+func scanItemType(row rowScanner) (models.ItemType, error) {
+	var itemType models.ItemType
+	err := row.Scan(
+		&itemType.Name,
+		&itemType.Description,
+		&itemType.Schema,
+		&itemType.BuiltIn,
+		&itemType.CreatedBy,
+		&itemType.CreatedAt,
+	)
+	return itemType, err
+}
@@ -0,0 +1,348 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func (p *Postgres) ListItems(ctx context.Context, q ItemQuery) ([]models.ItemWithAccess, error) {
+	args := []interface{}{q.UserID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	// Get all items where user has any role (owner, editor, or viewer)
+	conditions := []string{"ur.user_id = $1"}
+	if len(q.Roles) > 0 {
+		conditions = append(conditions, "r.name = ANY("+arg(pq.Array(q.Roles))+")")
+	}
+	if q.SharedBy != "" {
+		conditions = append(conditions, "r.name <> 'owner' AND u.email = "+arg(q.SharedBy))
+	}
+	if q.NamePrefix != "" {
+		conditions = append(conditions, "i.name LIKE "+arg(escapeLike(q.NamePrefix)+"%"))
+	}
+	if q.ItemType != "" {
+		conditions = append(conditions, "i.item_type = "+arg(q.ItemType))
+	}
+	if !q.CreatedAfter.IsZero() {
+		conditions = append(conditions, "i.created_at >= "+arg(q.CreatedAfter))
+	}
+	if !q.CreatedBefore.IsZero() {
+		conditions = append(conditions, "i.created_at < "+arg(q.CreatedBefore))
+	}
+	if !q.UpdatedAfter.IsZero() {
+		conditions = append(conditions, "i.updated_at >= "+arg(q.UpdatedAfter))
+	}
+	if !q.UpdatedBefore.IsZero() {
+		conditions = append(conditions, "i.updated_at < "+arg(q.UpdatedBefore))
+	}
+
+	sortColumn := "i.created_at"
+	switch q.Sort {
+	case SortByName:
+		sortColumn = "i.name"
+	case SortByUpdatedAt:
+		sortColumn = "i.updated_at"
+	}
+
+	direction, comparison := "ASC", ">"
+	if q.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	// Keyset pagination: continue strictly after the cursor's (sort key, item_id)
+	if q.After != nil {
+		var key interface{} = q.After.Time
+		if q.Sort == SortByName {
+			key = q.After.Name
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, i.item_id) %s (%s, %s)",
+			sortColumn, comparison, arg(key), arg(q.After.ItemID)))
+	}
+
+	query := `
+		SELECT 
+			i.item_id,
+			i.name,
+			i.item_type,
+			i.content,
+			i.version,
+			i.created_at,
+			i.updated_at,
+			r.name as role_name,
+			u.email as shared_by_email
+		FROM items i
+		JOIN user_roles ur ON i.item_id = ur.item_id
+		JOIN roles r ON ur.role_id = r.role_id
+		LEFT JOIN users u ON ur.created_by = u.user_id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY ` + sortColumn + ` ` + direction + `, i.item_id ` + direction
+	if q.Limit > 0 {
+		query += " LIMIT " + arg(q.Limit)
+	}
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.ItemWithAccess
+	for rows.Next() {
+		item, err := scanItemWithAccess(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+func (p *Postgres) GetItemForUser(ctx context.Context, itemID, userID int) (models.ItemWithAccess, error) {
+	// Query item with user's role and who shared it
+	query := `
+		SELECT 
+			i.item_id,
+			i.name,
+			i.item_type,
+			i.content,
+			i.version,
+			i.created_at,
+			i.updated_at,
+			r.name as role_name,
+			u.email as shared_by_email
+		FROM items i
+		JOIN user_roles ur ON i.item_id = ur.item_id
+		JOIN roles r ON ur.role_id = r.role_id
+		LEFT JOIN users u ON ur.created_by = u.user_id
+		WHERE i.item_id = $1 AND ur.user_id = $2
+	`
+	item, err := scanItemWithAccess(p.db.QueryRowContext(ctx, query, itemID, userID))
+	if err == sql.ErrNoRows {
+		return item, ErrNotFound
+	}
+	return item, err
+}
+
+func (p *Postgres) GetItem(ctx context.Context, itemID int) (models.Item, error) {
+	item, err := scanItem(p.db.QueryRowContext(ctx, "SELECT "+itemColumns+" FROM items WHERE item_id = $1", itemID))
+	if err == sql.ErrNoRows {
+		return item, ErrNotFound
+	}
+	return item, err
+}
+
+func (p *Postgres) CreateItem(ctx context.Context, item *models.Item, ownerID int) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback if we don't commit
+
+	// Create item
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO items (name, item_type, content) VALUES ($1, $2, $3::jsonb) RETURNING "+itemColumns,
+		item.Name, item.ItemType, item.Content,
+	).Scan(&item.ItemID, &item.Name, &item.ItemType, &item.Content, &item.Version, &item.CreatedAt, &item.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	// Assign owner role to creator
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_roles (item_id, user_id, role_id, created_by)
+		SELECT $1, $2, role_id, $2 FROM roles WHERE name = 'owner'
+	`, item.ItemID, ownerID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (p *Postgres) UpdateItem(ctx context.Context, itemID int, name string, content json.RawMessage, expectedVersion int) (models.Item, error) {
+	// Compare-and-swap on version so concurrent writers can't both win
+	updated, err := scanItem(p.db.QueryRowContext(ctx, `
+		UPDATE items 
+		SET name = $1, content = COALESCE($2::jsonb, content), version = version + 1, updated_at = NOW()
+		WHERE item_id = $3 AND ($4 = 0 OR version = $4)
+		RETURNING `+itemColumns,
+		name, nullJSON(content), itemID, expectedVersion,
+	))
+	if err == sql.ErrNoRows {
+		return updated, p.itemMissOrConflict(ctx, p.db, itemID)
+	}
+	return updated, err
+}
+
+func (p *Postgres) PatchItem(ctx context.Context, itemID int, patch ContentPatch, expectedVersion int) (models.Item, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Item{}, err
+	}
+	defer tx.Rollback()
+
+	// Hold the row while the patch runs so nobody can change it underneath us
+	current, err := scanItem(tx.QueryRowContext(ctx, "SELECT "+itemColumns+" FROM items WHERE item_id = $1 FOR UPDATE", itemID))
+	if err == sql.ErrNoRows {
+		return current, ErrNotFound
+	}
+	if err != nil {
+		return current, err
+	}
+	if expectedVersion != 0 && current.Version != expectedVersion {
+		return current, &ItemConflictError{Current: current}
+	}
+
+	content, err := patch(current)
+	if err != nil {
+		return current, err
+	}
+
+	updated, err := scanItem(tx.QueryRowContext(ctx, `
+		UPDATE items
+		SET content = $1::jsonb, version = version + 1, updated_at = NOW()
+		WHERE item_id = $2
+		RETURNING `+itemColumns,
+		[]byte(content), itemID,
+	))
+	if err != nil {
+		return updated, err
+	}
+
+	return updated, tx.Commit()
+}
+
+func (p *Postgres) DeleteItem(ctx context.Context, itemID, expectedVersion int) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the item row at the expected version before removing its children
+	var locked int
+	err = tx.QueryRowContext(ctx,
+		"SELECT item_id FROM items WHERE item_id = $1 AND ($2 = 0 OR version = $2) FOR UPDATE",
+		itemID, expectedVersion,
+	).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return p.itemMissOrConflict(ctx, tx, itemID)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Delete associated todos first
+	if _, err := tx.ExecContext(ctx, "DELETE FROM todos WHERE item_id = $1", itemID); err != nil {
+		return err
+	}
+
+	// Delete user roles
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_roles WHERE item_id = $1", itemID); err != nil {
+		return err
+	}
+
+	// Delete the item
+	result, err := tx.ExecContext(ctx, "DELETE FROM items WHERE item_id = $1", itemID)
+	if err != nil {
+		return err
+	}
+	if err := expectRows(result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// itemMissOrConflict explains why a versioned write touched no rows: either
+// the item is gone or someone else changed it first
+func (p *Postgres) itemMissOrConflict(ctx context.Context, q queryRower, itemID int) error {
+	current, err := scanItem(q.QueryRowContext(ctx, "SELECT "+itemColumns+" FROM items WHERE item_id = $1", itemID))
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return &ItemConflictError{Current: current}
+}
+
+const itemColumns = "item_id, name, item_type, content, version, created_at, updated_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanItem(row rowScanner) (models.Item, error) {
+	var item models.Item
+	err := row.Scan(&item.ItemID, &item.Name, &item.ItemType, &item.Content, &item.Version, &item.CreatedAt, &item.UpdatedAt)
+	return item, err
+}
+
+func scanItemWithAccess(row rowScanner) (models.ItemWithAccess, error) {
+	var item models.ItemWithAccess
+	var sharedByEmail sql.NullString // Use sql.NullString for potentially null shared_by_email
+
+	if err := row.Scan(
+		&item.ItemID,
+		&item.Name,
+		&item.ItemType,
+		&item.Content,
+		&item.Version,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+		&item.Role,
+		&sharedByEmail,
+	); err != nil {
+		return item, err
+	}
+
+	// Only set SharedBy if the item was shared (role is not owner)
+	if item.Role != "owner" && sharedByEmail.Valid {
+		item.SharedBy = sharedByEmail.String
+	}
+
+	return item, nil
+}
+
+// nullJSON binds content as NULL when there is none. A nil json.RawMessage
+// would reach the driver as an empty string, which isn't valid jsonb.
+func nullJSON(content json.RawMessage) interface{} {
+	if len(content) == 0 {
+		return nil
+	}
+	return []byte(content)
+}
+
+// expectRows returns ErrNotFound if the statement did not touch any rows
+func expectRows(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike escapes the LIKE wildcards in a user supplied pattern
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
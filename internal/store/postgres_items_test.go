@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/onyeepeace/todo-api/internal/db"
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func TestUpdateItemBindsMissingContentAsNull(t *testing.T) {
+	recorder := &recordingConnector{}
+	p := NewPostgres(sql.OpenDB(recorder))
+
+	p.UpdateItem(context.Background(), 1, "Renamed", nil, 0)
+	p.UpdateItem(context.Background(), 1, "Renamed", json.RawMessage(`[]`), 0)
+
+	var updates []recordedQuery
+	for _, q := range recorder.queries {
+		if strings.Contains(q.query, "UPDATE items") {
+			updates = append(updates, q)
+		}
+	}
+	if len(updates) != 2 {
+		t.Fatalf("got %d updates, want 2", len(updates))
+	}
+	if content := updates[0].args[1]; content != nil {
+		t.Errorf("nil content: sent %#v, want NULL", content)
+	}
+	if content, _ := updates[1].args[1].([]byte); string(content) != `[]` {
+		t.Errorf("content: sent %#v, want []", updates[1].args[1])
+	}
+}
+
+func TestPostgresUpdateItemKeepsContent(t *testing.T) {
+	p, _ := newTestPostgres(t)
+	ctx := context.Background()
+
+	user := models.User{Email: "owner@example.com", Username: "owner"}
+	identity := models.Identity{Provider: "test", Subject: "owner", Email: user.Email, EmailVerified: true}
+	if err := p.CreateUserWithIdentity(ctx, &user, &identity); err != nil {
+		t.Fatal(err)
+	}
+	note := models.Item{Name: "Plans", ItemType: models.ItemTypeNote, Content: json.RawMessage(`{"text": "hi"}`)}
+	if err := p.CreateItem(ctx, &note, user.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	renamed, err := p.UpdateItem(ctx, note.ItemID, "Ideas", nil, note.Version)
+	if err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	var content struct {
+		Text string `json:"text"`
+	}
+	json.Unmarshal(renamed.Content, &content)
+	if renamed.Name != "Ideas" || content.Text != "hi" || renamed.Version != note.Version+1 {
+		t.Errorf("rename: got %q %s version %d", renamed.Name, renamed.Content, renamed.Version)
+	}
+}
+
+func TestItemTypesMigrationKeepsContent(t *testing.T) {
+	_, database := newTestPostgres(t)
+	ctx := context.Background()
+	migrator, err := db.NewMigrator(database)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Go back to just before item types
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := 0
+	for _, status := range statuses {
+		if status.Version >= 7 {
+			steps++
+		}
+	}
+	if _, err := migrator.Down(ctx, steps); err != nil {
+		t.Fatalf("migrating down: %v", err)
+	}
+
+	var listID, noteID int
+	if err := database.QueryRow(`INSERT INTO items (name, content) VALUES ('List', '{"notes": "keep me"}') RETURNING item_id`).Scan(&listID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec(`INSERT INTO todos (item_id, title) VALUES ($1, 'Milk')`, listID); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.QueryRow(`INSERT INTO items (name, content) VALUES ('Note', '{"text": 5, "color": "red"}') RETURNING item_id`).Scan(&noteID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrating up: %v", err)
+	}
+	for _, want := range []struct {
+		itemID                           int
+		itemType, content, legacyContent string
+	}{
+		{listID, models.ItemTypeTodoList, `[]`, `{"notes": "keep me"}`},
+		{noteID, models.ItemTypeNote, `{"text": "5"}`, `{"text": 5, "color": "red"}`},
+	} {
+		var itemType, content, legacyContent string
+		err := database.QueryRow(`SELECT item_type, content::text, legacy_content::text FROM items WHERE item_id = $1`, want.itemID).Scan(&itemType, &content, &legacyContent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if itemType != want.itemType || content != want.content || legacyContent != want.legacyContent {
+			t.Errorf("item %d: got %s %s (was %s), want %s %s (was %s)", want.itemID, itemType, content, legacyContent, want.itemType, want.content, want.legacyContent)
+		}
+	}
+
+	// Rolling back puts the old content back
+	if _, err := migrator.Down(ctx, steps); err != nil {
+		t.Fatalf("migrating down again: %v", err)
+	}
+	var content string
+	if err := database.QueryRow(`SELECT content::text FROM items WHERE item_id = $1`, listID).Scan(&content); err != nil {
+		t.Fatal(err)
+	}
+	if content != `{"notes": "keep me"}` {
+		t.Errorf("after rolling back: got %s", content)
+	}
+}
@@ -0,0 +1,27 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+func (p *Postgres) UseOAuthState(ctx context.Context, state string, expiresAt time.Time) (bool, error) {
+	// A state can't be used once it has expired, so there's no need to
+	// remember it any longer
+	if _, err := p.db.ExecContext(ctx, "DELETE FROM used_oauth_states WHERE expires_at < NOW()"); err != nil {
+		return false, err
+	}
+
+	result, err := p.db.ExecContext(ctx,
+		"INSERT INTO used_oauth_states (state, expires_at) VALUES ($1, $2) ON CONFLICT (state) DO NOTHING",
+		state, expiresAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+const personalTokenColumns = "token_id, token_hash, user_id, name, scopes, expires_at, last_used_at, revoked_at, created_at"
+
+func (p *Postgres) CreatePersonalToken(ctx context.Context, token *models.PersonalToken) error {
+	return p.db.QueryRowContext(ctx, `
+		INSERT INTO personal_tokens (token_hash, user_id, name, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING token_id, created_at
+	`, token.TokenHash, token.UserID, token.Name, pq.Array(token.Scopes), token.ExpiresAt,
+	).Scan(&token.TokenID, &token.CreatedAt)
+}
+
+func (p *Postgres) ListPersonalTokens(ctx context.Context, userID int) ([]models.PersonalToken, error) {
+	rows, err := p.db.QueryContext(ctx,
+		"SELECT "+personalTokenColumns+" FROM personal_tokens WHERE user_id = $1 AND revoked_at IS NULL ORDER BY token_id DESC",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []models.PersonalToken
+	for rows.Next() {
+		token, err := scanPersonalToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (p *Postgres) UsePersonalToken(ctx context.Context, tokenHash string) (models.PersonalToken, error) {
+	token, err := scanPersonalToken(p.db.QueryRowContext(ctx, `
+		UPDATE personal_tokens SET last_used_at = NOW()
+		WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING `+personalTokenColumns,
+		tokenHash,
+	))
+	if err == sql.ErrNoRows {
+		return token, ErrNotFound
+	}
+	return token, err
+}
+
+func (p *Postgres) RevokePersonalToken(ctx context.Context, userID, tokenID int) error {
+	result, err := p.db.ExecContext(ctx,
+		"UPDATE personal_tokens SET revoked_at = NOW() WHERE token_id = $1 AND user_id = $2 AND revoked_at IS NULL",
+		tokenID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanPersonalToken(row rowScanner) (models.PersonalToken, error) {
+	var token models.PersonalToken
+	var lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(
+		&token.TokenID,
+		&token.TokenHash,
+		&token.UserID,
+		&token.Name,
+		pq.Array(&token.Scopes),
+		&token.ExpiresAt,
+		&lastUsedAt,
+		&revokedAt,
+		&token.CreatedAt,
+	)
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return token, err
+}
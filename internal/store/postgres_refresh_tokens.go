@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+const refreshTokenColumns = "token_id, token_hash, user_id, family_id, expires_at, used_at, revoked_at, created_at"
+
+func (p *Postgres) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	// Tokens past their expiry can't be used or reused, so there is nothing
+	// left to detect with them
+	if _, err := p.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE user_id = $1 AND expires_at < NOW()", token.UserID); err != nil {
+		return err
+	}
+
+	created, err := scanRefreshToken(p.db.QueryRowContext(ctx, `
+		INSERT INTO refresh_tokens (token_hash, user_id, family_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+refreshTokenColumns,
+		token.TokenHash, token.UserID, token.FamilyID, token.ExpiresAt,
+	))
+	if err != nil {
+		return err
+	}
+	*token = created
+	return nil
+}
+
+func (p *Postgres) RotateRefreshToken(ctx context.Context, tokenHash string, next *models.RefreshToken) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := scanRefreshToken(tx.QueryRowContext(ctx,
+		"SELECT "+refreshTokenColumns+" FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE", tokenHash))
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if current.RevokedAt != nil || current.ExpiresAt.Before(time.Now()) {
+		return ErrNotFound
+	}
+
+	if current.UsedAt != nil {
+		next.FamilyID = current.FamilyID
+		if err := revokeSessions(ctx, tx, "session_id = $1", current.FamilyID); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		return ErrTokenReused
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET used_at = NOW() WHERE token_id = $1", current.TokenID); err != nil {
+		return err
+	}
+
+	created, err := scanRefreshToken(tx.QueryRowContext(ctx, `
+		INSERT INTO refresh_tokens (token_hash, user_id, family_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+refreshTokenColumns,
+		next.TokenHash, current.UserID, current.FamilyID, next.ExpiresAt,
+	))
+	if err != nil {
+		return err
+	}
+	*next = created
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE sessions SET expires_at = $1 WHERE session_id = $2", next.ExpiresAt, next.FamilyID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (p *Postgres) RevokeRefreshToken(ctx context.Context, tokenHash string) (string, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var sessionID string
+	err = tx.QueryRowContext(ctx, "SELECT family_id FROM refresh_tokens WHERE token_hash = $1", tokenHash).Scan(&sessionID)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := revokeSessions(ctx, tx, "session_id = $1", sessionID); err != nil {
+		return "", err
+	}
+	return sessionID, tx.Commit()
+}
+
+func scanRefreshToken(row rowScanner) (models.RefreshToken, error) {
+	var token models.RefreshToken
+	var usedAt, revokedAt sql.NullTime
+	err := row.Scan(
+		&token.TokenID,
+		&token.TokenHash,
+		&token.UserID,
+		&token.FamilyID,
+		&token.ExpiresAt,
+		&usedAt,
+		&revokedAt,
+		&token.CreatedAt,
+	)
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return token, err
+}
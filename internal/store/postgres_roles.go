@@ -0,0 +1,251 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+// roleQuery selects roles as r with their permissions; it is completed with
+// a WHERE clause or nothing, then roleGrouping
+const roleQuery = `
+	SELECT r.role_id, r.name, COALESCE(r.description, ''), r.built_in,
+		COALESCE(array_agg(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}'),
+		r.created_at
+	FROM roles r
+	LEFT JOIN role_permissions rp ON r.role_id = rp.role_id
+	LEFT JOIN permissions p ON rp.permission_id = p.permission_id
+`
+
+const roleGrouping = " GROUP BY r.role_id ORDER BY r.built_in DESC, r.role_id"
+
+func (p *Postgres) ListRoles(ctx context.Context) ([]models.Role, error) {
+	rows, err := p.db.QueryContext(ctx, roleQuery+roleGrouping)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []models.Role
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+func (p *Postgres) GetRoleByName(ctx context.Context, name string) (models.Role, error) {
+	return p.getRoleByName(ctx, p.db, name)
+}
+
+func (p *Postgres) getRoleByName(ctx context.Context, q queryRower, name string) (models.Role, error) {
+	role, err := scanRole(q.QueryRowContext(ctx, roleQuery+" WHERE r.name = $1"+roleGrouping, name))
+	if err == sql.ErrNoRows {
+		return role, ErrNotFound
+	}
+	return role, err
+}
+
+func (p *Postgres) CreateRole(ctx context.Context, role *models.Role) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var roleID int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO roles (name, description) VALUES ($1, NULLIF($2, ''))
+		ON CONFLICT (name) DO NOTHING
+		RETURNING role_id
+	`, role.Name, role.Description).Scan(&roleID)
+	if err == sql.ErrNoRows {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT $1, permission_id FROM permissions WHERE name = ANY($2)
+	`, roleID, pq.Array(role.Permissions))
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if int(n) != len(role.Permissions) {
+		return ErrNotFound
+	}
+
+	created, err := p.getRoleByName(ctx, tx, role.Name)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	*role = created
+	return nil
+}
+
+func (p *Postgres) DeleteRole(ctx context.Context, name string) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locking the role stops anyone being given it while it's checked
+	roleID, err := lockCustomRole(ctx, tx, name)
+	if err != nil {
+		return err
+	}
+
+	// Invitations and links that no longer work don't count as using it
+	if _, err := tx.ExecContext(ctx, "DELETE FROM item_invitations WHERE role_id = $1 AND expires_at <= NOW()", roleID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM share_links l WHERE l.role_id = $1 AND NOT ("+shareLinkWorks+")", roleID); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM roles r
+		WHERE r.role_id = $1
+		AND NOT EXISTS (SELECT 1 FROM user_roles WHERE role_id = r.role_id)
+		AND NOT EXISTS (SELECT 1 FROM item_invitations WHERE role_id = r.role_id)
+		AND NOT EXISTS (SELECT 1 FROM share_links WHERE role_id = r.role_id)
+	`, roleID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrConflict
+	}
+
+	return tx.Commit()
+}
+
+func (p *Postgres) AddRolePermission(ctx context.Context, role, permission string) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	roleID, err := lockCustomRole(ctx, tx, role)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT $1, permission_id FROM permissions WHERE name = $2
+		ON CONFLICT DO NOTHING
+	`, roleID, permission)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		// Either it was already granted or the permission doesn't exist
+		var exists bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM permissions WHERE name = $1)", permission).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotFound
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (p *Postgres) RemoveRolePermission(ctx context.Context, role, permission string) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	roleID, err := lockCustomRole(ctx, tx, role)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM role_permissions
+		WHERE role_id = $1 AND permission_id = (SELECT permission_id FROM permissions WHERE name = $2)
+	`, roleID, permission)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+
+	return tx.Commit()
+}
+
+func (p *Postgres) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	rows, err := p.db.QueryContext(ctx, "SELECT permission_id, name, COALESCE(description, '') FROM permissions ORDER BY permission_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []models.Permission
+	for rows.Next() {
+		var permission models.Permission
+		if err := rows.Scan(&permission.PermissionID, &permission.Name, &permission.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, rows.Err()
+}
+
+// lockCustomRole locks the named role for the rest of the transaction and
+// returns its ID. Built-in roles give ErrConflict.
+func lockCustomRole(ctx context.Context, tx *sql.Tx, name string) (int, error) {
+	var roleID int
+	var builtIn bool
+	err := tx.QueryRowContext(ctx, "SELECT role_id, built_in FROM roles WHERE name = $1 FOR UPDATE", name).Scan(&roleID, &builtIn)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if builtIn {
+		return 0, ErrConflict
+	}
+	return roleID, nil
+}
+
+func scanRole(row rowScanner) (models.Role, error) {
+	var role models.Role
+	err := row.Scan(
+		&role.RoleID,
+		&role.Name,
+		&role.Description,
+		&role.BuiltIn,
+		pq.Array(&role.Permissions),
+		&role.CreatedAt,
+	)
+	return role, err
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5"
+
+// htmlEscaped wraps a SQL text expression so it escapes the way
+// html.EscapeString does. ts_headline copies its input into the snippet as
+// is, so it is given escaped text and only the <mark> tags are real HTML.
+func htmlEscaped(expr string) string {
+	return `replace(replace(replace(replace(replace(` + expr + `, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;')`
+}
+
+func (p *Postgres) Search(ctx context.Context, userID int, query string, limit int) ([]models.SearchResult, error) {
+	// Only items where the user's role grants can_view are searched
+	rows, err := p.db.QueryContext(ctx, `
+		WITH q AS (
+			SELECT websearch_to_tsquery('english', $2) AS query
+		),
+		visible AS (
+			SELECT ur.item_id
+			FROM user_roles ur
+			JOIN role_permissions rp ON ur.role_id = rp.role_id
+			JOIN permissions p ON rp.permission_id = p.permission_id
+			WHERE ur.user_id = $1 AND p.name = 'can_view'
+		)
+		SELECT 'item' AS type, i.item_id, NULL::int AS todo_id, i.name AS title,
+			ts_rank(i.search_vector, q.query) AS rank,
+			ts_headline('english', `+htmlEscaped(`
+				i.name || ' ' || coalesce((
+					SELECT string_agg(v #>> '{}', ' ')
+					FROM jsonb_path_query(i.content, 'strict $.**') AS v
+					WHERE jsonb_typeof(v) = 'string'
+				), '')`)+`,
+				q.query, '`+headlineOptions+`') AS snippet
+		FROM items i
+		JOIN visible ON visible.item_id = i.item_id
+		CROSS JOIN q
+		WHERE i.search_vector @@ q.query
+
+		UNION ALL
+
+		SELECT 'todo', t.item_id, t.todo_id, t.title,
+			ts_rank(t.search_vector, q.query),
+			ts_headline('english', `+htmlEscaped("t.title")+`, q.query, '`+headlineOptions+`')
+		FROM todos t
+		JOIN visible ON visible.item_id = t.item_id
+		CROSS JOIN q
+		WHERE t.search_vector @@ q.query
+
+		ORDER BY rank DESC, item_id, todo_id NULLS FIRST
+		LIMIT $3
+	`, userID, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []models.SearchResult{}
+	for rows.Next() {
+		var result models.SearchResult
+		var todoID sql.NullInt64
+		if err := rows.Scan(
+			&result.Type,
+			&result.ItemID,
+			&todoID,
+			&result.Title,
+			&result.Rank,
+			&result.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		result.TodoID = int(todoID.Int64)
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func (p *Postgres) CreateSession(ctx context.Context, session *models.Session) error {
+	// Expired sessions can't be used any more; their refresh tokens go with them
+	if _, err := p.db.ExecContext(ctx, "DELETE FROM sessions WHERE user_id = $1 AND expires_at < NOW()", session.UserID); err != nil {
+		return err
+	}
+
+	return p.db.QueryRowContext(ctx, `
+		INSERT INTO sessions (session_id, user_id, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING created_at
+	`, session.SessionID, session.UserID, session.ExpiresAt).Scan(&session.CreatedAt)
+}
+
+func (p *Postgres) SessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	var active bool
+	err := p.db.QueryRowContext(ctx,
+		"SELECT revoked_at IS NULL AND expires_at > NOW() FROM sessions WHERE session_id = $1", sessionID,
+	).Scan(&active)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !active, nil
+}
+
+func (p *Postgres) RevokeSession(ctx context.Context, sessionID string) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := revokeSessions(ctx, tx, "session_id = $1", sessionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) RevokeUserSessions(ctx context.Context, userID int) ([]string, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		"SELECT session_id FROM sessions WHERE user_id = $1 AND revoked_at IS NULL FOR UPDATE", userID)
+	if err != nil {
+		return nil, err
+	}
+	var sessionIDs []string
+	for rows.Next() {
+		var sessionID string
+		if err := rows.Scan(&sessionID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := revokeSessions(ctx, tx, "user_id = $1", userID); err != nil {
+		return nil, err
+	}
+	return sessionIDs, tx.Commit()
+}
+
+// revokeSessions revokes the matching sessions and their refresh tokens
+func revokeSessions(ctx context.Context, tx *sql.Tx, where string, arg interface{}) error {
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE revoked_at IS NULL
+		  AND family_id IN (SELECT session_id FROM sessions WHERE `+where+`)
+	`, arg); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE revoked_at IS NULL AND "+where, arg)
+	return err
+}
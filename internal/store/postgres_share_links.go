@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+// shareLinkColumns are selected from share_links as l joined with roles as r
+const shareLinkColumns = "l.link_id, l.item_id, l.token_hash, r.name, COALESCE(l.password_hash, ''), l.expires_at, l.max_uses, l.use_count, l.created_by, l.created_at"
+
+// shareLinkWorks is the condition for a link that still works
+const shareLinkWorks = "l.revoked_at IS NULL AND (l.expires_at IS NULL OR l.expires_at > NOW()) AND (l.max_uses IS NULL OR l.use_count < l.max_uses)"
+
+func (p *Postgres) CreateShareLink(ctx context.Context, link *models.ShareLink) error {
+	err := p.db.QueryRowContext(ctx, `
+		INSERT INTO share_links (item_id, token_hash, role_id, password_hash, expires_at, max_uses, created_by)
+		SELECT $1, $2, role_id, NULLIF($3, ''), $4, $5, $6 FROM roles WHERE name = $7
+		RETURNING link_id, created_at
+	`, link.ItemID, link.TokenHash, link.PasswordHash, link.ExpiresAt, link.MaxUses, link.CreatedBy, link.Role,
+	).Scan(&link.LinkID, &link.CreatedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("unknown role %q", link.Role)
+	}
+	link.PasswordProtected = link.PasswordHash != ""
+	return err
+}
+
+func (p *Postgres) ListShareLinks(ctx context.Context, itemID int) ([]models.ShareLink, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT `+shareLinkColumns+`
+		FROM share_links l
+		JOIN roles r ON l.role_id = r.role_id
+		WHERE l.item_id = $1 AND l.revoked_at IS NULL
+		ORDER BY l.link_id DESC
+	`, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []models.ShareLink
+	for rows.Next() {
+		link, err := scanShareLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
+}
+
+func (p *Postgres) GetShareLink(ctx context.Context, tokenHash string) (models.ShareLink, error) {
+	link, err := scanShareLink(p.db.QueryRowContext(ctx, `
+		SELECT `+shareLinkColumns+`
+		FROM share_links l
+		JOIN roles r ON l.role_id = r.role_id
+		WHERE l.token_hash = $1 AND `+shareLinkWorks,
+		tokenHash,
+	))
+	if err == sql.ErrNoRows {
+		return link, ErrNotFound
+	}
+	return link, err
+}
+
+func (p *Postgres) UseShareLink(ctx context.Context, linkID int) error {
+	// Counting the use in the same statement that checks the limit means
+	// concurrent requests can't use a link more than max_uses times
+	result, err := p.db.ExecContext(ctx,
+		"UPDATE share_links l SET use_count = l.use_count + 1 WHERE l.link_id = $1 AND "+shareLinkWorks,
+		linkID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *Postgres) RevokeShareLink(ctx context.Context, itemID, linkID int) error {
+	result, err := p.db.ExecContext(ctx,
+		"UPDATE share_links SET revoked_at = NOW() WHERE item_id = $1 AND link_id = $2 AND revoked_at IS NULL",
+		itemID, linkID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanShareLink(row rowScanner) (models.ShareLink, error) {
+	var link models.ShareLink
+	var maxUses sql.NullInt64
+	var expiresAt sql.NullTime
+	err := row.Scan(
+		&link.LinkID,
+		&link.ItemID,
+		&link.TokenHash,
+		&link.Role,
+		&link.PasswordHash,
+		&expiresAt,
+		&maxUses,
+		&link.UseCount,
+		&link.CreatedBy,
+		&link.CreatedAt,
+	)
+	if expiresAt.Valid {
+		link.ExpiresAt = &expiresAt.Time
+	}
+	if maxUses.Valid {
+		n := int(maxUses.Int64)
+		link.MaxUses = &n
+	}
+	link.PasswordProtected = link.PasswordHash != ""
+	return link, err
+}
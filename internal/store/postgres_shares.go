@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+func (p *Postgres) ShareItem(ctx context.Context, itemID, userID int, role string, sharedBy int) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Verify user exists
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1)", userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+
+	// Get role ID
+	var roleID int
+	err = tx.QueryRowContext(ctx, "SELECT role_id FROM roles WHERE name = $1", role).Scan(&roleID)
+	if err != nil {
+		return err
+	}
+
+	// Insert the role, or replace the one the user already has
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_roles (item_id, user_id, role_id, created_by) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (item_id, user_id) DO UPDATE SET role_id = EXCLUDED.role_id, created_by = EXCLUDED.created_by
+	`, itemID, userID, roleID, sharedBy)
+	if err != nil {
+		return err
+	}
+
+	return ownerError(tx.Commit())
+}
+
+func (p *Postgres) ItemUserIDs(ctx context.Context, itemID int) ([]int, error) {
+	rows, err := p.db.QueryContext(ctx, "SELECT user_id FROM user_roles WHERE item_id = $1 ORDER BY user_id", itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
+func (p *Postgres) HasPermission(ctx context.Context, userID, itemID int, permission string) (bool, error) {
+	var exists bool
+	err := p.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM user_roles ur
+			JOIN roles r ON ur.role_id = r.role_id
+			JOIN role_permissions rp ON r.role_id = rp.role_id
+			JOIN permissions p ON rp.permission_id = p.permission_id
+			WHERE ur.user_id = $1
+			AND ur.item_id = $2
+			AND p.name = $3
+		)
+	`, userID, itemID, permission).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return exists, err
+}
+
+func (p *Postgres) ListCollaborators(ctx context.Context, itemID int) ([]models.Collaborator, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT u.user_id, u.email, u.username, r.name, COALESCE(ur.created_by, 0), ur.created_at
+		FROM user_roles ur
+		JOIN users u ON ur.user_id = u.user_id
+		JOIN roles r ON ur.role_id = r.role_id
+		WHERE ur.item_id = $1
+		ORDER BY ur.created_at, u.user_id
+	`, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var collaborators []models.Collaborator
+	for rows.Next() {
+		var c models.Collaborator
+		if err := rows.Scan(&c.UserID, &c.Email, &c.Username, &c.Role, &c.SharedBy, &c.SharedAt); err != nil {
+			return nil, err
+		}
+		collaborators = append(collaborators, c)
+	}
+
+	return collaborators, rows.Err()
+}
+
+func (p *Postgres) GetRole(ctx context.Context, itemID, userID int) (string, error) {
+	var role string
+	err := p.db.QueryRowContext(ctx, `
+		SELECT r.name FROM user_roles ur
+		JOIN roles r ON ur.role_id = r.role_id
+		WHERE ur.item_id = $1 AND ur.user_id = $2
+	`, itemID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	return role, err
+}
+
+func (p *Postgres) ChangeRole(ctx context.Context, itemID, userID int, role string) error {
+	result, err := p.db.ExecContext(ctx, `
+		UPDATE user_roles SET role_id = (SELECT role_id FROM roles WHERE name = $3)
+		WHERE item_id = $1 AND user_id = $2
+	`, itemID, userID, role)
+	if err != nil {
+		return ownerError(err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *Postgres) RevokeAccess(ctx context.Context, itemID, userID int) error {
+	result, err := p.db.ExecContext(ctx, "DELETE FROM user_roles WHERE item_id = $1 AND user_id = $2", itemID, userID)
+	if err != nil {
+		return ownerError(err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *Postgres) RoleHasPermission(ctx context.Context, role, permission string) (bool, error) {
+	var exists bool
+	err := p.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM roles r
+			JOIN role_permissions rp ON r.role_id = rp.role_id
+			JOIN permissions p ON rp.permission_id = p.permission_id
+			WHERE r.name = $1 AND p.name = $2
+		)
+	`, role, permission).Scan(&exists)
+	return exists, err
+}
+
+// ownerError turns the item_has_owner trigger's error into ErrLastOwner. The
+// trigger is deferred, so the error comes from the end of the transaction.
+func ownerError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Constraint == "item_has_owner" {
+		return ErrLastOwner
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/db"
+)
+
+// newTestPostgres returns a Postgres store on a fresh, fully migrated schema
+// in the database at TEST_DATABASE_URL. Tests that need it are skipped when
+// it isn't set.
+func newTestPostgres(t *testing.T) (*Postgres, *sql.DB) {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	separator := " "
+	if strings.Contains(url, "://") {
+		separator = "?"
+		if strings.Contains(url, "?") {
+			separator = "&"
+		}
+	}
+	database, err := sql.Open("postgres", url+separator+"search_path="+schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	migrator, err := db.NewMigrator(database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	return NewPostgres(database), database
+}
+
+// recordedQuery is a statement a recordingConnector was asked to run
+type recordedQuery struct {
+	query string
+	args  []driver.Value
+}
+
+// recordingConnector is a database/sql driver that runs nothing. It records
+// the arguments statements would have been sent to Postgres with, after
+// database/sql converted them, and every query comes back empty.
+type recordingConnector struct {
+	queries []recordedQuery
+}
+
+func (c *recordingConnector) Connect(ctx context.Context) (driver.Conn, error) { return c, nil }
+func (c *recordingConnector) Driver() driver.Driver                            { return c }
+func (c *recordingConnector) Open(name string) (driver.Conn, error)            { return c, nil }
+func (c *recordingConnector) Close() error                                     { return nil }
+func (c *recordingConnector) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions aren't recorded")
+}
+
+func (c *recordingConnector) Prepare(query string) (driver.Stmt, error) {
+	return &recordingStmt{c: c, query: query}, nil
+}
+
+type recordingStmt struct {
+	c     *recordingConnector
+	query string
+}
+
+func (s *recordingStmt) Close() error  { return nil }
+func (s *recordingStmt) NumInput() int { return -1 }
+
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.c.queries = append(s.c.queries, recordedQuery{s.query, args})
+	return driver.RowsAffected(0), nil
+}
+
+func (s *recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.c.queries = append(s.c.queries, recordedQuery{s.query, args})
+	return noRows{}, nil
+}
+
+type noRows struct{}
+
+func (noRows) Columns() []string              { return nil }
+func (noRows) Close() error                   { return nil }
+func (noRows) Next(dest []driver.Value) error { return io.EOF }
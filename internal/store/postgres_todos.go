@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+const todoColumns = "todo_id, item_id, title, done, version, created_at, updated_at"
+
+func (p *Postgres) ListTodos(ctx context.Context, itemID int) ([]models.Todo, error) {
+	rows, err := p.db.QueryContext(ctx, "SELECT "+todoColumns+" FROM todos WHERE item_id = $1 ORDER BY todo_id", itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var todos []models.Todo
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+
+	return todos, rows.Err()
+}
+
+func (p *Postgres) GetTodo(ctx context.Context, itemID, todoID int) (models.Todo, error) {
+	row := p.db.QueryRowContext(ctx, "SELECT "+todoColumns+" FROM todos WHERE item_id = $1 AND todo_id = $2", itemID, todoID)
+	todo, err := scanTodo(row)
+	if err == sql.ErrNoRows {
+		return todo, ErrNotFound
+	}
+	return todo, err
+}
+
+func (p *Postgres) CreateTodo(ctx context.Context, todo *models.Todo) error {
+	row := p.db.QueryRowContext(ctx,
+		"INSERT INTO todos (item_id, title, done) VALUES ($1, $2, $3) RETURNING "+todoColumns,
+		todo.ItemID, todo.Title, todo.Done,
+	)
+	created, err := scanTodo(row)
+	if err != nil {
+		return err
+	}
+	*todo = created
+	return nil
+}
+
+func (p *Postgres) UpdateTodo(ctx context.Context, todo *models.Todo, expectedVersion int) error {
+	query := `UPDATE todos SET title = $1, done = $2, version = version + 1, updated_at = NOW()
+              WHERE item_id = $3 AND todo_id = $4 AND ($5 = 0 OR version = $5)
+              RETURNING ` + todoColumns
+	row := p.db.QueryRowContext(ctx, query, todo.Title, todo.Done, todo.ItemID, todo.TodoID, expectedVersion)
+	updated, err := scanTodo(row)
+	if err == sql.ErrNoRows {
+		return p.todoMissOrConflict(ctx, todo.ItemID, todo.TodoID)
+	}
+	if err != nil {
+		return err
+	}
+	*todo = updated
+	return nil
+}
+
+func (p *Postgres) MarkTodoDone(ctx context.Context, itemID, todoID, expectedVersion int) (models.Todo, error) {
+	query := `UPDATE todos SET done = true, version = version + 1, updated_at = NOW()
+              WHERE item_id = $1 AND todo_id = $2 AND ($3 = 0 OR version = $3)
+              RETURNING ` + todoColumns
+	todo, err := scanTodo(p.db.QueryRowContext(ctx, query, itemID, todoID, expectedVersion))
+	if err == sql.ErrNoRows {
+		return todo, p.todoMissOrConflict(ctx, itemID, todoID)
+	}
+	return todo, err
+}
+
+func (p *Postgres) DeleteTodo(ctx context.Context, itemID, todoID, expectedVersion int) error {
+	result, err := p.db.ExecContext(ctx,
+		"DELETE FROM todos WHERE item_id = $1 AND todo_id = $2 AND ($3 = 0 OR version = $3)",
+		itemID, todoID, expectedVersion,
+	)
+	if err != nil {
+		return err
+	}
+	if err := expectRows(result); err == ErrNotFound {
+		return p.todoMissOrConflict(ctx, itemID, todoID)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// todoMissOrConflict explains why a versioned write touched no rows: either
+// the todo is gone or someone else changed it first
+func (p *Postgres) todoMissOrConflict(ctx context.Context, itemID, todoID int) error {
+	var exists bool
+	err := p.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM todos WHERE item_id = $1 AND todo_id = $2)",
+		itemID, todoID,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrPreconditionFailed
+	}
+	return ErrNotFound
+}
+
+func scanTodo(row rowScanner) (models.Todo, error) {
+	var todo models.Todo
+	err := row.Scan(&todo.TodoID, &todo.ItemID, &todo.Title, &todo.Done, &todo.Version, &todo.CreatedAt, &todo.UpdatedAt)
+	return todo, err
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+const transferColumns = "item_id, from_user_id, to_user_id, created_at"
+
+func (p *Postgres) OfferTransfer(ctx context.Context, transfer *models.OwnershipTransfer) error {
+	err := p.db.QueryRowContext(ctx, `
+		INSERT INTO ownership_transfers (item_id, from_user_id, to_user_id)
+		SELECT $1, $2, user_id FROM users WHERE user_id = $3
+		ON CONFLICT (item_id) DO UPDATE
+		SET from_user_id = EXCLUDED.from_user_id, to_user_id = EXCLUDED.to_user_id, created_at = CURRENT_TIMESTAMP
+		RETURNING created_at
+	`, transfer.ItemID, transfer.FromUserID, transfer.ToUserID).Scan(&transfer.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (p *Postgres) GetTransfer(ctx context.Context, itemID int) (models.OwnershipTransfer, error) {
+	transfer, err := scanTransfer(p.db.QueryRowContext(ctx,
+		"SELECT "+transferColumns+" FROM ownership_transfers WHERE item_id = $1", itemID,
+	))
+	if err == sql.ErrNoRows {
+		return transfer, ErrNotFound
+	}
+	return transfer, err
+}
+
+func (p *Postgres) ListTransfersTo(ctx context.Context, userID int) ([]models.OwnershipTransfer, error) {
+	rows, err := p.db.QueryContext(ctx,
+		"SELECT "+transferColumns+" FROM ownership_transfers WHERE to_user_id = $1 ORDER BY item_id", userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []models.OwnershipTransfer
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	return transfers, rows.Err()
+}
+
+func (p *Postgres) AcceptTransfer(ctx context.Context, itemID, toUserID int) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The offer only stands while the user who made it is still an owner
+	var fromUserID int
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM ownership_transfers t
+		USING user_roles ur, roles r
+		WHERE t.item_id = $1 AND t.to_user_id = $2
+		  AND ur.item_id = t.item_id AND ur.user_id = t.from_user_id
+		  AND ur.role_id = r.role_id AND r.name = 'owner'
+		RETURNING t.from_user_id
+	`, itemID, toUserID).Scan(&fromUserID)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO user_roles (item_id, user_id, role_id, created_by)
+		SELECT $1, $2, role_id, $3 FROM roles WHERE name = 'owner'
+		ON CONFLICT (item_id, user_id) DO UPDATE SET role_id = EXCLUDED.role_id, created_by = EXCLUDED.created_by
+	`, itemID, toUserID, fromUserID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE user_roles SET role_id = (SELECT role_id FROM roles WHERE name = 'editor')
+		WHERE item_id = $1 AND user_id = $2
+	`, itemID, fromUserID); err != nil {
+		return err
+	}
+
+	return ownerError(tx.Commit())
+}
+
+func (p *Postgres) DeleteTransfer(ctx context.Context, itemID int) error {
+	result, err := p.db.ExecContext(ctx, "DELETE FROM ownership_transfers WHERE item_id = $1", itemID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanTransfer(row rowScanner) (models.OwnershipTransfer, error) {
+	var transfer models.OwnershipTransfer
+	err := row.Scan(&transfer.ItemID, &transfer.FromUserID, &transfer.ToUserID, &transfer.CreatedAt)
+	return transfer, err
+}
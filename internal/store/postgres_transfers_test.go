@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+// newPostgresUser adds a user with a verified email and returns their ID
+func newPostgresUser(t *testing.T, p *Postgres, name string) int {
+	t.Helper()
+	user := models.User{Email: name + "@example.com", Username: name}
+	identity := models.Identity{Provider: "test", Subject: name, Email: user.Email, EmailVerified: true}
+	if err := p.CreateUserWithIdentity(context.Background(), &user, &identity); err != nil {
+		t.Fatalf("creating user %s: %v", name, err)
+	}
+	return user.UserID
+}
+
+// newPostgresList returns the ID of a todo list owned by owner
+func newPostgresList(t *testing.T, p *Postgres, owner int) int {
+	t.Helper()
+	item := models.Item{Name: "Groceries", ItemType: models.ItemTypeTodoList, Content: json.RawMessage(`[]`)}
+	if err := p.CreateItem(context.Background(), &item, owner); err != nil {
+		t.Fatal(err)
+	}
+	return item.ItemID
+}
+
+func TestPostgresAcceptTransfer(t *testing.T) {
+	p, _ := newTestPostgres(t)
+	ctx := context.Background()
+	owner, recipient, bystander := newPostgresUser(t, p, "owner"), newPostgresUser(t, p, "recipient"), newPostgresUser(t, p, "bystander")
+	itemID := newPostgresList(t, p, owner)
+
+	if err := p.OfferTransfer(ctx, &models.OwnershipTransfer{ItemID: itemID, FromUserID: owner, ToUserID: 999999}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("offer to a missing user: got %v", err)
+	}
+	if err := p.OfferTransfer(ctx, &models.OwnershipTransfer{ItemID: itemID, FromUserID: owner, ToUserID: recipient}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AcceptTransfer(ctx, itemID, bystander); !errors.Is(err, ErrNotFound) {
+		t.Errorf("someone else accepting: got %v", err)
+	}
+	if err := p.AcceptTransfer(ctx, itemID, recipient); err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+
+	for userID, want := range map[int]string{recipient: "owner", owner: "editor"} {
+		if role, err := p.GetRole(ctx, itemID, userID); err != nil || role != want {
+			t.Errorf("user %d: got role %q, %v, want %q", userID, role, err, want)
+		}
+	}
+	if _, err := p.GetTransfer(ctx, itemID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("offer after accepting: got %v", err)
+	}
+}
+
+func TestPostgresTransferLapsesWhenOwnerIsDemoted(t *testing.T) {
+	p, _ := newTestPostgres(t)
+	ctx := context.Background()
+	owner, coOwner, recipient := newPostgresUser(t, p, "owner"), newPostgresUser(t, p, "co-owner"), newPostgresUser(t, p, "recipient")
+	itemID := newPostgresList(t, p, owner)
+	if err := p.ShareItem(ctx, itemID, coOwner, "owner", owner); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.OfferTransfer(ctx, &models.OwnershipTransfer{ItemID: itemID, FromUserID: owner, ToUserID: recipient}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ChangeRole(ctx, itemID, owner, "viewer"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AcceptTransfer(ctx, itemID, recipient); !errors.Is(err, ErrNotFound) {
+		t.Errorf("accepting an offer from someone no longer an owner: got %v", err)
+	}
+	if _, err := p.GetRole(ctx, itemID, recipient); !errors.Is(err, ErrNotFound) {
+		t.Errorf("recipient got a role: %v", err)
+	}
+}
+
+func TestPostgresItemKeepsAnOwner(t *testing.T) {
+	p, _ := newTestPostgres(t)
+	ctx := context.Background()
+	owner, coOwner := newPostgresUser(t, p, "owner"), newPostgresUser(t, p, "co-owner")
+	itemID := newPostgresList(t, p, owner)
+
+	if err := p.ChangeRole(ctx, itemID, owner, "editor"); !errors.Is(err, ErrLastOwner) {
+		t.Errorf("demoting the last owner: got %v", err)
+	}
+	if err := p.RevokeAccess(ctx, itemID, owner); !errors.Is(err, ErrLastOwner) {
+		t.Errorf("removing the last owner: got %v", err)
+	}
+
+	if err := p.ShareItem(ctx, itemID, coOwner, "owner", owner); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.RevokeAccess(ctx, itemID, owner); err != nil {
+		t.Errorf("removing an owner with a co-owner: %v", err)
+	}
+	if role, err := p.GetRole(ctx, itemID, coOwner); err != nil || role != "owner" {
+		t.Errorf("co-owner: got %q, %v", role, err)
+	}
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+const userColumns = "user_id, email, username, created_at, updated_at"
+
+func (p *Postgres) GetUser(ctx context.Context, userID int) (models.User, error) {
+	return p.getUser(ctx, "user_id = $1", userID)
+}
+
+func (p *Postgres) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	return p.getUser(ctx, "email = $1", email)
+}
+
+func (p *Postgres) IsAdmin(ctx context.Context, userID int) (bool, error) {
+	var admin bool
+	err := p.db.QueryRowContext(ctx, "SELECT is_admin FROM users WHERE user_id = $1", userID).Scan(&admin)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return admin, err
+}
+
+func (p *Postgres) getUser(ctx context.Context, where string, args ...interface{}) (models.User, error) {
+	user, err := scanUser(p.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE "+where, args...))
+	if err == sql.ErrNoRows {
+		return user, ErrNotFound
+	}
+	return user, err
+}
+
+func scanUser(row rowScanner) (models.User, error) {
+	var user models.User
+	err := row.Scan(
+		&user.UserID,
+		&user.Email,
+		&user.Username,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	return user, err
+}
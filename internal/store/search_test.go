@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+// searchStore is what TestSearchSnippetsAreEscaped needs from a store
+type searchStore interface {
+	CreateUserWithIdentity(ctx context.Context, user *models.User, identity *models.Identity) error
+	CreateItem(ctx context.Context, item *models.Item, ownerID int) error
+	Search(ctx context.Context, userID int, query string, limit int) ([]models.SearchResult, error)
+}
+
+func TestSearchSnippetsAreEscaped(t *testing.T) {
+	stores := map[string]func(t *testing.T) searchStore{
+		"memory": func(t *testing.T) searchStore { return NewMemory() },
+		"postgres": func(t *testing.T) searchStore {
+			p, _ := newTestPostgres(t)
+			return p
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			ctx := context.Background()
+
+			user := models.User{Email: "owner@example.com", Username: "owner"}
+			identity := models.Identity{Provider: "test", Subject: "owner", Email: user.Email, EmailVerified: true}
+			if err := s.CreateUserWithIdentity(ctx, &user, &identity); err != nil {
+				t.Fatal(err)
+			}
+			content, _ := json.Marshal(map[string]string{"text": `pancake <img src=x onerror="alert(1)"> & 'syrup'`})
+			note := models.Item{Name: "Breakfast", ItemType: models.ItemTypeNote, Content: content}
+			if err := s.CreateItem(ctx, &note, user.UserID); err != nil {
+				t.Fatal(err)
+			}
+
+			results, err := s.Search(ctx, user.UserID, "pancake", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+			snippet := results[0].Snippet
+			if !strings.Contains(snippet, "<mark>") {
+				t.Errorf("snippet %q doesn't mark the match", snippet)
+			}
+			if strings.Contains(strings.NewReplacer("<mark>", "", "</mark>", "").Replace(snippet), "<") {
+				t.Errorf("snippet %q has HTML besides <mark>", snippet)
+			}
+			if !strings.Contains(snippet, "&lt;img") || !strings.Contains(snippet, "&amp;") {
+				t.Errorf("snippet %q isn't escaped", snippet)
+			}
+		})
+	}
+}
@@ -0,0 +1,383 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/onyeepeace/todo-api/internal/models"
+)
+
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrCompacted means the requested updates are no longer in the log
+	ErrCompacted = errors.New("compacted")
+	// ErrTokenReused means an already rotated refresh token was presented
+	ErrTokenReused = errors.New("refresh token reused")
+	// ErrLastIdentity means removing the identity would leave its user with
+	// no way to sign in
+	ErrLastIdentity = errors.New("last identity")
+	// ErrLastOwner means the change would leave an item without an owner
+	ErrLastOwner = errors.New("last owner")
+)
+
+// ItemStore persists items and the caller's view of them
+type ItemStore interface {
+	// ListItems returns one page of the items the user has any role on
+	ListItems(ctx context.Context, query ItemQuery) ([]models.ItemWithAccess, error)
+	// GetItem returns an item regardless of who is asking
+	GetItem(ctx context.Context, itemID int) (models.Item, error)
+	// GetItemForUser returns an item together with the user's role on it
+	GetItemForUser(ctx context.Context, itemID, userID int) (models.ItemWithAccess, error)
+	// CreateItem inserts the item and makes ownerID its owner
+	CreateItem(ctx context.Context, item *models.Item, ownerID int) error
+	// UpdateItem replaces the name and content of an item and bumps its
+	// version; a nil content keeps the current content. When
+	// expectedVersion is not 0 the update only applies if the item is still
+	// at that version, otherwise an *ItemConflictError is returned.
+	UpdateItem(ctx context.Context, itemID int, name string, content json.RawMessage, expectedVersion int) (models.Item, error)
+	// PatchItem locks the item, runs patch against its current content and
+	// saves the result, with the same expectedVersion semantics as UpdateItem.
+	// Errors from patch are returned unchanged.
+	PatchItem(ctx context.Context, itemID int, patch ContentPatch, expectedVersion int) (models.Item, error)
+	// DeleteItem removes an item along with its todos and roles, with the
+	// same expectedVersion semantics as UpdateItem
+	DeleteItem(ctx context.Context, itemID, expectedVersion int) error
+}
+
+// ContentPatch computes an item's new content from its current state
+type ContentPatch func(current models.Item) (json.RawMessage, error)
+
+// ItemConflictError is returned when a versioned item write loses the race.
+// It carries the server's current copy so clients can merge.
+type ItemConflictError struct {
+	Current models.Item
+}
+
+func (e *ItemConflictError) Error() string {
+	return fmt.Sprintf("item %d is at version %d", e.Current.ItemID, e.Current.Version)
+}
+
+// Is makes errors.Is(err, ErrConflict) match
+func (e *ItemConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// Sort orders supported by ItemStore.ListItems
+const (
+	SortByName      = "name"
+	SortByCreatedAt = "created_at"
+	SortByUpdatedAt = "updated_at"
+)
+
+// ItemQuery filters, sorts and pages the items returned by ListItems
+type ItemQuery struct {
+	UserID int
+
+	// Roles limits results to items where the user holds one of these roles
+	Roles []string
+	// SharedBy limits results to items shared with the user by this email
+	SharedBy      string
+	NamePrefix    string
+	ItemType      string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+
+	Sort string // one of the SortBy constants, defaults to SortByCreatedAt
+	Desc bool
+
+	// After resumes the listing after the item the cursor points at
+	After *ItemCursor
+	// Limit is the maximum number of items to return, 0 means no limit
+	Limit int
+}
+
+// ItemCursor is the sort key of the last item on a page
+type ItemCursor struct {
+	Name   string    `json:"n,omitempty"`
+	Time   time.Time `json:"t,omitempty"`
+	ItemID int       `json:"id"`
+}
+
+// CursorFor returns the cursor that resumes a listing after item
+func (q ItemQuery) CursorFor(item models.ItemWithAccess) *ItemCursor {
+	cursor := &ItemCursor{ItemID: item.ItemID}
+	switch q.Sort {
+	case SortByName:
+		cursor.Name = item.Name
+	case SortByUpdatedAt:
+		cursor.Time = item.UpdatedAt
+	default:
+		cursor.Time = item.CreatedAt
+	}
+	return cursor
+}
+
+// TodoStore persists the todos belonging to an item
+type TodoStore interface {
+	ListTodos(ctx context.Context, itemID int) ([]models.Todo, error)
+	GetTodo(ctx context.Context, itemID, todoID int) (models.Todo, error)
+	CreateTodo(ctx context.Context, todo *models.Todo) error
+	// The mutations below bump the todo's version. When expectedVersion is
+	// not 0 they only apply if the todo is still at that version, otherwise
+	// ErrPreconditionFailed is returned.
+	UpdateTodo(ctx context.Context, todo *models.Todo, expectedVersion int) error
+	MarkTodoDone(ctx context.Context, itemID, todoID, expectedVersion int) (models.Todo, error)
+	DeleteTodo(ctx context.Context, itemID, todoID, expectedVersion int) error
+}
+
+// UserStore persists user accounts
+type UserStore interface {
+	GetUser(ctx context.Context, userID int) (models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (models.User, error)
+	// IsAdmin reports whether the user can manage roles
+	IsAdmin(ctx context.Context, userID int) (bool, error)
+}
+
+// IdentityStore persists the identity provider accounts users sign in with
+type IdentityStore interface {
+	GetIdentity(ctx context.Context, provider, subject string) (models.Identity, error)
+	ListIdentities(ctx context.Context, userID int) ([]models.Identity, error)
+	// GetUserByVerifiedEmail finds the user with an identity whose provider
+	// verified the email address
+	GetUserByVerifiedEmail(ctx context.Context, email string) (models.User, error)
+	// CreateUserWithIdentity signs up a new user. ErrConflict means the email
+	// or username is taken.
+	CreateUserWithIdentity(ctx context.Context, user *models.User, identity *models.Identity) error
+	// LinkIdentity adds an identity to identity.UserID. ErrConflict means the
+	// provider account already belongs to a user.
+	LinkIdentity(ctx context.Context, identity *models.Identity) error
+	// RecordSignIn stores the identity's email as of this sign-in
+	RecordSignIn(ctx context.Context, identity *models.Identity) error
+	// UnlinkIdentity removes one of the user's identities
+	UnlinkIdentity(ctx context.Context, userID, identityID int) error
+}
+
+// SessionStore persists sign-in sessions
+type SessionStore interface {
+	// CreateSession starts a session, clearing out the user's expired ones
+	CreateSession(ctx context.Context, session *models.Session) error
+	// SessionRevoked reports whether a session is revoked, expired or unknown
+	SessionRevoked(ctx context.Context, sessionID string) (bool, error)
+	// RevokeSession revokes a session together with its refresh tokens
+	RevokeSession(ctx context.Context, sessionID string) error
+	// RevokeUserSessions revokes every active session of a user and returns
+	// their IDs
+	RevokeUserSessions(ctx context.Context, userID int) ([]string, error)
+}
+
+// RefreshTokenStore persists refresh tokens by their hash. A token's family
+// is the session it belongs to.
+type RefreshTokenStore interface {
+	// CreateRefreshToken stores the first token of a session
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	// RotateRefreshToken marks the token with the given hash as used, stores
+	// next in its place, filling in next's user and family, and extends the
+	// session to next's expiry. Unknown, expired and revoked tokens give
+	// ErrNotFound. A token that was already used gives ErrTokenReused and
+	// revokes its session; next.FamilyID then names that session.
+	RotateRefreshToken(ctx context.Context, tokenHash string, next *models.RefreshToken) error
+	// RevokeRefreshToken revokes the session of the token with the given hash
+	// and returns its ID
+	RevokeRefreshToken(ctx context.Context, tokenHash string) (sessionID string, err error)
+}
+
+// PersonalTokenStore persists personal access tokens by their hash
+type PersonalTokenStore interface {
+	CreatePersonalToken(ctx context.Context, token *models.PersonalToken) error
+	// ListPersonalTokens returns the user's tokens that haven't been
+	// revoked, newest first
+	ListPersonalTokens(ctx context.Context, userID int) ([]models.PersonalToken, error)
+	// UsePersonalToken returns the token with the given hash and records
+	// that it was used. Unknown, expired and revoked tokens give ErrNotFound.
+	UsePersonalToken(ctx context.Context, tokenHash string) (models.PersonalToken, error)
+	// RevokePersonalToken revokes one of the user's tokens
+	RevokePersonalToken(ctx context.Context, userID, tokenID int) error
+}
+
+// OAuthStateStore remembers the OAuth states that finished a sign-in, so a
+// callback can't be replayed
+type OAuthStateStore interface {
+	// UseOAuthState records a state as used until it expires, and reports
+	// false if it already was
+	UseOAuthState(ctx context.Context, state string, expiresAt time.Time) (bool, error)
+}
+
+// ShareStore persists user roles on items and answers permission checks
+type ShareStore interface {
+	// ShareItem gives userID the named role on an item, replacing any role
+	// they already had. ErrNotFound is returned if the user does not exist.
+	// Every item keeps at least one owner: ShareItem, ChangeRole and
+	// RevokeAccess give ErrLastOwner rather than remove the last one.
+	ShareItem(ctx context.Context, itemID, userID int, role string, sharedBy int) error
+	// ItemUserIDs returns every user with any role on the item
+	ItemUserIDs(ctx context.Context, itemID int) ([]int, error)
+	// HasPermission reports whether the user's role on the item grants permission
+	HasPermission(ctx context.Context, userID, itemID int, permission string) (bool, error)
+	// ListCollaborators returns everyone with a role on the item, in the
+	// order they got it
+	ListCollaborators(ctx context.Context, itemID int) ([]models.Collaborator, error)
+	// GetRole returns the name of the user's role on the item, or ErrNotFound
+	GetRole(ctx context.Context, itemID, userID int) (string, error)
+	// ChangeRole replaces the role of someone who already has one on the
+	// item. ErrNotFound is returned if they don't.
+	ChangeRole(ctx context.Context, itemID, userID int, role string) error
+	// RevokeAccess removes the user's role on the item, or returns ErrNotFound
+	RevokeAccess(ctx context.Context, itemID, userID int) error
+	// RoleHasPermission reports whether the named role grants permission
+	RoleHasPermission(ctx context.Context, role, permission string) (bool, error)
+}
+
+// TransferStore persists offers to hand an item to a new owner. An item has
+// at most one offer at a time.
+type TransferStore interface {
+	// OfferTransfer stores an offer, replacing any the item already had
+	OfferTransfer(ctx context.Context, transfer *models.OwnershipTransfer) error
+	// GetTransfer returns the item's offer, or ErrNotFound
+	GetTransfer(ctx context.Context, itemID int) (models.OwnershipTransfer, error)
+	// ListTransfersTo returns the offers made to the user
+	ListTransfersTo(ctx context.Context, userID int) ([]models.OwnershipTransfer, error)
+	// AcceptTransfer makes the recipient of the item's offer an owner and the
+	// user who made it an editor. ErrNotFound is returned if there is no
+	// offer to toUserID, or the user who made it is no longer an owner.
+	AcceptTransfer(ctx context.Context, itemID, toUserID int) error
+	// DeleteTransfer withdraws or declines the item's offer
+	DeleteTransfer(ctx context.Context, itemID int) error
+}
+
+// InvitationStore persists invitations to share items with email addresses,
+// looked up by the hash of their token. Expired invitations are never
+// listed or accepted.
+type InvitationStore interface {
+	// CreateInvitation stores an invitation, replacing any the item already
+	// had for the same address, whatever its case
+	CreateInvitation(ctx context.Context, invitation *models.Invitation) error
+	// ListInvitations returns the item's pending invitations, newest first
+	ListInvitations(ctx context.Context, itemID int) ([]models.Invitation, error)
+	// AcceptInvitation gives userID the invitation's role on its item and
+	// deletes it. Someone who already has a role on the item keeps theirs.
+	// ErrNotFound is returned if no pending invitation has the hash.
+	AcceptInvitation(ctx context.Context, tokenHash string, userID int) (models.Invitation, error)
+	// DeclineInvitation deletes the pending invitation with the hash
+	DeclineInvitation(ctx context.Context, tokenHash string) error
+	// RevokeInvitation deletes one of the item's invitations
+	RevokeInvitation(ctx context.Context, itemID, invitationID int) error
+	// ClaimInvitations accepts every pending invitation to email for userID,
+	// as AcceptInvitation does, and returns them
+	ClaimInvitations(ctx context.Context, email string, userID int) ([]models.Invitation, error)
+}
+
+// RoleStore persists the roles users can have on items and the permissions
+// each one grants. Built-in roles can't be changed or deleted; ErrConflict
+// is returned instead.
+type RoleStore interface {
+	// ListRoles returns every role with its permissions, built-in ones first
+	ListRoles(ctx context.Context) ([]models.Role, error)
+	GetRoleByName(ctx context.Context, name string) (models.Role, error)
+	// CreateRole adds a custom role granting role.Permissions. ErrConflict
+	// is returned if the name is taken, and ErrNotFound if one of the
+	// permissions doesn't exist.
+	CreateRole(ctx context.Context, role *models.Role) error
+	// DeleteRole removes a custom role. ErrConflict is returned while
+	// anyone has it, or a pending invitation or working share link offers
+	// it.
+	DeleteRole(ctx context.Context, name string) error
+	// AddRolePermission makes a role grant a permission, if it doesn't
+	// already. ErrNotFound is returned if either doesn't exist.
+	AddRolePermission(ctx context.Context, role, permission string) error
+	// RemoveRolePermission stops a role granting a permission, or returns
+	// ErrNotFound if it didn't
+	RemoveRolePermission(ctx context.Context, role, permission string) error
+	ListPermissions(ctx context.Context) ([]models.Permission, error)
+}
+
+// ShareLinkStore persists share links by the hash of their token. Revoked
+// links are never returned.
+type ShareLinkStore interface {
+	CreateShareLink(ctx context.Context, link *models.ShareLink) error
+	// ListShareLinks returns the item's links, newest first, including ones
+	// that have expired or been used up
+	ListShareLinks(ctx context.Context, itemID int) ([]models.ShareLink, error)
+	// GetShareLink returns the link with the hash. ErrNotFound is returned
+	// unless it still works.
+	GetShareLink(ctx context.Context, tokenHash string) (models.ShareLink, error)
+	// UseShareLink counts one use of a link, or returns ErrNotFound if it no
+	// longer works
+	UseShareLink(ctx context.Context, linkID int) error
+	// RevokeShareLink stops one of the item's links from working
+	RevokeShareLink(ctx context.Context, itemID, linkID int) error
+}
+
+// ItemTypeStore persists the kinds of item and their content schemas
+type ItemTypeStore interface {
+	ListItemTypes(ctx context.Context) ([]models.ItemType, error)
+	GetItemType(ctx context.Context, name string) (models.ItemType, error)
+	// CreateItemType adds a custom type. ErrConflict is returned if the name
+	// is taken.
+	CreateItemType(ctx context.Context, itemType *models.ItemType) error
+}
+
+// SearchStore answers full-text queries over items and todos
+type SearchStore interface {
+	// Search returns the best matching items and todos the user can view
+	Search(ctx context.Context, userID int, query string, limit int) ([]models.SearchResult, error)
+}
+
+// DocMerge folds an update into a document snapshot, which is nil for an
+// item that has never been edited collaboratively; the document then starts
+// from the item's current content. It returns the new snapshot and the item
+// content that mirrors it.
+type DocMerge func(snapshot, content, update json.RawMessage) (merged, mergedContent json.RawMessage, err error)
+
+// DocStore persists the collaboratively edited state of items
+type DocStore interface {
+	// GetDoc returns the item's document and content. Snapshot is nil if no
+	// update has been applied yet. ErrNotFound is returned if the item does not exist.
+	GetDoc(ctx context.Context, itemID int) (models.Doc, error)
+	// ApplyDocUpdate merges one update at a time per item: it runs merge
+	// against the current snapshot, appends the update to the log and saves
+	// the merged snapshot and content, bumping the item's version. Only the
+	// newest keepUpdates updates stay in the log.
+	ApplyDocUpdate(ctx context.Context, update *models.DocUpdate, merge DocMerge, keepUpdates int) (models.Doc, models.Item, error)
+	// DocUpdatesSince returns the logged updates after afterID, oldest first.
+	// ErrCompacted is returned if some of them have been dropped.
+	DocUpdatesSince(ctx context.Context, itemID int, afterID int64) ([]models.DocUpdate, error)
+}
+
+var (
+	_ ItemStore          = (*Postgres)(nil)
+	_ TodoStore          = (*Postgres)(nil)
+	_ UserStore          = (*Postgres)(nil)
+	_ IdentityStore      = (*Postgres)(nil)
+	_ ShareStore         = (*Postgres)(nil)
+	_ TransferStore      = (*Postgres)(nil)
+	_ InvitationStore    = (*Postgres)(nil)
+	_ SearchStore        = (*Postgres)(nil)
+	_ DocStore           = (*Postgres)(nil)
+	_ ItemTypeStore      = (*Postgres)(nil)
+	_ RefreshTokenStore  = (*Postgres)(nil)
+	_ SessionStore       = (*Postgres)(nil)
+	_ OAuthStateStore    = (*Postgres)(nil)
+	_ PersonalTokenStore = (*Postgres)(nil)
+
+	_ ItemStore          = (*Memory)(nil)
+	_ TodoStore          = (*Memory)(nil)
+	_ UserStore          = (*Memory)(nil)
+	_ IdentityStore      = (*Memory)(nil)
+	_ ShareStore         = (*Memory)(nil)
+	_ TransferStore      = (*Memory)(nil)
+	_ InvitationStore    = (*Memory)(nil)
+	_ SearchStore        = (*Memory)(nil)
+	_ DocStore           = (*Memory)(nil)
+	_ ItemTypeStore      = (*Memory)(nil)
+	_ RefreshTokenStore  = (*Memory)(nil)
+	_ SessionStore       = (*Memory)(nil)
+	_ OAuthStateStore    = (*Memory)(nil)
+	_ PersonalTokenStore = (*Memory)(nil)
+)